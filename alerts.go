@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertContextSize is how many recent matching entries are sent with an alert
+const alertContextSize = 5
+
+// Alert is a fired rule with the entries that triggered it
+type Alert struct {
+	Rule    string
+	Time    time.Time
+	Count   int
+	Window  string `json:",omitempty"`
+	Summary string
+	Entries []LogEntry
+}
+
+// Notifier delivers fired alerts somewhere
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// webhookNotifier POSTs the alert as JSON
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookNotifier) Notify(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+// AlertRule fires when more than Above matching entries arrive within
+// Window. With Above 0 every match fires, subject to Cooldown.
+type AlertRule struct {
+	Name      string
+	Level     string
+	Source    string
+	Match     *regexp.Regexp
+	Above     int
+	Window    time.Duration
+	Cooldown  time.Duration
+	Notifiers []Notifier
+
+	hits      []time.Time
+	recent    []LogEntry
+	lastFired time.Time
+}
+
+// matches reports whether an entry satisfies the rule's conditions
+func (r *AlertRule) matches(entry LogEntry) bool {
+	if r.Level != "" && entry.Level != r.Level {
+		return false
+	}
+	if r.Source != "" && !strings.Contains(strings.ToLower(entry.Source), strings.ToLower(r.Source)) {
+		return false
+	}
+	if r.Match != nil && !r.Match.MatchString(entry.Message) {
+		return false
+	}
+	return true
+}
+
+// observe records a matching entry at time now and returns the alert to
+// fire, if any
+func (r *AlertRule) observe(entry LogEntry, now time.Time) *Alert {
+	r.recent = append(r.recent, entry)
+	if len(r.recent) > alertContextSize {
+		r.recent = r.recent[len(r.recent)-alertContextSize:]
+	}
+
+	r.hits = append(r.hits, now)
+	if r.Window > 0 {
+		drop := 0
+		for drop < len(r.hits) && now.Sub(r.hits[drop]) > r.Window {
+			drop++
+		}
+		r.hits = r.hits[drop:]
+	}
+
+	if len(r.hits) <= r.Above {
+		return nil
+	}
+	if !r.lastFired.IsZero() && now.Sub(r.lastFired) < r.Cooldown {
+		return nil
+	}
+	r.lastFired = now
+
+	alert := &Alert{
+		Rule:    r.Name,
+		Time:    now,
+		Count:   len(r.hits),
+		Entries: append([]LogEntry(nil), r.recent...),
+	}
+	if r.Above > 0 {
+		alert.Window = r.Window.String()
+		alert.Summary = fmt.Sprintf("%s: %d matching entries in %s (threshold %d)", r.Name, len(r.hits), r.Window, r.Above)
+	} else {
+		alert.Summary = fmt.Sprintf("%s: %s", r.Name, entry.Message)
+	}
+	return alert
+}
+
+// AlertEngine evaluates rules against entries as they arrive
+type AlertEngine struct {
+	rules []*AlertRule
+}
+
+// Check runs an entry through every rule and dispatches any alerts. Delivery
+// happens in the background so a slow receiver can't stall following.
+func (e *AlertEngine) Check(entry LogEntry) {
+	now := entry.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	for _, rule := range e.rules {
+		if !rule.matches(entry) {
+			continue
+		}
+		alert := rule.observe(entry, now)
+		if alert == nil {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "ALERT %s\n", alert.Summary)
+		for _, n := range rule.Notifiers {
+			go func(n Notifier, a Alert) {
+				if err := n.Notify(a); err != nil {
+					log.Printf("Error sending alert %s: %v", a.Rule, err)
+				}
+			}(n, *alert)
+		}
+	}
+}
+
+// LoadAlertRules reads a rules file. The format is a small YAML subset (a
+// list of flat key: value maps, optionally under a top-level "rules:" key);
+// JSON is accepted too.
+//
+//	rules:
+//	  - name: error-spike
+//	    level: ERROR
+//	    above: 50
+//	    window: 5m
+//	    webhook: https://example.com/hook
+//	  - name: oom
+//	    match: OOMKilled
+//	    slack: https://hooks.slack.com/services/...
+//	    pagerduty: <Events v2 routing key>
+//	    opsgenie: <API key>
+func LoadAlertRules(path string) (*AlertEngine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []map[string]string
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		specs, err = parseRulesJSON(trimmed)
+	} else {
+		specs, err = parseRulesYAML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	engine := &AlertEngine{}
+	for i, spec := range specs {
+		rule, err := newAlertRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %v", path, i+1, err)
+		}
+		engine.rules = append(engine.rules, rule)
+	}
+	if len(engine.rules) == 0 {
+		return nil, fmt.Errorf("%s: no rules defined", path)
+	}
+	return engine, nil
+}
+
+// newAlertRule builds a rule from its key/value description
+func newAlertRule(spec map[string]string) (*AlertRule, error) {
+	rule := &AlertRule{Name: spec["name"], Level: strings.ToUpper(spec["level"]), Source: spec["source"]}
+	if rule.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+
+	for key, value := range spec {
+		var err error
+		switch key {
+		case "name", "level", "source":
+		case "match":
+			rule.Match, err = regexp.Compile(strings.TrimSuffix(strings.TrimPrefix(value, "/"), "/"))
+		case "above":
+			rule.Above, err = strconv.Atoi(value)
+		case "window":
+			rule.Window, err = time.ParseDuration(value)
+		case "cooldown":
+			rule.Cooldown, err = time.ParseDuration(value)
+		case "webhook":
+			rule.Notifiers = append(rule.Notifiers, &webhookNotifier{url: value, client: &http.Client{Timeout: 10 * time.Second}})
+		case "slack":
+			rule.Notifiers = append(rule.Notifiers, newSlackNotifier(value))
+		case "pagerduty":
+			rule.Notifiers = append(rule.Notifiers, newPagerDutyNotifier(value))
+		case "opsgenie":
+			rule.Notifiers = append(rule.Notifiers, newOpsgenieNotifier(value))
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+
+	if rule.Above > 0 && rule.Window == 0 {
+		rule.Window = 5 * time.Minute
+	}
+	if _, ok := spec["cooldown"]; !ok && rule.Above > 0 {
+		// Don't re-fire on every entry while the window stays over threshold
+		rule.Cooldown = rule.Window
+	}
+	return rule, nil
+}
+
+// parseRulesJSON accepts either a list of rules or {"rules": [...]}, with
+// numbers allowed as plain JSON numbers
+func parseRulesJSON(data []byte) ([]map[string]string, error) {
+	var raw []map[string]interface{}
+	if data[0] == '{' {
+		var wrapper struct {
+			Rules []map[string]interface{} `json:"rules"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, err
+		}
+		raw = wrapper.Rules
+	} else if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	specs := make([]map[string]string, len(raw))
+	for i, r := range raw {
+		specs[i] = make(map[string]string)
+		for k, v := range r {
+			specs[i][k] = fmt.Sprint(v)
+		}
+	}
+	return specs, nil
+}
+
+// parseRulesYAML parses a list of flat maps. Nested structures, anchors and
+// multi-line strings are not supported.
+func parseRulesYAML(data []byte) ([]map[string]string, error) {
+	var specs []map[string]string
+	var current map[string]string
+
+	scanner := new(longLines).newScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := stripYAMLComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if line == trimmed && strings.HasSuffix(trimmed, ":") {
+			// Top-level key such as "rules:"
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			current = make(map[string]string)
+			specs = append(specs, current)
+			trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			if trimmed == "" {
+				continue
+			}
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: expected a list item (- key: value)", lineNum)
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", lineNum)
+		}
+		current[strings.TrimSpace(key)] = unquoteYAML(strings.TrimSpace(value))
+	}
+	return specs, scanner.Err()
+}
+
+// stripYAMLComment removes a trailing # comment outside quotes
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquoteYAML(value string) string {
+	if len(value) >= 2 {
+		if value[0] == '"' && value[len(value)-1] == '"' {
+			if s, err := strconv.Unquote(value); err == nil {
+				return s
+			}
+			return value[1 : len(value)-1]
+		}
+		if value[0] == '\'' && value[len(value)-1] == '\'' {
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+	}
+	return value
+}
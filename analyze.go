@@ -0,0 +1,382 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// analyzeOptions holds the listing and report flags of the analyze command
+type analyzeOptions struct {
+	stats              *bool
+	tail               *int
+	head               *int
+	output             *string
+	verbose            *bool
+	distinct           *string
+	errorRate          *bool
+	bucket             *time.Duration
+	errorRateThreshold *string
+	templates          *int
+	gaps               *time.Duration
+	bursts             *float64
+	sessions           *bool
+	sessionTimeout     *time.Duration
+	precursors         *int
+	precursorWindow    *time.Duration
+	saveBase           *string
+	compareBase        *string
+	rateTolerance      *float64
+	sortOutput         *bool
+	failOnErrors       *int
+	failOnMatch        *string
+	probes             *bool
+	probeMinPaths      *int
+	probeRatio         *float64
+	sortBuffer         *int
+	maxMemory          byteSize
+	orderCheck         *bool
+	heatmap            *bool
+	heatmapErrors      *bool
+	sizes              *bool
+	cache              *bool
+	slowest            *int
+	durationField      *string
+	intervals          *bool
+	mttr               *bool
+	mttrPairs          []recoveryPair
+}
+
+func addAnalyzeFlags(fs *flag.FlagSet) *analyzeOptions {
+	o := &analyzeOptions{
+		stats:              fs.Bool("stats", false, "Show statistics"),
+		tail:               fs.Int("tail", 0, "Show last N lines; with -follow, the last N entries of the file before the new ones"),
+		head:               fs.Int("head", 0, "Show first N lines"),
+		output:             fs.String("output", "", "Output format (json, ndjson, csv, logfmt)"),
+		verbose:            fs.Bool("v", false, "Verbose output"),
+		distinct:           fs.String("distinct", "", "Count unique values of a field (source, level, message or a -derive field); comma-separated for several"),
+		errorRate:          fs.Bool("error-rate", false, "Show error percentage per time bucket"),
+		bucket:             fs.Duration("bucket", 5*time.Minute, "Time bucket width for timeline reports"),
+		errorRateThreshold: fs.String("error-rate-threshold", "", "Flag buckets whose error rate exceeds this percentage (e.g. 5%)"),
+		templates:          fs.Int("templates", 0, "Show the top N message templates (messages clustered by their constant parts)"),
+		gaps:               fs.Duration("gaps", 0, "Report periods longer than this in which a source logged nothing"),
+		bursts:             fs.Float64("bursts", 0, "Report minutes where a source logged more than N times its own average"),
+		sessions:           fs.Bool("sessions", false, "Group access log requests into visitor sessions and report on them"),
+		sessionTimeout:     fs.Duration("session-timeout", 30*time.Minute, "Idle time that ends a session"),
+		precursors:         fs.Int("precursors", 0, "For the top N error templates, show messages that tend to precede them"),
+		precursorWindow:    fs.Duration("precursor-window", 30*time.Second, "How far before an error to look for precursors"),
+		saveBase:           fs.String("save-baseline", "", "Save a summary of the filtered entries to this JSON file"),
+		compareBase:        fs.String("compare-baseline", "", "Compare against a saved baseline and exit 1 on regressions"),
+		rateTolerance:      fs.Float64("error-rate-tolerance", 1.0, "Error rate increase (percentage points) tolerated by -compare-baseline"),
+		sortOutput:         fs.Bool("sort", false, "Output entries in timestamp order"),
+		failOnErrors:       fs.Int("fail-on-error-count", -1, "Exit 1 when more than N error entries match the filters (for CI)"),
+		failOnMatch:        fs.String("fail-on-match", "", "Exit 1 when any filtered entry matches this regex, ignoring case unless -case-sensitive (for CI)"),
+		probes:             fs.Bool("probes", false, "Report clients probing many paths that return 404/400 (vulnerability scanners)"),
+		probeMinPaths:      fs.Int("probe-min-paths", 10, "Distinct failing paths needed for -probes to flag a client"),
+		probeRatio:         fs.Float64("probe-ratio", 0.5, "Share of a client's requests that must fail for -probes to flag it"),
+		sortBuffer:         fs.Int("sort-buffer", 500000, "Entries sorted in memory before -sort spills to temporary files"),
+		orderCheck:         fs.Bool("order-check", false, "Report entries whose timestamp is earlier than the one before (clock skew, broken shippers)"),
+		heatmap:            fs.Bool("heatmap", false, "Show entries per weekday and hour as a shaded grid (CSV with -output csv)"),
+		heatmapErrors:      fs.Bool("heatmap-errors", false, "Count only ERROR and FATAL entries in -heatmap"),
+		mttr:               fs.Bool("mttr", false, "Pair failures with the recovery after them and report downtime episodes and the mean time to recovery"),
+		intervals:          fs.Bool("intervals", false, "Report min/avg/p99 time between consecutive filtered entries (heartbeats, job schedules)"),
+		slowest:            fs.Int("slowest", 0, "List the N slowest requests by their duration field"),
+		durationField:      fs.String("duration-field", "", "Field holding request durations for -slowest (default the first of duration_ms, latency_ms, duration, latency, request_time, time_taken...)"),
+		cache:              fs.Bool("cache", false, "Show CDN/proxy cache hit, stale and miss ratios, overall and per path prefix"),
+		sizes:              fs.Bool("sizes", false, "Show a histogram of access log response sizes per status class and the largest responses"),
+	}
+	fs.Func("mttr-pair", `Failure and recovery patterns for -mttr, e.g. "connection refused=>reconnected" (repeat for several; default errors => recovered/reconnected/restored...)`, func(value string) error {
+		pair, err := parseRecoveryPair(value)
+		o.mttrPairs = append(o.mttrPairs, pair)
+		return err
+	})
+	fs.Var(&o.maxMemory, "max-memory", "Memory for buffered entries when listing or sorting (e.g. 512MB, 1GB); beyond it entries spill to temporary files")
+	return o
+}
+
+// runAnalyze filters the entries and lists them or prints the selected
+// report
+func runAnalyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	input := addInputFlags(fs)
+	opts := addAnalyzeFlags(fs)
+	parseFlags(fs, args)
+
+	if !input.hasFiles() {
+		fmt.Println("Usage: loganalyzer analyze -f <logfile> [options]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	recordHistory(append([]string{"analyze"}, args...))
+	opts.run(fs, input)
+}
+
+// runStats prints summary statistics of the entries
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	input := addInputFlags(fs)
+	parseFlags(fs, args)
+
+	if !input.hasFiles() {
+		fmt.Println("Usage: loganalyzer stats -f <logfile> [options]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	analyzer, _ := input.load()
+	analyzer.showStats()
+}
+
+// runFollow prints entries as they are appended, like tail -f
+func runFollow(args []string) {
+	fs := flag.NewFlagSet("follow", flag.ExitOnError)
+	input := addInputFlags(fs)
+	live := addLiveFlags(fs)
+	verbose := fs.Bool("v", false, "Verbose output")
+	start := addFollowFlags(fs)
+	tail := fs.Int("tail", 0, "Print the last N entries of the file matching the filters before following, like tail -n N -f")
+	parseFlags(fs, args)
+
+	if !input.hasFiles() {
+		fmt.Println("Usage: loganalyzer follow -f <logfile> [options]")
+		fmt.Println("       loganalyzer follow -docker <container> [options]")
+		fmt.Println("       loganalyzer follow -f journal://[unit] [options]")
+		fmt.Println("       loganalyzer follow -k8s -namespace <ns> -selector <labels> [options]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	follow(input, live, *verbose, start, *tail)
+}
+
+// hasFiles reports whether there is anything to read, reading stdin when
+// it is a pipe and no -f was given
+func (o *inputOptions) hasFiles() bool {
+	if len(o.files) == 0 && !*o.k8s && stdinIsPipe() {
+		o.files = fileList{"-"}
+	}
+	return len(o.files) > 0 || *o.k8s
+}
+
+// followOptions holds the flags choosing where following a file starts
+type followOptions struct {
+	state     *string
+	since     *time.Duration
+	fromStart *bool
+}
+
+func addFollowFlags(fs *flag.FlagSet) *followOptions {
+	return &followOptions{
+		state:     fs.String("state", "", "State file (e.g. .loganalyzer.state) recording how far each followed file was read; a restart resumes there instead of at the end"),
+		since:     fs.Duration("since", 0, "Start following at the first entry of the last duration (e.g. 15m) instead of at the end, found with the file's index or by scanning back from the end"),
+		fromStart: fs.Bool("from-start", false, "Start following at the beginning of the file instead of at the end"),
+	}
+}
+
+func follow(input *inputOptions, live *liveOptions, verbose bool, start *followOptions, tail int) {
+	analyzer := input.newAnalyzer()
+	analyzer.followTail = tail
+	analyzer.followSince = *start.since
+	analyzer.followFromStart = *start.fromStart
+	if *start.since < 0 {
+		log.Fatal("-since must be positive")
+	}
+	if *start.state != "" {
+		state, err := loadFollowState(*start.state)
+		if err != nil {
+			log.Fatalf("Error reading state file: %v", err)
+		}
+		analyzer.state = state
+	}
+	if *input.k8s || strings.Contains(input.files[0], "://") {
+		switch {
+		case tail > 0:
+			log.Fatal("-tail with -follow supports local files only")
+		case *start.since > 0 || *start.fromStart:
+			log.Fatal("-since and -from-start support local files only")
+		}
+	}
+	if *input.k8s {
+		live.apply(analyzer)
+		analyzer.followK8s(*input.namespace, *input.selector, *input.container, *input.format, verbose)
+		return
+	}
+	if len(input.files) > 1 {
+		log.Fatal("follow supports a single file")
+	}
+	live.apply(analyzer)
+	analyzer.followFile(input.files[0], *input.format, verbose)
+}
+
+// run reads the inputs and prints the first report selected by the flags,
+// or the filtered entries when none is
+func (o *analyzeOptions) run(fs *flag.FlagSet, input *inputOptions) {
+	var failPattern *regexp.Regexp
+	if *o.failOnMatch != "" {
+		re, err := input.buildFilters().Compile(*o.failOnMatch)
+		if err != nil {
+			log.Fatalf("Invalid -fail-on-match pattern: %v", err)
+		}
+		failPattern = re
+	}
+	if *o.bucket <= 0 {
+		log.Fatal("-bucket must be positive")
+	}
+
+	analyzer := input.newAnalyzer()
+	analyzer.verbose = *o.verbose
+	format := *input.format
+	input.addPods(analyzer)
+
+	buffered := *o.sortOutput || o.maxMemory > 0
+	if buffered && listingOnly(fs, "sort", "sort-buffer", "max-memory", "head", "tail", "output", "v") {
+		// Listing streams through temporary files, merge sorted for -sort,
+		// so inputs larger than memory can still be listed and ordered
+		if err := analyzer.outputSpilled(input.files, format, *o.sortOutput, *o.sortBuffer, int64(o.maxMemory), *o.head, *o.tail, *o.output, *o.verbose); err != nil {
+			log.Fatalf("Error listing entries: %v", err)
+		}
+		return
+	}
+
+	for _, filename := range input.files {
+		if err := analyzer.parseFile(filename, format); err != nil {
+			log.Fatalf("Error parsing file: %v", err)
+		}
+	}
+
+	filteredEntries := analyzer.filterEntries()
+	if *o.sortOutput {
+		// Reports and listings that aren't streamed above see the entries
+		// in timestamp order too
+		sortEntries(filteredEntries)
+	}
+
+	// Checked after whichever report runs below, so the output is still
+	// produced before the non-zero exit
+	if reason := failureReason(filteredEntries, *o.failOnErrors, failPattern); reason != "" {
+		defer func() {
+			fmt.Fprintln(os.Stderr, "FAIL: "+reason)
+			os.Exit(1)
+		}()
+	}
+
+	if *o.saveBase != "" {
+		if err := saveBaseline(*o.saveBase, summarize(filteredEntries)); err != nil {
+			log.Fatalf("Error saving baseline: %v", err)
+		}
+		fmt.Printf("Baseline saved to %s\n", *o.saveBase)
+		return
+	}
+
+	if *o.compareBase != "" {
+		regressed, err := compareBaseline(*o.compareBase, filteredEntries, *o.rateTolerance)
+		if err != nil {
+			log.Fatalf("Error loading baseline: %v", err)
+		}
+		if regressed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *o.stats {
+		analyzer.showStats()
+		return
+	}
+
+	if *o.errorRate || *o.errorRateThreshold != "" {
+		threshold := 0.0
+		if *o.errorRateThreshold != "" {
+			t, err := parsePercent(*o.errorRateThreshold)
+			if err != nil {
+				log.Fatalf("Invalid error rate threshold: %v", err)
+			}
+			threshold = t
+		}
+		if n := bucketCount(filteredEntries, *o.bucket); n > maxBuckets {
+			log.Fatalf("-bucket %s makes %d buckets over the time range, more than %d; use a wider bucket", *o.bucket, n, maxBuckets)
+		}
+		analyzer.showErrorRate(filteredEntries, *o.bucket, threshold)
+		return
+	}
+
+	if *o.heatmap || *o.heatmapErrors {
+		analyzer.showHeatmap(filteredEntries, *o.heatmapErrors, *o.output)
+		return
+	}
+
+	if *o.mttr || len(o.mttrPairs) > 0 {
+		analyzer.showMTTR(filteredEntries, o.mttrPairs)
+		return
+	}
+
+	if *o.intervals {
+		analyzer.showIntervals(filteredEntries, 5)
+		return
+	}
+
+	if *o.slowest > 0 {
+		analyzer.showSlowest(filteredEntries, *o.slowest, *o.durationField)
+		return
+	}
+
+	if *o.cache {
+		analyzer.showCache(filteredEntries, 20)
+		return
+	}
+
+	if *o.sizes {
+		analyzer.showSizes(filteredEntries, 10)
+		return
+	}
+
+	if *o.orderCheck {
+		analyzer.showOrderCheck(filteredEntries)
+		return
+	}
+
+	if *o.precursors > 0 {
+		analyzer.showPrecursors(filteredEntries, *o.precursors, *o.precursorWindow)
+		return
+	}
+
+	if *o.probes {
+		analyzer.showProbes(filteredEntries, *o.probeMinPaths, *o.probeRatio)
+		return
+	}
+
+	if *o.sessions {
+		analyzer.showSessions(filteredEntries, *o.sessionTimeout)
+		return
+	}
+
+	if *o.bursts > 0 {
+		analyzer.showBursts(filteredEntries, *o.bursts)
+		return
+	}
+
+	if *o.gaps > 0 {
+		analyzer.showGaps(filteredEntries, *o.gaps)
+		return
+	}
+
+	if *o.templates > 0 {
+		analyzer.showTemplates(filteredEntries, *o.templates)
+		return
+	}
+
+	if *o.distinct != "" {
+		analyzer.showDistinct(filteredEntries, strings.Split(*o.distinct, ","))
+		return
+	}
+
+	if *o.head > 0 {
+		filteredEntries = analyzer.getHead(filteredEntries, *o.head)
+	} else if *o.tail > 0 {
+		filteredEntries = analyzer.getTail(filteredEntries, *o.tail)
+	}
+
+	analyzer.outputEntries(filteredEntries, *o.output, *o.verbose)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// authFailurePatterns recognise failed logins in sshd/PAM syslog messages.
+// Each has named groups ip and (optionally) user.
+var authFailurePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`Failed (?:password|publickey|keyboard-interactive/pam) for (?:invalid user )?(?P<user>\S*) from (?P<ip>[0-9a-fA-F.:]+)`),
+	regexp.MustCompile(`Invalid user (?P<user>\S*) from (?P<ip>[0-9a-fA-F.:]+)`),
+	regexp.MustCompile(`authentication failure;.*rhost=(?P<ip>[0-9a-fA-F.:]+)(?:\s+user=(?P<user>\S+))?`),
+	regexp.MustCompile(`(?i)(?:login|authentication) failed for (?:user )?'?(?P<user>[^'\s]*)'? from (?P<ip>[0-9a-fA-F.:]+)`),
+}
+
+// AuthFailure is a single failed login attempt
+type AuthFailure struct {
+	Time time.Time
+	IP   string
+	User string
+}
+
+// authFailure extracts a failed login from an entry: sshd/PAM messages, or
+// 401 responses in access logs
+func authFailure(entry LogEntry) (AuthFailure, bool) {
+	if entry.Access != nil {
+		if entry.Access.Status == 401 {
+			return AuthFailure{Time: entry.Timestamp, IP: entry.Access.ClientIP}, true
+		}
+		return AuthFailure{}, false
+	}
+
+	for _, re := range authFailurePatterns {
+		m := re.FindStringSubmatch(entry.Message)
+		if m == nil {
+			continue
+		}
+		f := AuthFailure{Time: entry.Timestamp}
+		for i, name := range re.SubexpNames() {
+			switch name {
+			case "ip":
+				f.IP = m[i]
+			case "user":
+				f.User = m[i]
+			}
+		}
+		if _, err := netip.ParseAddr(f.IP); err == nil {
+			return f, true
+		}
+	}
+	return AuthFailure{}, false
+}
+
+// Offender is a client that exceeded the failure threshold
+type Offender struct {
+	IP       string
+	Failures int
+	// Peak is the most failures seen inside a single window
+	Peak  int
+	First time.Time
+	Last  time.Time
+	Users map[string]int
+}
+
+// findOffenders returns clients with more than threshold failures within
+// any window, worst first
+func findOffenders(failures []AuthFailure, threshold int, window time.Duration) []Offender {
+	byIP := make(map[string][]AuthFailure)
+	for _, f := range failures {
+		byIP[f.IP] = append(byIP[f.IP], f)
+	}
+
+	var offenders []Offender
+	for ip, list := range byIP {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Time.Before(list[j].Time)
+		})
+
+		peak, start := 0, 0
+		for end := range list {
+			for list[end].Time.Sub(list[start].Time) > window {
+				start++
+			}
+			if n := end - start + 1; n > peak {
+				peak = n
+			}
+		}
+		if peak <= threshold {
+			continue
+		}
+
+		o := Offender{IP: ip, Failures: len(list), Peak: peak, First: list[0].Time, Last: list[len(list)-1].Time, Users: make(map[string]int)}
+		for _, f := range list {
+			if f.User != "" {
+				o.Users[f.User]++
+			}
+		}
+		offenders = append(offenders, o)
+	}
+
+	sort.Slice(offenders, func(i, j int) bool {
+		if offenders[i].Peak != offenders[j].Peak {
+			return offenders[i].Peak > offenders[j].Peak
+		}
+		return offenders[i].IP < offenders[j].IP
+	})
+	return offenders
+}
+
+// aggregateCIDRs collapses addresses into the smallest set of prefixes that
+// covers exactly those addresses, so adjacent hosts become one rule
+func aggregateCIDRs(ips []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, ip := range ips {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+
+	// Repeatedly merge sibling prefixes (two halves of the same parent)
+	for merged := true; merged; {
+		merged = false
+		sort.Slice(prefixes, func(i, j int) bool {
+			if prefixes[i].Addr() != prefixes[j].Addr() {
+				return prefixes[i].Addr().Less(prefixes[j].Addr())
+			}
+			return prefixes[i].Bits() < prefixes[j].Bits()
+		})
+
+		var out []netip.Prefix
+		for _, p := range prefixes {
+			if len(out) > 0 {
+				last := out[len(out)-1]
+				if last.Overlaps(p) && last.Bits() <= p.Bits() {
+					continue
+				}
+				if last.Bits() == p.Bits() && last.Bits() > 0 {
+					parent, _ := last.Addr().Prefix(last.Bits() - 1)
+					if parent.Addr() == last.Addr() && parent.Contains(p.Addr()) {
+						out[len(out)-1] = parent
+						merged = true
+						continue
+					}
+				}
+			}
+			out = append(out, p)
+		}
+		prefixes = out
+	}
+	return prefixes
+}
+
+// writeBlocklist prints the offenders in a form other tools can ingest
+func writeBlocklist(offenders []Offender, format, jail string) error {
+	ips := make([]string, len(offenders))
+	for i, o := range offenders {
+		ips[i] = o.IP
+	}
+	sort.Strings(ips)
+
+	switch format {
+	case "plain":
+		for _, ip := range ips {
+			fmt.Println(ip)
+		}
+	case "cidr":
+		for _, p := range aggregateCIDRs(ips) {
+			fmt.Println(p)
+		}
+	case "fail2ban":
+		for _, ip := range ips {
+			fmt.Printf("fail2ban-client set %s banip %s\n", jail, ip)
+		}
+	default:
+		return fmt.Errorf("unknown blocklist format %q (plain, cidr, fail2ban)", format)
+	}
+	return nil
+}
+
+// runBruteforce reports clients with too many failed logins and can emit
+// them as a blocklist
+func runBruteforce(args []string) {
+	fs := flag.NewFlagSet("bruteforce", flag.ExitOnError)
+	input := addInputFlags(fs)
+	threshold := fs.Int("threshold", 20, "Flag clients with more than this many failures within -window")
+	window := fs.Duration("window", 5*time.Minute, "Sliding window for -threshold")
+	blocklist := fs.String("blocklist", "", "Print only a blocklist: plain, cidr (aggregated) or fail2ban")
+	jail := fs.String("jail", "sshd", "fail2ban jail name for -blocklist fail2ban")
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 {
+		fmt.Println("Usage: loganalyzer bruteforce -f <auth.log> [-threshold N] [-window 5m] [-blocklist plain|cidr|fail2ban]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	_, entries := input.load()
+	var failures []AuthFailure
+	for _, entry := range entries {
+		if f, ok := authFailure(entry); ok {
+			failures = append(failures, f)
+		}
+	}
+	offenders := findOffenders(failures, *threshold, *window)
+
+	if *blocklist != "" {
+		if err := writeBlocklist(offenders, *blocklist, *jail); err != nil {
+			log.Fatalf("Error writing blocklist: %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("=== Brute-force Report (%d failed logins, >%d in %s) ===\n", len(failures), *threshold, *window)
+	if len(offenders) == 0 {
+		fmt.Println("No clients exceeded the threshold")
+		return
+	}
+
+	fmt.Printf("%-39s %6s %8s  %-19s  %-19s  %s\n", "Client", "Peak", "Failures", "First", "Last", "Users")
+	for _, o := range offenders {
+		users := make([]string, 0, len(o.Users))
+		for u := range o.Users {
+			users = append(users, u)
+		}
+		sort.Slice(users, func(i, j int) bool {
+			if o.Users[users[i]] != o.Users[users[j]] {
+				return o.Users[users[i]] > o.Users[users[j]]
+			}
+			return users[i] < users[j]
+		})
+		if len(users) > 5 {
+			users = append(users[:5], fmt.Sprintf("+%d more", len(o.Users)-5))
+		}
+		fmt.Printf("%-39s %6d %8d  %-19s  %-19s  %s\n", o.IP, o.Peak, o.Failures,
+			o.First.Format("2006-01-02 15:04:05"), o.Last.Format("2006-01-02 15:04:05"), strings.Join(users, ","))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+func saveBaseline(path string, summary *LogSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func loadBaseline(path string) (*LogSummary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var summary LogSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if summary.Levels == nil {
+		summary.Levels = make(map[string]int)
+	}
+	if summary.Errors == nil {
+		summary.Errors = make(map[string]int)
+	}
+	return &summary, nil
+}
+
+// findRegressions lists error types missing from the baseline and an error
+// rate that grew by more than tolerance percentage points
+func findRegressions(baseline, current *LogSummary, tolerance float64) []string {
+	var regressions []string
+
+	var newErrors []string
+	for msg := range current.Errors {
+		if _, ok := baseline.Errors[msg]; !ok {
+			newErrors = append(newErrors, msg)
+		}
+	}
+	sort.Strings(newErrors)
+	for _, msg := range newErrors {
+		regressions = append(regressions, fmt.Sprintf("new error: %s (%d)", msg, current.Errors[msg]))
+	}
+
+	if increase := current.ErrorRate() - baseline.ErrorRate(); increase > tolerance {
+		regressions = append(regressions, fmt.Sprintf("error rate up %.2f points: %.2f%% -> %.2f%%",
+			increase, baseline.ErrorRate(), current.ErrorRate()))
+	}
+
+	return regressions
+}
+
+// compareBaseline prints the diff against a saved baseline and returns
+// whether any regression was found
+func compareBaseline(path string, entries []LogEntry, tolerance float64) (bool, error) {
+	baseline, err := loadBaseline(path)
+	if err != nil {
+		return false, err
+	}
+
+	current := summarize(entries)
+	printSummaryDiff(baseline, current)
+
+	regressions := findRegressions(baseline, current, tolerance)
+	fmt.Println()
+	if len(regressions) == 0 {
+		fmt.Println("No regressions against baseline")
+		return false, nil
+	}
+
+	fmt.Printf("Regressions (%d):\n", len(regressions))
+	for _, r := range regressions {
+		fmt.Printf("  %s\n", r)
+	}
+	return true, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/output"
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// benchInput is the lines of an input read into memory by bench
+type benchInput struct {
+	lines []string
+}
+
+// benchStage is the measured cost of one pipeline stage or format
+type benchStage struct {
+	name    string
+	lines   int
+	bytes   int64
+	elapsed time.Duration
+}
+
+// rates returns the stage's throughput in lines and megabytes per second
+func (s benchStage) rates() (linesPerSec, mbPerSec float64) {
+	secs := s.elapsed.Seconds()
+	if secs == 0 {
+		secs = 1e-9
+	}
+	return float64(s.lines) / secs, float64(s.bytes) / (1 << 20) / secs
+}
+
+func (s benchStage) print() {
+	lines, mb := s.rates()
+	fmt.Printf("%-14s %10d %12s %14.0f %10.1f\n", s.name, s.lines, s.elapsed.Round(time.Microsecond), lines, mb)
+}
+
+// runBench times each stage of the pipeline (read, parse, filter, output)
+// over the inputs, and the parsing of every format that reads them, so
+// regressions and the cost of a custom pattern can be measured. Entries
+// are written to a discarded output.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	input := addInputFlags(fs)
+	maxLines := fs.Int("lines", 1000000, "Lines read from each input, which are held in memory (0 for all)")
+	outFormat := fs.String("output", "", "Output format timed by the output stage (json, ndjson, csv, logfmt; default text)")
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 {
+		fmt.Println("Usage: loganalyzer bench -f <logfile> [-f <logfile>...] [-format <format>] [-lines 1000000] [options]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	analyzer := input.newAnalyzer()
+	// Progress lines would be timed along with the reading
+	analyzer.progress = false
+
+	read := benchStage{name: "read"}
+	start := time.Now()
+	var inputs []benchInput
+	for _, filename := range input.files {
+		in, size, err := analyzer.benchRead(filename, *maxLines)
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", filename, err)
+		}
+		inputs = append(inputs, in)
+		read.lines += len(in.lines)
+		read.bytes += size
+	}
+	read.elapsed = time.Since(start)
+
+	parse := benchStage{name: "parse", lines: read.lines, bytes: read.bytes}
+	start = time.Now()
+	var entries []LogEntry
+	for _, in := range inputs {
+		format := *input.format
+		if format == "auto" && analyzer.sniffLines > 0 {
+			format = analyzer.sniffFormat(in.lines[:min(len(in.lines), analyzer.sniffLines)])
+		}
+		session := parser.NewSession(format)
+		for i, line := range in.lines {
+			if entry, _ := analyzer.tryParse(line, session, i+1); entry != nil {
+				entries = append(entries, *entry)
+			}
+		}
+	}
+	parse.elapsed = time.Since(start)
+
+	filter := benchStage{name: "filter", lines: len(entries)}
+	start = time.Now()
+	var filtered []LogEntry
+	for _, entry := range entries {
+		filter.bytes += int64(len(entry.Raw)) + 1
+		if analyzer.matchesFilters(entry) {
+			filtered = append(filtered, entry)
+		}
+	}
+	filter.elapsed = time.Since(start)
+
+	out := benchStage{name: "output", lines: len(filtered)}
+	counter := &countingWriter{w: io.Discard}
+	start = time.Now()
+	w := output.NewWriter(counter, *outFormat, false)
+	for _, entry := range filtered {
+		if err := w.Write(entry); err != nil {
+			log.Fatalf("Error writing output: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalf("Error writing output: %v", err)
+	}
+	out.elapsed = time.Since(start)
+	out.bytes = counter.n
+
+	fmt.Printf("=== Benchmark: %d lines, %.1f MB, format %s ===\n", read.lines, float64(read.bytes)/(1<<20), *input.format)
+	fmt.Printf("%-14s %10s %12s %14s %10s\n", "Stage", "Lines", "Time", "Lines/s", "MB/s")
+	for _, s := range []benchStage{read, parse, filter, out} {
+		s.print()
+	}
+	total := benchStage{name: "total", lines: read.lines, bytes: read.bytes,
+		elapsed: read.elapsed + parse.elapsed + filter.elapsed + out.elapsed}
+	total.print()
+
+	fmt.Println()
+	fmt.Println("Parsing every line with each format that detects the inputs:")
+	fmt.Printf("%-14s %10s %12s %14s %10s\n", "Format", "Matched", "Time", "Lines/s", "MB/s")
+	for _, name := range benchFormats(inputs) {
+		// Throughput is over all lines, matched or not
+		s := benchStage{name: name, lines: read.lines, bytes: read.bytes}
+		matched := 0
+		start := time.Now()
+		for _, in := range inputs {
+			session := parser.NewSession(name)
+			for _, line := range in.lines {
+				if _, ok := session.TryParse(line); ok {
+					matched++
+				}
+			}
+		}
+		s.elapsed = time.Since(start)
+		lines, mb := s.rates()
+		fmt.Printf("%-14s %10d %12s %14.0f %10.1f\n", name, matched, s.elapsed.Round(time.Microsecond), lines, mb)
+	}
+}
+
+// benchRead reads up to limit lines of an input (all when limit is 0),
+// returning them and their size in bytes
+func (la *LogAnalyzer) benchRead(filename string, limit int) (benchInput, int64, error) {
+	var in benchInput
+	r, err := la.openInput(filename)
+	if err != nil {
+		return in, 0, err
+	}
+	defer r.Close()
+
+	var size int64
+	scanner := la.newLineScanner(r)
+	for (limit <= 0 || len(in.lines) < limit) && scanner.Scan() {
+		line := scanner.Text()
+		in.lines = append(in.lines, line)
+		size += int64(len(line)) + 1
+	}
+	return in, size, scanner.Err()
+}
+
+// benchFormats lists the formats that detect a line in the first lines of
+// any input, in detection order
+func benchFormats(inputs []benchInput) []string {
+	var names []string
+	for _, name := range parser.Names() {
+		p, _ := parser.Lookup(name)
+	inputs:
+		for _, in := range inputs {
+			for _, line := range in.lines[:min(len(in.lines), defaultSniffLines)] {
+				if p.Detect(line) {
+					names = append(names, name)
+					break inputs
+				}
+			}
+		}
+	}
+	return names
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
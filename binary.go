@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// binaryLines handles lines with binary content (-binary): NUL bytes,
+// control characters other than tab and escape, or invalid UTF-8, as found
+// in crash dumps and partially written files
+type binaryLines struct {
+	// mode is sanitize (escape the offending bytes), skip or keep
+	mode  string
+	count atomic.Int64
+}
+
+// filter returns the line to parse and whether to parse it at all
+func (b *binaryLines) filter(line string) (string, bool) {
+	if b.mode == "keep" || !isBinaryLine(line) {
+		return line, true
+	}
+	b.count.Add(1)
+	if b.mode == "skip" {
+		return "", false
+	}
+	return sanitizeLine(line), true
+}
+
+func isBinaryLine(line string) bool {
+	ascii := true
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c < 0x20 && c != '\t' && c != 0x1b || c == 0x7f {
+			return true
+		}
+		if c >= 0x80 {
+			ascii = false
+		}
+	}
+	return !ascii && !utf8.ValidString(line)
+}
+
+// sanitizeLine escapes control characters and invalid UTF-8 bytes as \xNN,
+// so they can't corrupt a terminal but remain visible
+func sanitizeLine(line string) string {
+	var b strings.Builder
+	b.Grow(len(line))
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		switch {
+		case r == utf8.RuneError && size == 1, r < 0x20 && r != '\t' && r != 0x1b, r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, line[i])
+		default:
+			b.WriteString(line[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// minBurstCount keeps sparse sources from reporting 3-lines-in-a-minute
+// "bursts" against an average of one
+const minBurstCount = 10
+
+// Burst is a run of minutes in which a source logged far more than usual
+type Burst struct {
+	Source  string
+	Start   time.Time
+	End     time.Time
+	Peak    int
+	Average float64
+}
+
+// findBursts reports, per source, runs of minutes whose volume exceeds
+// factor times that source's own per-minute average over its active span
+func findBursts(entries []LogEntry, factor float64) []Burst {
+	perMinute := make(map[string]map[int64]int)
+	locations := make(map[string]*time.Location)
+	for _, entry := range entries {
+		if entry.Timestamp.IsZero() {
+			continue
+		}
+		source := entry.Source
+		if source == "" {
+			source = "(no source)"
+		}
+		if perMinute[source] == nil {
+			perMinute[source] = make(map[int64]int)
+			locations[source] = entry.Timestamp.Location()
+		}
+		perMinute[source][entry.Timestamp.Truncate(time.Minute).Unix()]++
+	}
+
+	var bursts []Burst
+	for source, counts := range perMinute {
+		minutes := make([]int64, 0, len(counts))
+		total := 0
+		for m, c := range counts {
+			minutes = append(minutes, m)
+			total += c
+		}
+		sort.Slice(minutes, func(i, j int) bool { return minutes[i] < minutes[j] })
+
+		span := (minutes[len(minutes)-1]-minutes[0])/60 + 1
+		average := float64(total) / float64(span)
+
+		var current *Burst
+		for _, m := range minutes {
+			count := counts[m]
+			start := time.Unix(m, 0).In(locations[source])
+			if count < minBurstCount || float64(count) <= factor*average {
+				continue
+			}
+			if current != nil && current.End.Equal(start) {
+				current.End = start.Add(time.Minute)
+				if count > current.Peak {
+					current.Peak = count
+				}
+				continue
+			}
+			if current != nil {
+				bursts = append(bursts, *current)
+			}
+			current = &Burst{Source: source, Start: start, End: start.Add(time.Minute), Peak: count, Average: average}
+		}
+		if current != nil {
+			bursts = append(bursts, *current)
+		}
+	}
+
+	sort.Slice(bursts, func(i, j int) bool {
+		return bursts[i].Start.Before(bursts[j].Start)
+	})
+
+	return bursts
+}
+
+func (la *LogAnalyzer) showBursts(entries []LogEntry, factor float64) {
+	bursts := findBursts(entries, factor)
+
+	fmt.Printf("=== Bursts above %.1fx average per-minute volume ===\n", factor)
+	if len(bursts) == 0 {
+		fmt.Println("No bursts found")
+		return
+	}
+
+	for _, b := range bursts {
+		fmt.Printf("%-20s %s to %s  peak %d/min (avg %.1f/min, %.1fx)\n", b.Source,
+			b.Start.Format("2006-01-02 15:04"),
+			b.End.Format("15:04"),
+			b.Peak, b.Average, float64(b.Peak)/b.Average)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// cacheClass groups cache results: hits served from cache, stale content
+// served while the cache updates, misses that went to the origin, and the
+// rest (bypassed, errors)
+func cacheClass(status string) string {
+	switch status {
+	case "HIT", "REFRESHHIT", "REVALIDATED":
+		return "hit"
+	case "STALE", "UPDATING":
+		return "stale"
+	case "MISS", "EXPIRED":
+		return "miss"
+	}
+	return "other"
+}
+
+// cacheCounts counts the requests of one path prefix by cache class
+type cacheCounts struct {
+	total, hit, stale, miss, other int
+}
+
+func (c *cacheCounts) add(status string) {
+	c.total++
+	switch cacheClass(status) {
+	case "hit":
+		c.hit++
+	case "stale":
+		c.stale++
+	case "miss":
+		c.miss++
+	default:
+		c.other++
+	}
+}
+
+func (c *cacheCounts) print(label string) {
+	pct := func(n int) float64 { return 100 * float64(n) / float64(c.total) }
+	fmt.Printf("%-30s %8d %7.1f%% %7.1f%% %7.1f%% %7.1f%%\n", label, c.total, pct(c.hit), pct(c.stale), pct(c.miss), pct(c.other))
+}
+
+// pathPrefix returns the first segment of a request path: /static for
+// /static/app.js
+func pathPrefix(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	if i := strings.IndexByte(strings.TrimPrefix(path, "/"), '/'); i >= 0 {
+		return path[:i+1]
+	}
+	return path
+}
+
+// showCache reports the cache hit, stale and miss ratios of access log
+// requests that carry a cache status, overall and per path prefix
+func (la *LogAnalyzer) showCache(entries []LogEntry, top int) {
+	var overall cacheCounts
+	prefixes := make(map[string]*cacheCounts)
+	for _, entry := range entries {
+		a := entry.Access
+		if a == nil || a.CacheStatus == "" {
+			continue
+		}
+		overall.add(a.CacheStatus)
+		prefix := pathPrefix(a.Path)
+		if prefixes[prefix] == nil {
+			prefixes[prefix] = &cacheCounts{}
+		}
+		prefixes[prefix].add(a.CacheStatus)
+	}
+	if overall.total == 0 {
+		fmt.Println("No requests with a cache status (CloudFront logs, or nginx/varnish logs ending with the cache status)")
+		return
+	}
+
+	names := make([]string, 0, len(prefixes))
+	for name := range prefixes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if prefixes[names[i]].total != prefixes[names[j]].total {
+			return prefixes[names[i]].total > prefixes[names[j]].total
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Println("=== Cache Hit Ratio ===")
+	fmt.Printf("%-30s %8s %8s %8s %8s %8s\n", "Path", "Requests", "Hit", "Stale", "Miss", "Other")
+	overall.print("(all)")
+	for i, name := range names {
+		if i >= top {
+			fmt.Printf("... %d more prefixes\n", len(names)-top)
+			break
+		}
+		prefixes[name].print(name)
+	}
+}
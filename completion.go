@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/hrabid/log-analyzer/pkg/output"
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// completionWords are the second words of subcommands that take one
+var completionWords = map[string][]string{
+	"listen":     {"syslog", "tcp", "unix", "redis"},
+	"scan":       {"secrets"},
+	"detect":     {"format"},
+	"query":      {"save", "run", "list", "delete", "history"},
+	"completion": {"bash", "zsh", "fish"},
+}
+
+// completionCommand is the main command ("main"), a subcommand or a
+// subcommand with its second word ("listen syslog")
+type completionCommand struct {
+	name  string
+	flags []completionFlag
+	words []string
+}
+
+type completionFlag struct {
+	name  string
+	value bool
+	usage string
+}
+
+func init() {
+	subcommands["completion"] = runCompletion
+}
+
+// runCompletion prints a completion script for a shell. The hidden
+// `completion names formats|profiles|queries` lists the names the scripts
+// complete at run time, so formats, profiles and queries added to the
+// config later are offered without regenerating the script.
+func runCompletion(args []string) {
+	if len(args) == 2 && args[0] == "names" {
+		for _, name := range completionNames(args[1]) {
+			fmt.Println(name)
+		}
+		return
+	}
+	if len(args) != 1 || (args[0] != "bash" && args[0] != "zsh" && args[0] != "fish") {
+		fmt.Println("Usage: loganalyzer completion bash|zsh|fish")
+		fmt.Println()
+		fmt.Println("  bash: source <(loganalyzer completion bash)")
+		fmt.Println("  zsh:  source <(loganalyzer completion zsh)")
+		fmt.Println("  fish: loganalyzer completion fish > ~/.config/fish/completions/loganalyzer.fish")
+		os.Exit(1)
+	}
+
+	commands, err := completionCommands()
+	if err != nil {
+		log.Fatalf("Error reading flags: %v", err)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(w, commands)
+	case "zsh":
+		writeZshCompletion(w, commands)
+	case "fish":
+		writeFishCompletion(w, commands)
+	}
+}
+
+// completionNames lists formats (including config patterns), config
+// profiles or saved queries
+func completionNames(kind string) []string {
+	var names []string
+	switch kind {
+	case "formats":
+		if cfg, err := loadConfig(configPath()); err == nil {
+			cfg.registerPatterns()
+		}
+		names = append([]string{"auto"}, parser.Names()...)
+	case "profiles":
+		if cfg, err := loadConfig(configPath()); err == nil {
+			for name := range cfg.profiles {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	case "queries":
+		queries, _ := loadQueries()
+		for name := range queries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	return names
+}
+
+// completionCommands collects every command's flags. The flag sets are
+// built inside each subcommand, so they are read from the -h output of the
+// running binary rather than kept in a second list that could drift.
+func completionCommands() ([]completionCommand, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	commands := []completionCommand{{name: "main", words: names}}
+	for _, name := range names {
+		commands = append(commands, completionCommand{name: name, words: completionWords[name]})
+		if name == "completion" {
+			continue
+		}
+		for _, word := range completionWords[name] {
+			commands = append(commands, completionCommand{name: name + " " + word})
+		}
+	}
+
+	for i := range commands {
+		if strings.HasPrefix(commands[i].name, "completion") {
+			continue
+		}
+		args := []string{"-h"}
+		if commands[i].name != "main" {
+			args = append(strings.Fields(commands[i].name), "-h")
+		}
+		cmd := exec.Command(self, args...)
+		cmd.Env = append(os.Environ(), "LOGANALYZER_HISTORY=")
+		out, _ := cmd.CombinedOutput()
+		commands[i].flags = parseFlagHelp(out)
+	}
+	return commands, nil
+}
+
+// parseFlagHelp reads the flags from FlagSet.PrintDefaults output, where
+// each flag line is indented two spaces and a type after the name
+// ("-bucket duration") means the flag takes a value. The usage follows on
+// the same line after a tab or on the next line.
+func parseFlagHelp(help []byte) []completionFlag {
+	var flags []completionFlag
+	inDefaults := false
+	lines := strings.Split(string(help), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "Usage of ") {
+			inDefaults = true
+			continue
+		}
+		if !inDefaults || !strings.HasPrefix(line, "  -") {
+			continue
+		}
+		head, usage, _ := strings.Cut(line[3:], "\t")
+		fields := strings.Fields(head)
+		if len(fields) == 0 {
+			continue
+		}
+		if usage == "" && i+1 < len(lines) {
+			usage = lines[i+1]
+		}
+		flags = append(flags, completionFlag{name: fields[0], value: len(fields) > 1, usage: strings.TrimSpace(usage)})
+	}
+	return flags
+}
+
+func (c completionCommand) flagNames() string {
+	names := make([]string, len(c.flags))
+	for i, f := range c.flags {
+		names[i] = "-" + f.name
+	}
+	return strings.Join(names, " ")
+}
+
+// completionCase lists the commands with a second word as shell case
+// patterns: "listen syslog"|"listen tcp"|...
+func completionCase(commands []completionCommand) string {
+	var patterns []string
+	for _, c := range commands {
+		if strings.Contains(c.name, " ") {
+			patterns = append(patterns, `"`+c.name+`"`)
+		}
+	}
+	return strings.Join(patterns, "|")
+}
+
+// passesMainFlags reports whether a command hands its options to the main
+// command, so it completes the main command's flags too
+func passesMainFlags(name string) bool {
+	return name == "query run" || name == "query save"
+}
+
+func writeBashCompletion(w io.Writer, commands []completionCommand) {
+	top := commands[0]
+	fmt.Fprintln(w, "# bash completion for loganalyzer; load with")
+	fmt.Fprintln(w, "#   source <(loganalyzer completion bash)")
+	fmt.Fprintln(w, "_loganalyzer() {")
+	fmt.Fprintln(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} self=${COMP_WORDS[0]}")
+	fmt.Fprintln(w, "\tcase $prev in")
+	fmt.Fprintln(w, "\t-format|-from) COMPREPLY=($(compgen -W \"$($self completion names formats 2>/dev/null)\" -- \"$cur\")); return ;;")
+	fmt.Fprintln(w, "\t-profile) COMPREPLY=($(compgen -W \"$($self completion names profiles 2>/dev/null)\" -- \"$cur\")); return ;;")
+	fmt.Fprintf(w, "\t-output) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(output.Formats, " "))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\tlocal cmd=main")
+	fmt.Fprintln(w, "\tif (( COMP_CWORD > 1 )); then")
+	fmt.Fprintln(w, "\t\tcase ${COMP_WORDS[1]} in")
+	fmt.Fprintf(w, "\t\t%s) cmd=${COMP_WORDS[1]} ;;\n", strings.Join(top.words, "|"))
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "\tif (( COMP_CWORD > 2 )); then")
+	fmt.Fprintln(w, "\t\tcase \"$cmd ${COMP_WORDS[2]}\" in")
+	fmt.Fprintf(w, "\t\t%s) cmd=\"$cmd ${COMP_WORDS[2]}\" ;;\n", completionCase(commands))
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\tlocal flags=\"\" words=\"\"")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	for _, c := range commands {
+		flags := c.flagNames()
+		if passesMainFlags(c.name) {
+			flags = strings.TrimSpace(flags + " " + top.flagNames())
+		}
+		fmt.Fprintf(w, "\t%q)\n", c.name)
+		if flags != "" {
+			fmt.Fprintf(w, "\t\tflags=%q\n", flags)
+		}
+		words := strings.Join(c.words, " ")
+		position := len(strings.Fields(c.name)) + 1
+		if c.name == "main" {
+			position = 1
+		}
+		if c.name == "query run" || c.name == "query delete" {
+			words = "$($self completion names queries 2>/dev/null)"
+		}
+		if words != "" {
+			fmt.Fprintf(w, "\t\t(( COMP_CWORD == %d )) && words=\"%s\"\n", position, words)
+		}
+		fmt.Fprintln(w, "\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\tif [[ $cur == -* ]]; then")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))")
+	fmt.Fprintln(w, "\telif [[ -n $words ]]; then")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))")
+	fmt.Fprintln(w, "\telse")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o filenames -F _loganalyzer loganalyzer")
+}
+
+func writeZshCompletion(w io.Writer, commands []completionCommand) {
+	top := commands[0]
+	fmt.Fprintln(w, "#compdef loganalyzer")
+	fmt.Fprintln(w, "# zsh completion for loganalyzer; load with")
+	fmt.Fprintln(w, "#   source <(loganalyzer completion zsh)")
+	fmt.Fprintln(w, "_loganalyzer() {")
+	fmt.Fprintln(w, "\tlocal cur=${words[CURRENT]} prev=${words[CURRENT-1]} self=${words[1]}")
+	fmt.Fprintln(w, "\tcase $prev in")
+	fmt.Fprintln(w, "\t-format|-from) compadd -- ${(f)\"$($self completion names formats 2>/dev/null)\"}; return ;;")
+	fmt.Fprintln(w, "\t-profile) compadd -- ${(f)\"$($self completion names profiles 2>/dev/null)\"}; return ;;")
+	fmt.Fprintf(w, "\t-output) compadd -- %s; return ;;\n", strings.Join(output.Formats, " "))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\tlocal cmd=main")
+	fmt.Fprintln(w, "\tif (( CURRENT > 2 )); then")
+	fmt.Fprintln(w, "\t\tcase ${words[2]} in")
+	fmt.Fprintf(w, "\t\t%s) cmd=${words[2]} ;;\n", strings.Join(top.words, "|"))
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "\tif (( CURRENT > 3 )); then")
+	fmt.Fprintln(w, "\t\tcase \"$cmd ${words[3]}\" in")
+	fmt.Fprintf(w, "\t\t%s) cmd=\"$cmd ${words[3]}\" ;;\n", completionCase(commands))
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\tlocal -a flags candidates")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	for _, c := range commands {
+		flags := c.flagNames()
+		if passesMainFlags(c.name) {
+			flags = strings.TrimSpace(flags + " " + top.flagNames())
+		}
+		fmt.Fprintf(w, "\t%q)\n", c.name)
+		if flags != "" {
+			fmt.Fprintf(w, "\t\tflags=(%s)\n", flags)
+		}
+		position := len(strings.Fields(c.name)) + 2
+		if c.name == "main" {
+			position = 2
+		}
+		words := strings.Join(c.words, " ")
+		if c.name == "query run" || c.name == "query delete" {
+			words = "${(f)\"$($self completion names queries 2>/dev/null)\"}"
+		}
+		if words != "" {
+			fmt.Fprintf(w, "\t\t(( CURRENT == %d )) && candidates=(%s)\n", position, words)
+		}
+		fmt.Fprintln(w, "\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "\tif [[ $cur == -* ]]; then")
+	fmt.Fprintln(w, "\t\tcompadd -- $flags")
+	fmt.Fprintln(w, "\telif (( $#candidates )); then")
+	fmt.Fprintln(w, "\t\tcompadd -- $candidates")
+	fmt.Fprintln(w, "\telse")
+	fmt.Fprintln(w, "\t\t_files")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "compdef _loganalyzer loganalyzer")
+}
+
+// fishQuote single-quotes s for fish, which only escapes \ and '
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func writeFishCompletion(w io.Writer, commands []completionCommand) {
+	top := commands[0]
+	fmt.Fprintln(w, "# fish completion for loganalyzer; install with")
+	fmt.Fprintln(w, "#   loganalyzer completion fish > ~/.config/fish/completions/loganalyzer.fish")
+	fmt.Fprintln(w, "function __loganalyzer_cmd")
+	fmt.Fprintln(w, "\tset -l words (commandline -opc)")
+	fmt.Fprintln(w, "\tset -l cmd main")
+	fmt.Fprintf(w, "\tif test (count $words) -ge 2; and contains -- $words[2] %s\n", strings.Join(top.words, " "))
+	fmt.Fprintln(w, "\t\tset cmd $words[2]")
+	var seconds []string
+	for _, c := range commands {
+		if strings.Contains(c.name, " ") {
+			seconds = append(seconds, fishQuote(c.name))
+		}
+	}
+	fmt.Fprintf(w, "\t\tif test (count $words) -ge 3; and contains -- \"$cmd $words[3]\" %s\n", strings.Join(seconds, " "))
+	fmt.Fprintln(w, "\t\t\tset cmd \"$cmd $words[3]\"")
+	fmt.Fprintln(w, "\t\tend")
+	fmt.Fprintln(w, "\tend")
+	fmt.Fprintln(w, "\techo $cmd")
+	fmt.Fprintln(w, "end")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "function __loganalyzer_is")
+	fmt.Fprintln(w, "\ttest (__loganalyzer_cmd) = \"$argv\"")
+	fmt.Fprintln(w, "end")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "function __loganalyzer_at")
+	fmt.Fprintln(w, "\ttest (count (commandline -opc)) -eq $argv[1]")
+	fmt.Fprintln(w, "end")
+	fmt.Fprintln(w)
+
+	values := map[string]string{
+		"format":  "(loganalyzer completion names formats 2>/dev/null)",
+		"from":    "(loganalyzer completion names formats 2>/dev/null)",
+		"profile": "(loganalyzer completion names profiles 2>/dev/null)",
+		"output":  strings.Join(output.Formats, " "),
+	}
+	for _, c := range commands {
+		cond := fishQuote("__loganalyzer_is " + c.name)
+		words := strings.Join(c.words, " ")
+		if c.name == "query run" || c.name == "query delete" {
+			words = "(loganalyzer completion names queries 2>/dev/null)"
+		}
+		if words != "" {
+			position := len(strings.Fields(c.name))
+			if c.name == "main" {
+				position = 1
+			}
+			at := fishQuote(fmt.Sprintf("__loganalyzer_is %s; and __loganalyzer_at %d", c.name, position))
+			fmt.Fprintf(w, "complete -c loganalyzer -n %s -f -a %s\n", at, fishQuote(words))
+		}
+
+		flags := c.flags
+		if passesMainFlags(c.name) {
+			flags = append(append([]completionFlag(nil), flags...), top.flags...)
+		}
+		for _, f := range flags {
+			line := fmt.Sprintf("complete -c loganalyzer -n %s -o %s", cond, f.name)
+			if v, ok := values[f.name]; ok {
+				line += " -x -a " + fishQuote(v)
+			} else if f.value {
+				line += " -r"
+			}
+			if f.usage != "" {
+				line += " -d " + fishQuote(f.usage)
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// configPath is $LOGANALYZER_CONFIG, or loganalyzer/config.yaml in the user
+// config directory (~/.config on Linux)
+func configPath() string {
+	if path := os.Getenv("LOGANALYZER_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "loganalyzer", "config.yaml")
+}
+
+// config is the parsed config file:
+//
+//	defaults:            flag values used when a flag isn't given
+//	  format: nginx
+//	profiles:            named sets of flag values selected with -profile
+//	  nginx-prod:
+//	    f: [/var/log/nginx/access.log, /var/log/nginx/error.log]
+//	    level: ERROR
+//	    output: json
+//	patterns:            custom formats usable with -format
+//	  myapp:
+//	    regex: '^(?P<timestamp>\S+ \S+) (?P<level>\w+) (?P<message>.*)'
+//	    time: "2006-01-02 15:04:05"
+//	  java:
+//	    log4j: '%d{ISO8601} [%t] %-5p %c - %m%n'
+//
+// Flag values are keyed by flag name; a [a, b] list sets a repeatable flag
+// once per item.
+type config struct {
+	defaults map[string]string
+	profiles map[string]map[string]string
+	patterns map[string]map[string]string
+}
+
+// loadConfig reads the config file; a missing file is an empty config
+func loadConfig(path string) (*config, error) {
+	cfg := &config{
+		defaults: make(map[string]string),
+		profiles: make(map[string]map[string]string),
+		patterns: make(map[string]map[string]string),
+	}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := parseConfigYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for key, value := range values {
+		parts := strings.SplitN(key, ".", 3)
+		switch {
+		case parts[0] == "defaults" && len(parts) == 2:
+			cfg.defaults[parts[1]] = value
+		case parts[0] == "profiles" && len(parts) == 3:
+			if cfg.profiles[parts[1]] == nil {
+				cfg.profiles[parts[1]] = make(map[string]string)
+			}
+			cfg.profiles[parts[1]][parts[2]] = value
+		case parts[0] == "patterns" && len(parts) == 3:
+			if cfg.patterns[parts[1]] == nil {
+				cfg.patterns[parts[1]] = make(map[string]string)
+			}
+			cfg.patterns[parts[1]][parts[2]] = value
+		default:
+			return nil, fmt.Errorf("%s: unexpected key %s", path, key)
+		}
+	}
+	return cfg, nil
+}
+
+// parseConfigYAML flattens nested maps into dotted keys
+// ("profiles.web.level"). Like the rules parser it handles the subset of
+// YAML the config uses: no lists of maps, anchors or multi-line strings.
+func parseConfigYAML(data []byte) (map[string]string, error) {
+	type parent struct {
+		indent int
+		key    string
+	}
+	values := make(map[string]string)
+	var parents []parent
+
+	scanner := new(longLines).newScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := stripYAMLComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if strings.Contains(line[:indent], "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", lineNum)
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || strings.Contains(key, ".") {
+			return nil, fmt.Errorf("line %d: expected key: value", lineNum)
+		}
+
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		if value == "" {
+			parents = append(parents, parent{indent: indent, key: key})
+			continue
+		}
+
+		path := make([]string, 0, len(parents)+1)
+		for _, p := range parents {
+			path = append(path, p.key)
+		}
+		values[strings.Join(append(path, key), ".")] = value
+	}
+	return values, scanner.Err()
+}
+
+// configList splits a [a, b] list value; anything else is a single value
+func configList(value string) []string {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return []string{unquoteYAML(value)}
+	}
+	var items []string
+	for _, item := range strings.Split(value[1:len(value)-1], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, unquoteYAML(item))
+		}
+	}
+	return items
+}
+
+// registerPatterns adds the config's custom formats to the parser registry
+func (c *config) registerPatterns() error {
+	for name, spec := range c.patterns {
+		if _, taken := parser.Lookup(name); taken {
+			return fmt.Errorf("pattern %s: format already exists", name)
+		}
+		if layout, ok := spec["log4j"]; ok {
+			p, err := parser.NewLog4jParser(unquoteYAML(layout))
+			if err != nil {
+				return fmt.Errorf("pattern %s: %v", name, err)
+			}
+			parser.Register(name, p)
+			continue
+		}
+		re, err := regexp.Compile(unquoteYAML(spec["regex"]))
+		if err != nil {
+			return fmt.Errorf("pattern %s: %v", name, err)
+		}
+		parser.Register(name, parser.NewRegexParser(re, unquoteYAML(spec["time"])))
+	}
+	return nil
+}
+
+// apply sets the flags not given on the command line from the defaults and
+// then the named profile. Keys naming flags fs doesn't have are skipped, so
+// defaults can hold flags of several subcommands.
+func (c *config) apply(fs *flag.FlagSet, profile string) error {
+	values := make(map[string]string)
+	for k, v := range c.defaults {
+		values[k] = v
+	}
+	if profile != "" {
+		p, ok := c.profiles[profile]
+		if !ok {
+			return fmt.Errorf("no profile %q in %s", profile, configPath())
+		}
+		for k, v := range p {
+			values[k] = v
+		}
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if given[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		for _, item := range configList(values[name]) {
+			if err := fs.Set(name, item); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseFlags parses the command line of the main command or a subcommand
+// and fills in the config file's defaults and the -profile it selects. The
+// Go profiling flags every command takes are started here.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	profile := fs.String("profile", "", "Apply a named profile from the config file ($LOGANALYZER_CONFIG or ~/.config/loganalyzer/config.yaml)")
+	cpuProfile := fs.String("cpuprofile", "", "Write a CPU profile of the run to this file, for go tool pprof")
+	memProfile := fs.String("memprofile", "", "Write a heap profile to this file when the run ends")
+	pprofListen := fs.String("pprof-listen", "", "Serve live profiles (net/http/pprof) on this address, e.g. localhost:6060")
+	fs.Parse(args)
+
+	cfg, err := loadConfig(configPath())
+	if err == nil {
+		err = cfg.registerPatterns()
+	}
+	if err == nil {
+		err = cfg.apply(fs, *profile)
+	}
+	if err != nil {
+		log.Fatalf("Error in config: %v", err)
+	}
+	if err := profiles.start(*cpuProfile, *memProfile, *pprofListen); err != nil {
+		log.Fatalf("Error starting profiling: %v", err)
+	}
+}
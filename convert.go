@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hrabid/log-analyzer/pkg/output"
+)
+
+// runConvert re-emits parsed entries in another format, streaming so it can
+// sit in a pipeline as a normalizer
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	input := addInputFlags(fs)
+	fs.StringVar(input.format, "from", "auto", "Input format (alias of -format)")
+	to := fs.String("to", "ndjson", "Output format ("+strings.Join(output.Formats, ", ")+")")
+	verbose := fs.Bool("v", false, "Verbose text output")
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 {
+		input.files = fileList{"-"}
+	}
+
+	valid := false
+	for _, f := range output.Formats {
+		valid = valid || f == *to
+	}
+	if !valid {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q (%s)\n", *to, strings.Join(output.Formats, ", "))
+		os.Exit(1)
+	}
+
+	analyzer := input.newAnalyzer()
+	w := output.NewWriter(os.Stdout, *to, *verbose)
+	for _, filename := range input.files {
+		err := analyzer.scanFile(filename, *input.format, func(entry *LogEntry) {
+			if !analyzer.matchesFilters(*entry) {
+				return
+			}
+			analyzer.resolveHostnames([]LogEntry{*entry})
+			if err := w.Write(*entry); err != nil {
+				log.Fatalf("Error writing output: %v", err)
+			}
+		})
+		if err != nil {
+			log.Fatalf("Error parsing file: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalf("Error writing output: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// derivation computes a new entry field from a -derive expression of the
+// form name=func(args...), where args are field names or quoted strings:
+//
+//	extract(field, "regex")      first capture group (or whole match)
+//	scan(field, "took %dms")     the %d, %f or %s placeholder's value
+//	duration(field, "took %s")   like scan, converted to milliseconds from
+//	                             a Go duration (1.5s, 250ms) or bare number
+//	lower(field), upper(field), len(field)
+//	field                        a copy of another field
+type derivation struct {
+	name string
+	fn   func(entry *LogEntry) (string, bool)
+}
+
+var deriveName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseDerivation compiles one -derive expression
+func parseDerivation(expr string) (derivation, error) {
+	name, body, ok := strings.Cut(expr, "=")
+	name, body = strings.TrimSpace(name), strings.TrimSpace(body)
+	if !ok || !deriveName.MatchString(name) {
+		return derivation{}, fmt.Errorf("%q: want name=expression", expr)
+	}
+	if _, builtin := (&LogEntry{}).Field(name); builtin {
+		return derivation{}, fmt.Errorf("%q: %s is a built-in field", expr, name)
+	}
+
+	fname, args, err := parseCall(body)
+	if err != nil {
+		return derivation{}, fmt.Errorf("%q: %v", expr, err)
+	}
+	fn, err := deriveFunc(fname, args)
+	if err != nil {
+		return derivation{}, fmt.Errorf("%q: %v", expr, err)
+	}
+	return derivation{name: name, fn: fn}, nil
+}
+
+// deriveArg is a function argument: a field reference or a string literal
+type deriveArg struct {
+	field   string
+	literal string
+	quoted  bool
+}
+
+// parseCall splits `fn(a, "b")` into the function name and its arguments;
+// a bare field name is returned as a call to "field"
+func parseCall(body string) (string, []deriveArg, error) {
+	open := strings.IndexByte(body, '(')
+	if open < 0 {
+		if !deriveName.MatchString(body) {
+			return "", nil, fmt.Errorf("invalid field %q", body)
+		}
+		return "field", []deriveArg{{field: body}}, nil
+	}
+	if !strings.HasSuffix(body, ")") {
+		return "", nil, fmt.Errorf("missing closing parenthesis")
+	}
+	fname := strings.TrimSpace(body[:open])
+	rest := strings.TrimSpace(body[open+1 : len(body)-1])
+
+	var args []deriveArg
+	for rest != "" {
+		var arg deriveArg
+		if rest[0] == '"' {
+			lit, n, err := quotedPrefix(rest)
+			if err != nil {
+				return "", nil, fmt.Errorf("%v in %s()", err, fname)
+			}
+			arg.literal, arg.quoted = lit, true
+			rest = rest[n:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			arg.field = strings.TrimSpace(rest[:end])
+			if !deriveName.MatchString(arg.field) {
+				return "", nil, fmt.Errorf("invalid argument %q to %s()", arg.field, fname)
+			}
+			rest = rest[end:]
+		}
+		args = append(args, arg)
+
+		rest = strings.TrimSpace(rest)
+		if rest != "" {
+			if rest[0] != ',' {
+				return "", nil, fmt.Errorf("expected , between arguments to %s()", fname)
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+	return fname, args, nil
+}
+
+// quotedPrefix reads the double-quoted string s starts with, returning its
+// content and length. Only \" is an escape, so regexes keep their
+// backslashes: "GET (\S+)".
+func quotedPrefix(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '"':
+			b.WriteByte('"')
+			i++
+		case s[i] == '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// scanPattern turns a scanf-style pattern into a regex capturing the first
+// placeholder
+func scanPattern(pattern string) (*regexp.Regexp, error) {
+	placeholders := map[byte]string{'d': `(-?\d+)`, 'f': `(-?\d+(?:\.\d+)?)`, 's': `(\S+)`}
+	var re strings.Builder
+	captured := false
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '%' && i+1 < len(pattern) {
+			if group, ok := placeholders[pattern[i+1]]; ok {
+				if captured {
+					group = strings.Replace(group, "(", "(?:", 1)
+				}
+				re.WriteString(group)
+				captured = true
+				i++
+				continue
+			}
+		}
+		re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+	}
+	if !captured {
+		return nil, fmt.Errorf("pattern %q has no %%d, %%f or %%s", pattern)
+	}
+	return regexp.Compile(re.String())
+}
+
+// deriveFunc builds the function for a call, checking its arguments
+func deriveFunc(name string, args []deriveArg) (func(*LogEntry) (string, bool), error) {
+	wantArgs := map[string]int{"field": 1, "lower": 1, "upper": 1, "len": 1, "extract": 2, "scan": 2, "duration": 2}
+	n, known := wantArgs[name]
+	if !known {
+		return nil, fmt.Errorf("unknown function %s()", name)
+	}
+	if len(args) != n || args[0].quoted || (n == 2 && !args[1].quoted) {
+		if n == 1 {
+			return nil, fmt.Errorf("%s() takes a field name", name)
+		}
+		return nil, fmt.Errorf("%s() takes a field name and a quoted pattern", name)
+	}
+	field := args[0].field
+
+	switch name {
+	case "field":
+		return func(e *LogEntry) (string, bool) { return e.Field(field) }, nil
+	case "lower", "upper", "len":
+		return func(e *LogEntry) (string, bool) {
+			v, ok := e.Field(field)
+			if !ok {
+				return "", false
+			}
+			switch name {
+			case "lower":
+				return strings.ToLower(v), true
+			case "upper":
+				return strings.ToUpper(v), true
+			}
+			return strconv.Itoa(len(v)), true
+		}, nil
+	}
+
+	var re *regexp.Regexp
+	var err error
+	if name == "extract" {
+		re, err = regexp.Compile(args[1].literal)
+	} else {
+		re, err = scanPattern(args[1].literal)
+	}
+	if err != nil {
+		return nil, err
+	}
+	match := func(e *LogEntry) (string, bool) {
+		v, ok := e.Field(field)
+		if !ok {
+			return "", false
+		}
+		m := re.FindStringSubmatch(v)
+		if m == nil {
+			return "", false
+		}
+		if len(m) > 1 {
+			return m[1], true
+		}
+		return m[0], true
+	}
+	if name != "duration" {
+		return match, nil
+	}
+
+	return func(e *LogEntry) (string, bool) {
+		v, ok := match(e)
+		if !ok {
+			return "", false
+		}
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return v, true
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), true
+	}, nil
+}
+
+// applyDerivations sets the -derive fields an entry has values for; later
+// expressions can use the fields derived before them
+func (la *LogAnalyzer) applyDerivations(entry *LogEntry) {
+	for _, d := range la.derived {
+		if v, ok := d.fn(entry); ok {
+			entry.SetField(d.name, v)
+		}
+	}
+}
+
+// derives reports whether name is a -derive field
+func (la *LogAnalyzer) derives(name string) bool {
+	for _, d := range la.derived {
+		if d.name == name {
+			return true
+		}
+	}
+	return false
+}
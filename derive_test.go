@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestParseDerivation(t *testing.T) {
+	entry := &LogEntry{
+		Message: "GET /orders took 1.5s (cache MISS)",
+		Source:  "API",
+		Fields:  map[string]interface{}{"path": "/t/acme/orders", "elapsed": "250", "retries": 2.0},
+	}
+	tests := []struct {
+		expr string
+		want string
+		ok   bool
+	}{
+		{`tenant=extract(path, "^/t/(\w+)/")`, "acme", true},
+		{`verb=extract(message, "^[A-Z]+")`, "GET", true},
+		{`cache=scan(message, "cache %s)")`, "MISS", true},
+		{`latency_ms=duration(message, "took %s ")`, "1500", true},
+		{`elapsed_ms=duration(elapsed, "%s")`, "250", true},
+		{`src=lower(source)`, "api", true},
+		{`src=upper(path)`, "/T/ACME/ORDERS", true},
+		{`n=len(path)`, "14", true},
+		{`tries=retries`, "2", true},
+		{`tenant=extract(path, "^/x/(\w+)")`, "", false},
+		{`tenant=extract(missing, ".")`, "", false},
+		{`latency_ms=duration(message, "GET %s ")`, "", false},
+	}
+	for _, tt := range tests {
+		d, err := parseDerivation(tt.expr)
+		if err != nil {
+			t.Errorf("parseDerivation(%q): %v", tt.expr, err)
+			continue
+		}
+		got, ok := d.fn(entry)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s = %q, %v, want %q, %v", tt.expr, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseDerivationErrors(t *testing.T) {
+	for _, expr := range []string{
+		`extract(path, "x")`,
+		`1bad=lower(path)`,
+		`level=lower(message)`,
+		`x=nope(path)`,
+		`x=lower("path")`,
+		`x=extract(path, x)`,
+		`x=extract(path)`,
+		`x=extract(path, "(")`,
+		`x=lower(path`,
+	} {
+		if _, err := parseDerivation(expr); err == nil {
+			t.Errorf("parseDerivation(%q) succeeded", expr)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// madScale converts a median absolute deviation into a standard deviation
+// estimate for normally distributed data
+const madScale = 1.4826
+
+// Anomaly is a run of consecutive buckets where a metric deviated from its
+// rolling baseline
+type Anomaly struct {
+	Metric   string
+	Start    time.Time
+	End      time.Time
+	Observed int
+	Baseline float64
+	Score    float64
+}
+
+// runDetect reports volume and error anomalies; `detect format` reports
+// how the inputs' formats are detected instead
+func runDetect(args []string) {
+	if len(args) > 0 && args[0] == "format" {
+		runDetectFormat(args[1:])
+		return
+	}
+	fs := flag.NewFlagSet("detect", flag.ExitOnError)
+	input := addInputFlags(fs)
+	bucket := fs.Duration("bucket", time.Minute, "Time bucket width")
+	window := fs.Int("window", 30, "Number of preceding buckets used as the baseline")
+	threshold := fs.Float64("threshold", 3.5, "Robust z-score above which a bucket is anomalous")
+	parseFlags(fs, args)
+	if *bucket <= 0 {
+		log.Fatal("-bucket must be positive")
+	}
+
+	_, entries := input.load()
+	if n := bucketCount(entries, *bucket); n > maxBuckets {
+		log.Fatalf("-bucket %s makes %d buckets over the time range, more than %d; use a wider bucket", *bucket, n, maxBuckets)
+	}
+	buckets := bucketEntries(entries, *bucket)
+
+	volume := make([]float64, len(buckets))
+	errors := make([]float64, len(buckets))
+	for i, b := range buckets {
+		volume[i] = float64(b.Total)
+		errors[i] = float64(b.Errors)
+	}
+
+	var anomalies []Anomaly
+	anomalies = append(anomalies, detectAnomalies("volume", buckets, volume, *bucket, *window, *threshold)...)
+	anomalies = append(anomalies, detectAnomalies("errors", buckets, errors, *bucket, *window, *threshold)...)
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Start.Before(anomalies[j].Start)
+	})
+
+	fmt.Printf("=== Anomalies (%d buckets of %s, window %d, threshold %.1f) ===\n",
+		len(buckets), *bucket, *window, *threshold)
+	if len(anomalies) == 0 {
+		fmt.Println("No anomalies detected")
+		return
+	}
+
+	for _, a := range anomalies {
+		direction := "spike"
+		if a.Score < 0 {
+			direction = "drop"
+		}
+		fmt.Printf("%s to %s  %-6s %-5s observed %d vs baseline %.1f (score %+.1f)\n",
+			a.Start.Format("2006-01-02 15:04:05"),
+			a.End.Format("15:04:05"),
+			a.Metric, direction, a.Observed, a.Baseline, a.Score)
+	}
+}
+
+// detectAnomalies scores each bucket against the median and MAD of the
+// preceding window and merges consecutive outliers into one anomaly
+func detectAnomalies(metric string, buckets []TimeBucket, values []float64, width time.Duration, window int, threshold float64) []Anomaly {
+	var anomalies []Anomaly
+	var current *Anomaly
+
+	minHistory := window / 2
+	if minHistory < 3 {
+		minHistory = 3
+	}
+
+	for i, value := range values {
+		lo := i - window
+		if lo < 0 {
+			lo = 0
+		}
+		history := values[lo:i]
+		if len(history) < minHistory {
+			continue
+		}
+
+		med := median(history)
+		deviations := make([]float64, len(history))
+		for j, h := range history {
+			deviations[j] = math.Abs(h - med)
+		}
+		// A perfectly flat baseline has a MAD of zero; require at least a
+		// deviation of one event so a single extra line isn't an anomaly
+		spread := math.Max(madScale*median(deviations), 1)
+		score := (value - med) / spread
+
+		if math.Abs(score) < threshold {
+			if current != nil {
+				anomalies = append(anomalies, *current)
+				current = nil
+			}
+			continue
+		}
+
+		if current != nil && (score > 0) == (current.Score > 0) {
+			current.End = buckets[i].Start.Add(width)
+			if math.Abs(score) > math.Abs(current.Score) {
+				current.Score = score
+				current.Observed = int(value)
+				current.Baseline = med
+			}
+			continue
+		}
+
+		if current != nil {
+			anomalies = append(anomalies, *current)
+		}
+		current = &Anomaly{
+			Metric:   metric,
+			Start:    buckets[i].Start,
+			End:      buckets[i].Start.Add(width),
+			Observed: int(value),
+			Baseline: med,
+			Score:    score,
+		}
+	}
+	if current != nil {
+		anomalies = append(anomalies, *current)
+	}
+
+	return anomalies
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
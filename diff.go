@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+	"github.com/hrabid/log-analyzer/pkg/stats"
+)
+
+// LogSummary is a digest of a set of entries that can be compared against
+// another period or file
+type LogSummary struct {
+	Entries      int
+	First        time.Time
+	Last         time.Time
+	Levels       map[string]int
+	Errors       map[string]int
+	HourlyVolume [24]int
+}
+
+// ErrorRate returns the percentage of entries that are errors
+func (s *LogSummary) ErrorRate() float64 {
+	if s.Entries == 0 {
+		return 0
+	}
+	return float64(s.Levels["ERROR"]+s.Levels["FATAL"]) * 100 / float64(s.Entries)
+}
+
+// summarize builds a LogSummary; errors are keyed by normalized message so
+// the keys are stable across runs and files
+func summarize(entries []LogEntry) *LogSummary {
+	s := &LogSummary{
+		Levels: make(map[string]int),
+		Errors: make(map[string]int),
+	}
+
+	for _, entry := range entries {
+		s.Entries++
+		s.Levels[entry.Level]++
+		if parser.IsError(entry.Level) {
+			s.Errors[stats.NormalizeMessage(entry.Message)]++
+		}
+
+		if entry.Timestamp.IsZero() {
+			continue
+		}
+		s.HourlyVolume[entry.Timestamp.Hour()]++
+		if s.First.IsZero() || entry.Timestamp.Before(s.First) {
+			s.First = entry.Timestamp
+		}
+		if s.Last.IsZero() || entry.Timestamp.After(s.Last) {
+			s.Last = entry.Timestamp
+		}
+	}
+
+	return s
+}
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	input := addInputFlags(fs)
+	var baselineFiles fileList
+	fs.Var(&baselineFiles, "f2", "Log file to compare against (repeatable; defaults to the -f files)")
+	start2 := fs.String("start2", "", "Start of the comparison window (YYYY-MM-DD HH:MM:SS)")
+	end2 := fs.String("end2", "", "End of the comparison window (YYYY-MM-DD HH:MM:SS)")
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 || (len(baselineFiles) == 0 && *start2 == "" && *end2 == "") {
+		fmt.Println("Usage: loganalyzer diff -f today.log -f2 yesterday.log [options]")
+		fmt.Println("       loganalyzer diff -f app.log -start ... -end ... -start2 ... -end2 ...")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	if len(baselineFiles) == 0 {
+		baselineFiles = input.files
+	}
+
+	_, currentEntries := input.load()
+
+	baseline := input.newAnalyzer()
+	if *start2 != "" || *end2 != "" {
+		baseline.filters.StartTime = parseTimeFilter(*start2, "start2")
+		baseline.filters.EndTime = parseTimeFilter(*end2, "end2")
+	}
+	for _, filename := range baselineFiles {
+		if err := baseline.parseFile(filename, *input.format); err != nil {
+			log.Fatalf("Error parsing file: %v", err)
+		}
+	}
+
+	printSummaryDiff(summarize(baseline.filterEntries()), summarize(currentEntries))
+}
+
+// printSummaryDiff compares a baseline summary (B) with a current one (A)
+func printSummaryDiff(b, a *LogSummary) {
+	fmt.Println("=== Log Diff (A = current, B = baseline) ===")
+	fmt.Printf("%-12s %10s %10s %10s\n", "", "B", "A", "Change")
+	printDiffRow("Entries", b.Entries, a.Entries)
+	for _, level := range []string{"FATAL", "ERROR", "WARN", "INFO", "DEBUG", "TRACE"} {
+		printDiffRow(level, b.Levels[level], a.Levels[level])
+	}
+	fmt.Printf("%-12s %9.2f%% %9.2f%% %+9.2f%%\n", "Error rate", b.ErrorRate(), a.ErrorRate(), a.ErrorRate()-b.ErrorRate())
+	fmt.Println()
+
+	var appeared, disappeared, changed []string
+	for msg := range a.Errors {
+		if _, ok := b.Errors[msg]; ok {
+			changed = append(changed, msg)
+		} else {
+			appeared = append(appeared, msg)
+		}
+	}
+	for msg := range b.Errors {
+		if _, ok := a.Errors[msg]; !ok {
+			disappeared = append(disappeared, msg)
+		}
+	}
+
+	sortByCount(appeared, a.Errors)
+	sortByCount(disappeared, b.Errors)
+	sort.Slice(changed, func(i, j int) bool {
+		di := abs(a.Errors[changed[i]] - b.Errors[changed[i]])
+		dj := abs(a.Errors[changed[j]] - b.Errors[changed[j]])
+		if di != dj {
+			return di > dj
+		}
+		return changed[i] < changed[j]
+	})
+
+	fmt.Printf("New Errors (%d):\n", len(appeared))
+	for i, msg := range appeared {
+		if i >= 10 {
+			break
+		}
+		fmt.Printf("  + %s: %d\n", msg, a.Errors[msg])
+	}
+	fmt.Println()
+
+	fmt.Printf("Resolved Errors (%d):\n", len(disappeared))
+	for i, msg := range disappeared {
+		if i >= 10 {
+			break
+		}
+		fmt.Printf("  - %s: %d\n", msg, b.Errors[msg])
+	}
+	fmt.Println()
+
+	fmt.Println("Largest Changes in Recurring Errors:")
+	for i, msg := range changed {
+		if i >= 10 {
+			break
+		}
+		fmt.Printf("  %s: %d -> %d\n", msg, b.Errors[msg], a.Errors[msg])
+	}
+	fmt.Println()
+
+	fmt.Println("Volume by Hour of Day:")
+	fmt.Printf("  %-5s %10s %10s\n", "Hour", "B", "A")
+	for hour := 0; hour < 24; hour++ {
+		if a.HourlyVolume[hour] == 0 && b.HourlyVolume[hour] == 0 {
+			continue
+		}
+		fmt.Printf("  %02d:00 %10d %10d\n", hour, b.HourlyVolume[hour], a.HourlyVolume[hour])
+	}
+}
+
+func printDiffRow(label string, b, a int) {
+	change := "-"
+	if b > 0 {
+		change = fmt.Sprintf("%+.1f%%", float64(a-b)*100/float64(b))
+	} else if a > 0 {
+		change = "new"
+	}
+	fmt.Printf("%-12s %10d %10d %10s\n", label, b, a, change)
+}
+
+func sortByCount(keys []string, counts map[string]int) {
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
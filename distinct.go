@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// exactDistinctLimit is the number of unique values tracked exactly before
+// a distinct counter switches over to a HyperLogLog sketch
+const exactDistinctLimit = 100000
+
+// hllPrecision gives 2^14 registers, a standard error of roughly 0.8%
+const hllPrecision = 14
+
+// DistinctCounter counts unique values, exactly while the set is small and
+// approximately with HyperLogLog once it grows past exactDistinctLimit
+type DistinctCounter struct {
+	exact map[string]struct{}
+	hll   *hyperLogLog
+}
+
+func NewDistinctCounter() *DistinctCounter {
+	return &DistinctCounter{exact: make(map[string]struct{})}
+}
+
+func (dc *DistinctCounter) Add(value string) {
+	if dc.hll != nil {
+		dc.hll.Add(value)
+		return
+	}
+
+	dc.exact[value] = struct{}{}
+	if len(dc.exact) > exactDistinctLimit {
+		dc.hll = newHyperLogLog(hllPrecision)
+		for v := range dc.exact {
+			dc.hll.Add(v)
+		}
+		dc.exact = nil
+	}
+}
+
+// Count returns the number of unique values and whether it is an estimate
+func (dc *DistinctCounter) Count() (uint64, bool) {
+	if dc.hll != nil {
+		return dc.hll.Count(), true
+	}
+	return uint64(len(dc.exact)), false
+}
+
+type hyperLogLog struct {
+	p         uint8
+	registers []uint8
+}
+
+func newHyperLogLog(p uint8) *hyperLogLog {
+	return &hyperLogLog{p: p, registers: make([]uint8, 1<<p)}
+}
+
+func (h *hyperLogLog) Add(value string) {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(value))
+	x := mix64(hasher.Sum64())
+
+	idx := x >> (64 - h.p)
+	rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+func (h *hyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Small range correction: fall back to linear counting
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+// mix64 is the splitmix64 finalizer, spreading FNV output across all bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// anyHasField reports whether some entry has the field, e.g. a key of
+// JSON lines
+func anyHasField(entries []LogEntry, field string) bool {
+	for i := range entries {
+		if _, ok := entries[i].Field(field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (la *LogAnalyzer) showDistinct(entries []LogEntry, fields []string) {
+	fmt.Println("=== Distinct Values ===")
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if _, ok := (&LogEntry{}).Field(field); !ok && !la.derives(field) && !anyHasField(entries, field) {
+			log.Fatalf("Unknown field for -distinct: %s", field)
+		}
+
+		counter := NewDistinctCounter()
+		for _, entry := range entries {
+			if value, _ := entry.Field(field); value != "" {
+				counter.Add(value)
+			}
+		}
+
+		count, approximate := counter.Count()
+		if approximate {
+			fmt.Printf("%s: ~%d (HyperLogLog estimate)\n", field, count)
+		} else {
+			fmt.Printf("%s: %d\n", field, count)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 100, 10000, 200000} {
+		h := newHyperLogLog(hllPrecision)
+		for i := 0; i < n; i++ {
+			v := "client-" + strconv.Itoa(i)
+			h.Add(v)
+			h.Add(v)
+		}
+		got := float64(h.Count())
+		// Five standard errors of a 2^14 register sketch
+		if math.Abs(got-float64(n)) > 0.04*float64(n) {
+			t.Errorf("%d distinct values: estimate %v", n, got)
+		}
+	}
+}
+
+func TestDistinctCounter(t *testing.T) {
+	dc := NewDistinctCounter()
+	for i := 0; i < 1000; i++ {
+		dc.Add(strconv.Itoa(i % 10))
+	}
+	if n, estimate := dc.Count(); n != 10 || estimate {
+		t.Errorf("Count = %d, %v, want 10 exact", n, estimate)
+	}
+
+	for i := 0; i <= exactDistinctLimit; i++ {
+		dc.Add(strconv.Itoa(i))
+	}
+	n, estimate := dc.Count()
+	if !estimate {
+		t.Error("Count is still exact past exactDistinctLimit")
+	}
+	if math.Abs(float64(n)-exactDistinctLimit) > 0.04*exactDistinctLimit {
+		t.Errorf("Count = %d, want about %d", n, exactDistinctLimit)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// dockerClient talks to the Docker Engine API on $DOCKER_HOST, by default
+// the local unix socket
+type dockerClient struct {
+	base   string
+	client *http.Client
+}
+
+func newDockerClient() (*dockerClient, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		socket := strings.TrimPrefix(host, "unix://")
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		// The host part is ignored when dialing the socket
+		return &dockerClient{base: "http://docker", client: &http.Client{Transport: transport}}, nil
+	case strings.HasPrefix(host, "tcp://"):
+		return &dockerClient{base: "http://" + strings.TrimPrefix(host, "tcp://"), client: &http.Client{}}, nil
+	}
+	return nil, fmt.Errorf("unsupported DOCKER_HOST %q", host)
+}
+
+func (c *dockerClient) get(path string, query url.Values) (io.ReadCloser, error) {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := c.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		resp.Body.Close()
+		return nil, fmt.Errorf("docker: %s %s", resp.Status, apiErr.Message)
+	}
+	return resp.Body, nil
+}
+
+// openDocker streams a container's logs from a docker://<container> input.
+// With follow only new lines are sent and the stream stays open.
+func openDocker(name string, follow bool) (io.ReadCloser, error) {
+	container := strings.TrimPrefix(name, "docker://")
+	c, err := newDockerClient()
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/containers/" + url.PathEscape(container)
+	info, err := c.get(path+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	var inspect struct {
+		Config struct {
+			Tty bool
+		}
+	}
+	err = json.NewDecoder(info).Decode(&inspect)
+	info.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if follow {
+		query.Set("follow", "1")
+		query.Set("tail", "0")
+	}
+	body, err := c.get(path+"/logs", query)
+	if err != nil {
+		return nil, err
+	}
+	if inspect.Config.Tty {
+		// A TTY merges the streams and the output is sent as is
+		return body, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(demuxDocker(body, pw))
+	}()
+	return readCloser{Reader: pr, Closer: multiCloser{pr, body}}, nil
+}
+
+// demuxDocker copies the payload of a multiplexed log stream to w. Each
+// frame has an 8-byte header: the stream (1 stdout, 2 stderr), three zero
+// bytes and the big-endian payload size.
+func demuxDocker(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, br, size); err != nil {
+			return err
+		}
+	}
+}
+
+// multiCloser closes several closers, reporting the first error
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var first error
+	for _, c := range m {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// followDocker processes a container's new log lines as they are written
+func (la *LogAnalyzer) followDocker(name, format string, verbose bool) {
+	r, err := openDocker(name, true)
+	if err != nil {
+		log.Fatalf("Error following container: %v", err)
+	}
+	defer r.Close()
+
+	fmt.Println("Following container logs... (Press Ctrl+C to exit)")
+	source := inputSource(name)
+	scanner := la.newLineScanner(r)
+	for scanner.Scan() {
+		if entry := la.parseLine(scanner.Text(), format); entry != nil {
+			entry.Source = source
+			la.processLive(entry, verbose)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Error following container: %v", err)
+	}
+	log.Fatal("Container log stream closed")
+}
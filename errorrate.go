@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// TimeBucket holds level counts for one bucket of a timeline
+type TimeBucket struct {
+	Start  time.Time
+	Total  int
+	Errors int
+}
+
+// ErrorRate returns the percentage of entries in the bucket that are errors
+func (b TimeBucket) ErrorRate() float64 {
+	if b.Total == 0 {
+		return 0
+	}
+	return float64(b.Errors) * 100 / float64(b.Total)
+}
+
+// timeSpan returns the earliest and latest timestamps of the entries, zero
+// when none has one
+func timeSpan(entries []LogEntry) (first, last time.Time) {
+	for _, e := range entries {
+		if e.Timestamp.IsZero() {
+			continue
+		}
+		if first.IsZero() || e.Timestamp.Before(first) {
+			first = e.Timestamp
+		}
+		if e.Timestamp.After(last) {
+			last = e.Timestamp
+		}
+	}
+	return first, last
+}
+
+// maxBuckets bounds the buckets a report builds over the whole time range
+// of its input, checked with bucketCount before any are allocated
+const maxBuckets = 1000000
+
+// bucketCount returns how many buckets bucketEntries would make, without
+// making them, so a width can be refused before the allocation
+func bucketCount(entries []LogEntry, width time.Duration) int64 {
+	first, last := timeSpan(entries)
+	if first.IsZero() || width <= 0 {
+		return 0
+	}
+	return int64(last.Truncate(width).Sub(first.Truncate(width))/width) + 1
+}
+
+// bucketEntries groups timestamped entries into consecutive buckets of the
+// given width, including empty buckets so gaps show up in the timeline. A
+// width that isn't positive gives no buckets.
+func bucketEntries(entries []LogEntry, width time.Duration) []TimeBucket {
+	if width <= 0 {
+		return nil
+	}
+	counts := make(map[int64]*TimeBucket)
+	var first, last time.Time
+
+	for _, entry := range entries {
+		if entry.Timestamp.IsZero() {
+			continue
+		}
+		start := entry.Timestamp.Truncate(width)
+		b, ok := counts[start.UnixNano()]
+		if !ok {
+			b = &TimeBucket{Start: start}
+			counts[start.UnixNano()] = b
+		}
+		b.Total++
+		if parser.IsError(entry.Level) {
+			b.Errors++
+		}
+
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if last.IsZero() || start.After(last) {
+			last = start
+		}
+	}
+
+	var buckets []TimeBucket
+	if first.IsZero() {
+		return buckets
+	}
+	for t := first; !t.After(last); t = t.Add(width) {
+		if b, ok := counts[t.UnixNano()]; ok {
+			buckets = append(buckets, *b)
+		} else {
+			buckets = append(buckets, TimeBucket{Start: t})
+		}
+	}
+
+	return buckets
+}
+
+// parsePercent accepts "5%", "5" or "0.5%" and returns the percentage value
+func parsePercent(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+}
+
+func (la *LogAnalyzer) showErrorRate(entries []LogEntry, width time.Duration, threshold float64) {
+	buckets := bucketEntries(entries, width)
+	if len(buckets) == 0 {
+		fmt.Println("No timestamped entries to report on")
+		return
+	}
+
+	fmt.Printf("=== Error Rate per %s (threshold %.1f%%) ===\n", width, threshold)
+	fmt.Printf("%-19s  %8s  %8s  %7s\n", "Bucket", "Total", "Errors", "Rate")
+
+	type period struct {
+		start, end time.Time
+		peak       float64
+	}
+	var periods []period
+	var current *period
+
+	for _, b := range buckets {
+		rate := b.ErrorRate()
+		marker := ""
+		if threshold > 0 && rate > threshold {
+			marker = "  <-- above threshold"
+			if current == nil {
+				current = &period{start: b.Start}
+			}
+			current.end = b.Start.Add(width)
+			if rate > current.peak {
+				current.peak = rate
+			}
+		} else if current != nil {
+			periods = append(periods, *current)
+			current = nil
+		}
+
+		fmt.Printf("%-19s  %8d  %8d  %6.1f%%%s\n",
+			b.Start.Format("2006-01-02 15:04:05"), b.Total, b.Errors, rate, marker)
+	}
+	if current != nil {
+		periods = append(periods, *current)
+	}
+
+	if threshold <= 0 {
+		return
+	}
+
+	fmt.Println()
+	if len(periods) == 0 {
+		fmt.Println("Error rate stayed below the threshold")
+		return
+	}
+	fmt.Println("Periods above threshold:")
+	for _, p := range periods {
+		fmt.Printf("  %s to %s (%s, peak %.1f%%)\n",
+			p.start.Format("2006-01-02 15:04:05"),
+			p.end.Format("2006-01-02 15:04:05"),
+			p.end.Sub(p.start), p.peak)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// failureReason returns why a run should exit non-zero under -fail-on-*,
+// or "" when the entries are acceptable. maxErrors < 0 disables the count
+// check and a nil pattern disables the match check.
+func failureReason(entries []LogEntry, maxErrors int, pattern *regexp.Regexp) string {
+	errors := 0
+	for _, entry := range entries {
+		if parser.IsError(entry.Level) {
+			errors++
+		}
+		if pattern != nil && pattern.MatchString(entry.Raw) {
+			return fmt.Sprintf("entry matches -fail-on-match: %s", entry.Raw)
+		}
+	}
+	if maxErrors >= 0 && errors > maxErrors {
+		return fmt.Sprintf("%d error entries exceed -fail-on-error-count %d", errors, maxErrors)
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// followChunk is how much of a file a backwards scan for -since reads at a
+// time
+const followChunk = 64 << 10
+
+// followStart returns where following a file starts when -from-start or
+// -since moves it back from the end. ok is false when neither is set.
+func (la *LogAnalyzer) followStart(file *os.File, filename, format string) (offset int64, ok bool) {
+	switch {
+	case la.followFromStart:
+		return 0, true
+	case la.followSince <= 0:
+		return 0, false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Error opening file: %v", err)
+	}
+	cutoff := time.Now().Add(-la.followSince)
+
+	from, indexed := la.indexedStart(filename, info.Size(), cutoff)
+	if indexed {
+		offset, err = firstSince(file, from, cutoff, format)
+	} else {
+		offset, err = scanBackSince(file, info.Size(), cutoff, format)
+	}
+	if err != nil {
+		log.Fatalf("Error reading file: %v", err)
+	}
+	return offset, true
+}
+
+// indexedStart returns the start of the first block of the file's index
+// that can hold entries after cutoff, or the end of the indexed part when
+// none can. The file may have grown since it was indexed, as followed
+// files do, but not shrunk.
+func (la *LogAnalyzer) indexedStart(filename string, size int64, cutoff time.Time) (int64, bool) {
+	data, err := os.ReadFile(filename + indexSuffix)
+	if err != nil {
+		return 0, false
+	}
+	var idx timeIndex
+	if err := json.Unmarshal(data, &idx); err != nil || idx.Size > size {
+		return 0, false
+	}
+	for _, b := range idx.Blocks {
+		if b.Untimed || b.Min.IsZero() || !b.Max.Before(cutoff) {
+			return b.Offset, true
+		}
+	}
+	return idx.Size, true
+}
+
+// firstSince reads forward from a line boundary and returns the offset of
+// the first line with a timestamp at or after cutoff, or of the end
+func firstSince(file *os.File, from int64, cutoff time.Time, format string) (int64, error) {
+	if _, err := file.Seek(from, io.SeekStart); err != nil {
+		return 0, err
+	}
+	session := parser.NewSession(format)
+	reader := bufio.NewReader(file)
+	offset := from
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// A partial last line is left to the follow
+			return offset, nil
+		}
+		if t := lineTime(session, line); !t.IsZero() && !t.Before(cutoff) {
+			return offset, nil
+		}
+		offset += int64(len(line))
+	}
+}
+
+// scanBackSince reads a file backwards from its end and returns the offset
+// of the line after the last one timestamped before cutoff, so only the
+// end of a large file is read. Lines without a timestamp go along with the
+// entries after them.
+func scanBackSince(file *os.File, size int64, cutoff time.Time, format string) (int64, error) {
+	session := parser.NewSession(format)
+	var carry []byte
+	for pos := size; pos > 0; {
+		n := min(int64(followChunk), pos)
+		pos -= n
+		buf := make([]byte, n, n+int64(len(carry)))
+		if _, err := file.ReadAt(buf, pos); err != nil {
+			return 0, err
+		}
+		buf = append(buf, carry...)
+
+		// Complete lines are those after the first newline, or all of them
+		// at the start of the file
+		first := 0
+		if pos > 0 {
+			i := bytes.IndexByte(buf, '\n')
+			if i < 0 {
+				carry = buf
+				continue
+			}
+			first = i + 1
+		}
+		lines := buf[first:]
+		for len(lines) > 0 {
+			i := bytes.LastIndexByte(lines[:len(lines)-1], '\n') + 1
+			line := lines[i:]
+			lines = lines[:i]
+			start := pos + int64(first+i)
+			if line[len(line)-1] != '\n' {
+				// A partial last line is left to the follow
+				continue
+			}
+			if t := lineTime(session, string(line)); !t.IsZero() && t.Before(cutoff) {
+				return start + int64(len(line)), nil
+			}
+		}
+		carry = buf[:first]
+	}
+	return 0, nil
+}
+
+// lineTime returns the timestamp of a line, or zero when it has none
+func lineTime(session *parser.Session, line string) time.Time {
+	entry, _ := session.TryParse(trimLine(line, false))
+	if entry == nil {
+		return time.Time{}
+	}
+	return entry.Timestamp
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// textSuffix names the sidecar full-text index next to a log file
+const textSuffix = ".lafts"
+
+// textIndex is a trigram index of a log file's lines: every three bytes of
+// lowercased text map to the lines holding them, so a search reads only
+// the lines holding all of its trigrams
+type textIndex struct {
+	Size    int64
+	ModTime time.Time
+	Format  string
+	// Lines are the offsets where the file's lines start
+	Lines []int64
+	// Postings holds the numbers of the lines with each trigram, ascending
+	// and written as uvarint deltas
+	Postings map[string][]byte
+
+	// building holds the postings while the index is built
+	building map[string]*posting
+}
+
+// posting is a trigram's postings being built, with the number of the last
+// line added
+type posting struct {
+	last uint32
+	data []byte
+}
+
+func newTextIndex(size int64, modTime time.Time, format string) *textIndex {
+	return &textIndex{Size: size, ModTime: modTime, Format: format, building: make(map[string]*posting)}
+}
+
+// add indexes the next line of the file, starting at offset
+func (idx *textIndex) add(offset int64, line string) {
+	n := uint32(len(idx.Lines))
+	idx.Lines = append(idx.Lines, offset)
+	text := strings.ToLower(line)
+	var buf [binary.MaxVarintLen32]byte
+	for i := 0; i+3 <= len(text); i++ {
+		p, ok := idx.building[text[i:i+3]]
+		switch {
+		case !ok:
+			// Keys outlive the line they were cut from
+			p = &posting{}
+			idx.building[strings.Clone(text[i:i+3])] = p
+		case p.last == n && len(p.data) > 0:
+			continue
+		}
+		p.data = append(p.data, buf[:binary.PutUvarint(buf[:], uint64(n-p.last))]...)
+		p.last = n
+	}
+}
+
+// finish moves the built postings into Postings
+func (idx *textIndex) finish() {
+	idx.Postings = make(map[string][]byte, len(idx.building))
+	for trigram, p := range idx.building {
+		idx.Postings[trigram] = p.data
+	}
+	idx.building = nil
+}
+
+func (idx *textIndex) save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := gob.NewEncoder(w).Encode(idx); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// candidates returns the numbers of the lines holding every trigram of the
+// lowercased query, or all lines when it is shorter than a trigram
+func (idx *textIndex) candidates(query string) []uint32 {
+	query = strings.ToLower(query)
+	if len(query) < 3 {
+		all := make([]uint32, len(idx.Lines))
+		for i := range all {
+			all[i] = uint32(i)
+		}
+		return all
+	}
+
+	seen := make(map[string]bool)
+	var trigrams []string
+	for i := 0; i+3 <= len(query); i++ {
+		if t := query[i : i+3]; !seen[t] {
+			seen[t] = true
+			trigrams = append(trigrams, t)
+		}
+	}
+	// Intersecting from the rarest trigram keeps the lists short
+	sort.Slice(trigrams, func(i, j int) bool {
+		return len(idx.Postings[trigrams[i]]) < len(idx.Postings[trigrams[j]])
+	})
+
+	var lines []uint32
+	for i, t := range trigrams {
+		postings := decodePostings(idx.Postings[t])
+		if i == 0 {
+			lines = postings
+		} else {
+			lines = intersect(lines, postings)
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+	}
+	return lines
+}
+
+func decodePostings(data []byte) []uint32 {
+	var lines []uint32
+	var n uint32
+	for len(data) > 0 {
+		delta, size := binary.Uvarint(data)
+		data = data[size:]
+		n += uint32(delta)
+		lines = append(lines, n)
+	}
+	return lines
+}
+
+// intersect returns the numbers in both ascending lists
+func intersect(a, b []uint32) []uint32 {
+	out := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// loadTextIndex reads a file's full-text index. ok is false when there is
+// none, or it no longer matches the file.
+func (la *LogAnalyzer) loadTextIndex(filename string) (*textIndex, bool) {
+	if filename == "-" || strings.Contains(filename, "://") {
+		return nil, false
+	}
+	f, err := os.Open(filename + textSuffix)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	var idx textIndex
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&idx); err != nil {
+		log.Printf("%s%s: %v (reading the whole file)", filename, textSuffix, err)
+		return nil, false
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, false
+	}
+	if info.Size() != idx.Size || !info.ModTime().Equal(idx.ModTime) {
+		log.Printf("%s changed since it was indexed; reading the whole file (run loganalyzer index -full-text again)", filename)
+		return nil, false
+	}
+	return &idx, true
+}
+
+// runSearch prints the entries whose message contains a text, reading
+// only the lines the full-text index (index -full-text) points at
+func runSearch(args []string) {
+	var query string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		query, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	input := addInputFlags(fs)
+	output := fs.String("output", "", "Output format (json, ndjson, csv, logfmt; default text)")
+	limit := fs.Int("limit", 0, "Stop after N matches (0 for all)")
+	verbose := fs.Bool("v", false, "Verbose output")
+	parseFlags(fs, args)
+	if query == "" && fs.NArg() > 0 {
+		query = strings.Join(fs.Args(), " ")
+	}
+
+	if query == "" || len(input.files) == 0 {
+		fmt.Println(`Usage: loganalyzer search "<text>" -f <logfile> [-f <logfile>...] [options]`)
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	analyzer := input.newAnalyzer()
+	start := time.Now()
+	var matches []LogEntry
+	read := 0
+	for _, filename := range input.files {
+		found, n, err := analyzer.searchFile(filename, query, *input.format, *limit-len(matches))
+		if err != nil {
+			log.Fatalf("Error searching %s: %v", filename, err)
+		}
+		matches = append(matches, found...)
+		read += n
+		if *limit > 0 && len(matches) >= *limit {
+			break
+		}
+	}
+	analyzer.outputEntries(matches, *output, *verbose)
+	fmt.Fprintf(os.Stderr, "%d matches, %d lines read in %s\n", len(matches), read, time.Since(start).Round(time.Millisecond))
+}
+
+// searchFile returns up to limit (all when limit <= 0) entries of a file
+// matching the query and the filters, and how many lines were read. It
+// reads the lines the full-text index points at, or the whole file without
+// one.
+func (la *LogAnalyzer) searchFile(filename, query, format string, limit int) ([]LogEntry, int, error) {
+	needle := query
+	if !la.filters.CaseSensitive {
+		needle = strings.ToLower(needle)
+	}
+	var matches []LogEntry
+	read := 0
+	// check parses a line and keeps it when it matches, reporting whether
+	// the limit was reached
+	check := func(line string, lineNum int) bool {
+		read++
+		entry := la.parseLine(la.long.clip(line), format)
+		if entry == nil {
+			return false
+		}
+		message := entry.Message
+		if !la.filters.CaseSensitive {
+			message = strings.ToLower(message)
+		}
+		if !strings.Contains(message, needle) {
+			return false
+		}
+		entry.File = filename
+		entry.LineNum = lineNum
+		la.enrich(entry)
+		if !la.matchesFilters(*entry) {
+			return false
+		}
+		matches = append(matches, *entry)
+		return limit > 0 && len(matches) >= limit
+	}
+
+	idx, ok := la.loadTextIndex(filename)
+	if !ok {
+		r, err := la.openInput(filename)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer r.Close()
+		scanner := la.newLineScanner(r)
+		for lineNum := 1; scanner.Scan(); lineNum++ {
+			if check(scanner.Text(), lineNum) {
+				break
+			}
+		}
+		la.resolveHostnames(matches)
+		return matches, read, scanner.Err()
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	var buf []byte
+	for _, n := range idx.candidates(query) {
+		start, end := idx.Lines[n], idx.Size
+		if int(n)+1 < len(idx.Lines) {
+			end = idx.Lines[n+1]
+		}
+		if int64(cap(buf)) < end-start {
+			buf = make([]byte, end-start)
+		}
+		buf = buf[:end-start]
+		if _, err := f.ReadAt(buf, start); err != nil && !errors.Is(err, io.EOF) {
+			return nil, 0, err
+		}
+		if check(trimLine(string(buf), start == 0), int(n)+1) {
+			break
+		}
+	}
+	la.resolveHostnames(matches)
+	return matches, read, nil
+}
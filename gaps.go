@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Gap is a period in which a source produced no entries
+type Gap struct {
+	Source string
+	From   time.Time
+	To     time.Time
+	// Trailing marks a source that never resumed before the log ended
+	Trailing bool
+}
+
+func (g Gap) Duration() time.Duration {
+	return g.To.Sub(g.From)
+}
+
+// findGaps returns silences longer than minGap per source, including sources
+// that went quiet before the last entry of the whole log
+func findGaps(entries []LogEntry, minGap time.Duration) []Gap {
+	bySource := make(map[string][]time.Time)
+	var logEnd time.Time
+
+	for _, entry := range entries {
+		if entry.Timestamp.IsZero() {
+			continue
+		}
+		source := entry.Source
+		if source == "" {
+			source = "(no source)"
+		}
+		bySource[source] = append(bySource[source], entry.Timestamp)
+		if entry.Timestamp.After(logEnd) {
+			logEnd = entry.Timestamp
+		}
+	}
+
+	var gaps []Gap
+	for source, times := range bySource {
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+		for i := 1; i < len(times); i++ {
+			if times[i].Sub(times[i-1]) > minGap {
+				gaps = append(gaps, Gap{Source: source, From: times[i-1], To: times[i]})
+			}
+		}
+
+		last := times[len(times)-1]
+		if logEnd.Sub(last) > minGap {
+			gaps = append(gaps, Gap{Source: source, From: last, To: logEnd, Trailing: true})
+		}
+	}
+
+	sort.Slice(gaps, func(i, j int) bool {
+		if !gaps[i].From.Equal(gaps[j].From) {
+			return gaps[i].From.Before(gaps[j].From)
+		}
+		return gaps[i].Source < gaps[j].Source
+	})
+
+	return gaps
+}
+
+func (la *LogAnalyzer) showGaps(entries []LogEntry, minGap time.Duration) {
+	gaps := findGaps(entries, minGap)
+
+	fmt.Printf("=== Gaps longer than %s ===\n", minGap)
+	if len(gaps) == 0 {
+		fmt.Println("No gaps found")
+		return
+	}
+
+	for _, g := range gaps {
+		suffix := ""
+		if g.Trailing {
+			suffix = " (silent until end of log)"
+		}
+		fmt.Printf("%-20s %s to %s  %s%s\n", g.Source,
+			g.From.Format("2006-01-02 15:04:05"),
+			g.To.Format("2006-01-02 15:04:05"),
+			g.Duration(), suffix)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// mmdbMetadataMarker precedes the metadata map at the end of a MaxMind DB
+var mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// GeoIPReader looks up IP addresses in a MaxMind DB file (GeoLite2-City,
+// GeoLite2-Country and compatible databases)
+type GeoIPReader struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dataStart  uint
+	ipv4Start  uint
+	cache      *lruCache[string, *GeoLocation]
+}
+
+func OpenGeoIP(path string) (*GeoIPReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if idx < 0 {
+		return nil, fmt.Errorf("%s: not a MaxMind DB file", path)
+	}
+
+	d := mmdbDecoder{buf: buf[idx+len(mmdbMetadataMarker):]}
+	value, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid metadata: %v", path, err)
+	}
+	meta, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: invalid metadata", path)
+	}
+
+	r := &GeoIPReader{
+		buf:        buf,
+		nodeCount:  uint(mmdbUint(meta["node_count"])),
+		recordSize: uint(mmdbUint(meta["record_size"])),
+		ipVersion:  uint(mmdbUint(meta["ip_version"])),
+		cache:      newLRUCache[string, *GeoLocation](lookupCacheSize),
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%s: unsupported record size %d", path, r.recordSize)
+	}
+	r.dataStart = r.nodeCount*r.recordSize/4 + 16
+	if r.dataStart > uint(len(buf)) {
+		return nil, fmt.Errorf("%s: truncated search tree", path)
+	}
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Lookup returns the location for an IP address, or nil when the address is
+// invalid or not in the database. Results are cached per address, for the
+// most recent lookupCacheSize addresses.
+func (r *GeoIPReader) Lookup(address string) *GeoLocation {
+	if loc, ok := r.cache.Get(address); ok {
+		return loc
+	}
+
+	loc := r.lookup(address)
+	r.cache.Put(address, loc)
+	return loc
+}
+
+func (r *GeoIPReader) lookup(address string) *GeoLocation {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil
+	}
+
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		node = r.ipv4Start
+	} else if r.ipVersion == 4 {
+		return nil
+	}
+
+	bitCount := uint(len(ip) * 8)
+	for i := uint(0); i < bitCount && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-i%8)) & 1
+		node = r.readRecord(node, bit)
+	}
+
+	if node <= r.nodeCount {
+		return nil
+	}
+
+	d := mmdbDecoder{buf: r.buf[r.dataStart:]}
+	value, _, err := d.decode(node - r.nodeCount - 16)
+	if err != nil {
+		return nil
+	}
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	loc := &GeoLocation{
+		CountryCode: mmdbString(record, "country", "iso_code"),
+		Country:     mmdbString(record, "country", "names", "en"),
+		City:        mmdbString(record, "city", "names", "en"),
+	}
+	if loc.CountryCode == "" {
+		loc.CountryCode = mmdbString(record, "registered_country", "iso_code")
+		loc.Country = mmdbString(record, "registered_country", "names", "en")
+	}
+	return loc
+}
+
+func (r *GeoIPReader) readRecord(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := r.buf[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		off := node * 7
+		b := r.buf[off : off+7]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.buf[off : off+4]))
+	}
+}
+
+// mmdbDecoder decodes the MaxMind DB data section format
+type mmdbDecoder struct {
+	buf []byte
+}
+
+var errMMDBTruncated = errors.New("truncated data section")
+
+func (d *mmdbDecoder) decode(offset uint) (interface{}, uint, error) {
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errMMDBTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typeNum := uint(ctrl >> 5)
+
+	if typeNum == 1 {
+		ptr, next, err := d.decodePointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(ptr)
+		return value, next, err
+	}
+
+	if typeNum == 0 {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errMMDBTruncated
+		}
+		typeNum = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		extra := size - 28
+		if offset+extra > uint(len(d.buf)) {
+			return nil, 0, errMMDBTruncated
+		}
+		n := uint(0)
+		for _, b := range d.buf[offset : offset+extra] {
+			n = n<<8 | uint(b)
+		}
+		offset += extra
+		switch size {
+		case 29:
+			size = 29 + n
+		case 30:
+			size = 285 + n
+		default:
+			size = 65821 + n
+		}
+	}
+
+	switch typeNum {
+	case 7: // map
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, _ := key.(string)
+			m[k] = value
+			offset = next
+		}
+		return m, offset, nil
+	case 11: // array
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case 14: // boolean, stored in the size field
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errMMDBTruncated
+	}
+	data := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typeNum {
+	case 2: // utf8 string
+		return string(data), next, nil
+	case 3: // double
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), next, nil
+	case 4: // bytes
+		return append([]byte(nil), data...), next, nil
+	case 5, 6, 9, 10: // uint16, uint32, uint64, uint128 (truncated to 64 bits)
+		n := uint64(0)
+		for _, b := range data {
+			n = n<<8 | uint64(b)
+		}
+		return n, next, nil
+	case 8: // int32
+		n := uint32(0)
+		for _, b := range data {
+			n = n<<8 | uint32(b)
+		}
+		return int32(n), next, nil
+	case 15: // float
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), next, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported data type %d", typeNum)
+}
+
+func (d *mmdbDecoder) decodePointer(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl>>3) & 0x3
+	n := size + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errMMDBTruncated
+	}
+	b := d.buf[offset : offset+n]
+
+	var ptr uint
+	switch size {
+	case 0:
+		ptr = uint(ctrl&0x7)<<8 | uint(b[0])
+	case 1:
+		ptr = (uint(ctrl&0x7)<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 2:
+		ptr = (uint(ctrl&0x7)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		ptr = uint(binary.BigEndian.Uint32(b))
+	}
+	return ptr, offset + n, nil
+}
+
+func mmdbUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int32:
+		return uint64(n)
+	}
+	return 0
+}
+
+// mmdbString walks nested maps by key and returns the string at the end
+func mmdbString(record map[string]interface{}, path ...string) string {
+	var current interface{} = record
+	for _, key := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		current = m[key]
+	}
+	s, _ := current.(string)
+	return s
+}
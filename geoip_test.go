@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMMDBDecode(t *testing.T) {
+	long := strings.Repeat("x", 30)
+	tests := []struct {
+		name   string
+		buf    []byte
+		offset uint
+		want   interface{}
+		next   uint
+	}{
+		{"string", []byte("\x42hi"), 0, "hi", 3},
+		{"long string", append([]byte{0x5D, 0x01}, long...), 0, long, 32},
+		{"uint16", []byte{0xA2, 0x01, 0x2C}, 0, uint64(300), 3},
+		{"uint32", []byte{0xC1, 0x05}, 0, uint64(5), 2},
+		{"int32", []byte{0x04, 0x01, 0xFF, 0xFF, 0xFF, 0xFF}, 0, int32(-1), 6},
+		{"double", []byte{0x68, 0x3F, 0xF8, 0, 0, 0, 0, 0, 0}, 0, 1.5, 9},
+		{"boolean", []byte{0x01, 0x07}, 0, true, 2},
+		{"map", []byte{0xE1, 0x41, 'a', 0xA1, 0x07}, 0, map[string]interface{}{"a": uint64(7)}, 5},
+		{"array", []byte{0x02, 0x04, 0x41, 'x', 0x41, 'y'}, 0, []interface{}{"x", "y"}, 6},
+		// A pointer resolves to the value it points at; decoding continues
+		// after the pointer itself
+		{"pointer", []byte{0x41, 'a', 0x20, 0x00}, 2, "a", 4},
+	}
+	for _, tt := range tests {
+		d := mmdbDecoder{buf: tt.buf}
+		got, next, err := d.decode(tt.offset)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || next != tt.next {
+			t.Errorf("%s: decode = %#v, %d, want %#v, %d", tt.name, got, next, tt.want, tt.next)
+		}
+	}
+}
+
+func TestMMDBDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"truncated string", []byte("\x43ab")},
+		{"truncated size", []byte{0x5D}},
+		{"truncated map", []byte{0xE1, 0x41, 'a'}},
+		{"truncated pointer", []byte{0x28, 0x00}},
+		{"bad double", []byte{0x64, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		d := mmdbDecoder{buf: tt.buf}
+		if v, _, err := d.decode(0); err == nil {
+			t.Errorf("%s: decode = %#v, want an error", tt.name, v)
+		}
+	}
+}
+
+func TestMMDBString(t *testing.T) {
+	record := map[string]interface{}{
+		"country": map[string]interface{}{"names": map[string]interface{}{"en": "Germany"}},
+	}
+	if got := mmdbString(record, "country", "names", "en"); got != "Germany" {
+		t.Errorf("mmdbString = %q, want Germany", got)
+	}
+	if got := mmdbString(record, "city", "names", "en"); got != "" {
+		t.Errorf("mmdbString of a missing path = %q", got)
+	}
+}
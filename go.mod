@@ -0,0 +1,11 @@
+module github.com/hrabid/log-analyzer
+
+go 1.25.0
+
+require (
+	github.com/tetratelabs/wazero v1.12.0
+	github.com/yuin/gopher-lua v1.1.2
+	golang.org/x/crypto v0.54.0
+)
+
+require golang.org/x/sys v0.47.0 // indirect
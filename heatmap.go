@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// heatmapShades render a cell's count relative to the busiest cell
+var heatmapShades = []string{"  ", "░░", "▒▒", "▓▓", "██"}
+
+// weekdayGrid counts entries by weekday (Monday first) and hour of day
+type weekdayGrid [7][24]int
+
+func buildWeekdayGrid(entries []LogEntry, errorsOnly bool) (grid weekdayGrid, total int) {
+	for _, entry := range entries {
+		if entry.Timestamp.IsZero() || (errorsOnly && !parser.IsError(entry.Level)) {
+			continue
+		}
+		day := (int(entry.Timestamp.Weekday()) + 6) % 7
+		grid[day][entry.Timestamp.Hour()]++
+		total++
+	}
+	return grid, total
+}
+
+// showHeatmap prints entries (or only errors) per weekday and hour as a
+// shaded grid, or as CSV, to bring out cron jobs and business hours
+func (la *LogAnalyzer) showHeatmap(entries []LogEntry, errorsOnly bool, output string) {
+	grid, total := buildWeekdayGrid(entries, errorsOnly)
+	what, title := "entries", "Entries"
+	if errorsOnly {
+		what, title = "errors", "Errors"
+	}
+
+	if output == "csv" {
+		fmt.Print("Weekday")
+		for hour := 0; hour < 24; hour++ {
+			fmt.Printf(",%02d", hour)
+		}
+		fmt.Println()
+		for day, counts := range grid {
+			fmt.Print(weekdayName(day))
+			for _, n := range counts {
+				fmt.Printf(",%d", n)
+			}
+			fmt.Println()
+		}
+		return
+	}
+
+	if total == 0 {
+		fmt.Printf("No timestamped %s to report on\n", what)
+		return
+	}
+	max := 0
+	for _, counts := range grid {
+		for _, n := range counts {
+			if n > max {
+				max = n
+			}
+		}
+	}
+
+	fmt.Printf("=== %s by Weekday and Hour ===\n", title)
+	fmt.Print("    ")
+	for hour := 0; hour < 24; hour++ {
+		fmt.Printf(" %02d", hour)
+	}
+	fmt.Printf(" %8s\n", "Total")
+	for day, counts := range grid {
+		fmt.Print(weekdayName(day))
+		sum := 0
+		for _, n := range counts {
+			shade := 0
+			if n > 0 {
+				// Any count shows, however small next to the maximum
+				shade = 1 + (n*(len(heatmapShades)-1)-1)/max
+			}
+			fmt.Print(" " + heatmapShades[shade])
+			sum += n
+		}
+		fmt.Printf(" %8d\n", sum)
+	}
+	fmt.Println()
+
+	var legend []string
+	low := 1
+	for shade := 1; shade < len(heatmapShades); shade++ {
+		high := shade * max / (len(heatmapShades) - 1)
+		if high >= low {
+			legend = append(legend, fmt.Sprintf("%s %d-%d", heatmapShades[shade], low, high))
+			low = high + 1
+		}
+	}
+	fmt.Printf("%s %s per hour\n", strings.Join(legend, "  "), what)
+}
+
+func weekdayName(day int) string {
+	return time.Weekday((day + 1) % 7).String()[:3]
+}
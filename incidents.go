@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hrabid/log-analyzer/pkg/stats"
+)
+
+const (
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieAlertsURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// alertDedupKey identifies the incident an alert belongs to: the rule plus
+// the template of the latest triggering message, so repeated occurrences of
+// the same error update one incident instead of opening new ones
+func alertDedupKey(alert Alert) string {
+	template := ""
+	if len(alert.Entries) > 0 {
+		template = stats.NormalizeMessage(alert.Entries[len(alert.Entries)-1].Message)
+	}
+	sum := sha1.Sum([]byte(template))
+	return alert.Rule + "-" + hex.EncodeToString(sum[:8])
+}
+
+// alertSeverity maps the level of the latest entry onto incident severity
+func alertSeverity(alert Alert) string {
+	if len(alert.Entries) == 0 {
+		return "error"
+	}
+	switch alert.Entries[len(alert.Entries)-1].Level {
+	case "FATAL":
+		return "critical"
+	case "ERROR":
+		return "error"
+	case "WARN":
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// postJSON sends v as a JSON body and treats any non-2xx reply as an error
+func postJSON(client *http.Client, url string, headers map[string]string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// pagerDutyNotifier triggers incidents through the PagerDuty Events API v2
+type pagerDutyNotifier struct {
+	routingKey string
+	url        string
+	client     *http.Client
+}
+
+func newPagerDutyNotifier(routingKey string) *pagerDutyNotifier {
+	return &pagerDutyNotifier{routingKey: routingKey, url: pagerDutyEventsURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *pagerDutyNotifier) Notify(alert Alert) error {
+	hostname, _ := os.Hostname()
+	event := map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alertDedupKey(alert),
+		"payload": map[string]interface{}{
+			"summary":        truncate(alert.Summary, 1024),
+			"source":         hostname,
+			"severity":       alertSeverity(alert),
+			"timestamp":      alert.Time.Format(time.RFC3339),
+			"component":      alert.Rule,
+			"custom_details": alert,
+		},
+	}
+	return postJSON(p.client, p.url, nil, event)
+}
+
+// opsgenieNotifier creates alerts through the Opsgenie Alert API; the alias
+// field is Opsgenie's dedup key
+type opsgenieNotifier struct {
+	apiKey string
+	url    string
+	client *http.Client
+}
+
+func newOpsgenieNotifier(apiKey string) *opsgenieNotifier {
+	return &opsgenieNotifier{apiKey: apiKey, url: opsgenieAlertsURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (o *opsgenieNotifier) Notify(alert Alert) error {
+	priority := map[string]string{"critical": "P1", "error": "P2", "warning": "P3"}[alertSeverity(alert)]
+	if priority == "" {
+		priority = "P4"
+	}
+	hostname, _ := os.Hostname()
+
+	var description bytes.Buffer
+	for _, entry := range alert.Entries {
+		fmt.Fprintf(&description, "%s [%s] %s\n", entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Level, entry.Message)
+	}
+
+	body := map[string]interface{}{
+		"message":     truncate(alert.Summary, 130),
+		"alias":       alertDedupKey(alert),
+		"description": truncate(description.String(), 15000),
+		"priority":    priority,
+		"source":      hostname,
+		"tags":        []string{"loganalyzer", alert.Rule},
+	}
+	return postJSON(o.client, o.url, map[string]string{"Authorization": "GenieKey " + o.apiKey}, body)
+}
+
+// truncate shortens s to at most n bytes, cutting on a rune boundary so the
+// JSON payload stays valid UTF-8
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n - 3
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// indexSuffix names the sidecar index next to a log file
+const indexSuffix = ".laidx"
+
+// timeIndex maps a log file's byte offsets to the time range of the
+// entries found there. Blocks start at line boundaries; block i runs up to
+// the next block's offset or the end of the file.
+type timeIndex struct {
+	Size    int64        `json:"size"`
+	ModTime time.Time    `json:"mtime"`
+	Format  string       `json:"format"`
+	Blocks  []indexBlock `json:"blocks"`
+}
+
+// indexBlock holds the time range of a block. Untimed blocks contain lines
+// without a timestamp (or lines no entry was parsed from), which pass any
+// time filter, so they are always read.
+type indexBlock struct {
+	Offset  int64     `json:"offset"`
+	Min     time.Time `json:"min"`
+	Max     time.Time `json:"max"`
+	Untimed bool      `json:"untimed,omitempty"`
+}
+
+// byteRange is a region of a file to read, [start, end)
+type byteRange struct {
+	start, end int64
+}
+
+// runIndex builds the time index of each file, and with -full-text the
+// full-text index search reads
+func runIndex(args []string) {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	input := addInputFlags(fs)
+	blockSize := byteSize(1 << 20)
+	fs.Var(&blockSize, "block", "Bytes of log per index entry (e.g. 256KB, 4MB); smaller blocks skip more precisely")
+	fullText := fs.Bool("full-text", false, "Also build a trigram index of every line (saved as <file>"+textSuffix+"), so search reads only the lines holding the text")
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 {
+		fmt.Println("Usage: loganalyzer index -f <logfile> [-f <logfile>...] [-block 1MB] [-full-text] [options]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	if blockSize < 1 {
+		blockSize = 1
+	}
+
+	analyzer := input.newAnalyzer()
+	for _, filename := range input.files {
+		idx, text, err := analyzer.buildIndex(filename, *input.format, int64(blockSize), *fullText)
+		if err != nil {
+			log.Fatalf("Error indexing %s: %v", filename, err)
+		}
+		if err := idx.save(filename + indexSuffix); err != nil {
+			log.Fatalf("Error saving index: %v", err)
+		}
+		fmt.Printf("Indexed %s: %d blocks, %s\n", filename, len(idx.Blocks), idx.timeRange())
+		if text != nil {
+			if err := text.save(filename + textSuffix); err != nil {
+				log.Fatalf("Error saving index: %v", err)
+			}
+			fmt.Printf("Indexed the text of %s: %d lines, %d trigrams\n", filename, len(text.Lines), len(text.Postings))
+		}
+	}
+}
+
+// buildIndex reads a plain local file, recording the time range of each
+// block of about blockSize bytes and, with fullText, the trigrams of each
+// line
+func (la *LogAnalyzer) buildIndex(filename, format string, blockSize int64, fullText bool) (*timeIndex, *textIndex, error) {
+	if filename == "-" || strings.Contains(filename, "://") {
+		return nil, nil, errors.New("only local files can be indexed")
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, errors.New("not a regular file")
+	}
+
+	idx := &timeIndex{Size: info.Size(), ModTime: info.ModTime(), Format: format}
+	var text *textIndex
+	if fullText {
+		text = newTextIndex(info.Size(), info.ModTime(), format)
+	}
+	r := bufio.NewReaderSize(f, 64*1024)
+	if magic, _ := r.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return nil, nil, errors.New("compressed files can't be indexed")
+	}
+
+	var offset int64
+	var block *indexBlock
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			if block == nil || offset-block.Offset >= blockSize {
+				idx.Blocks = append(idx.Blocks, indexBlock{Offset: offset})
+				block = &idx.Blocks[len(idx.Blocks)-1]
+			}
+			entry := la.parseLine(trimLine(line, offset == 0), format)
+			if text != nil {
+				text.add(offset, trimLine(line, offset == 0))
+			}
+			offset += int64(len(line))
+
+			switch {
+			case entry == nil || entry.Timestamp.IsZero():
+				block.Untimed = true
+			case block.Min.IsZero():
+				block.Min, block.Max = entry.Timestamp, entry.Timestamp
+			case entry.Timestamp.Before(block.Min):
+				block.Min = entry.Timestamp
+			case entry.Timestamp.After(block.Max):
+				block.Max = entry.Timestamp
+			}
+		}
+		if err == io.EOF {
+			if text != nil {
+				text.finish()
+			}
+			return idx, text, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+func (idx *timeIndex) save(path string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (idx *timeIndex) timeRange() string {
+	var first, last time.Time
+	for _, b := range idx.Blocks {
+		if b.Min.IsZero() {
+			continue
+		}
+		if first.IsZero() || b.Min.Before(first) {
+			first = b.Min
+		}
+		if b.Max.After(last) {
+			last = b.Max
+		}
+	}
+	if first.IsZero() {
+		return "no timestamps"
+	}
+	return first.Format("2006-01-02 15:04:05") + " to " + last.Format("2006-01-02 15:04:05")
+}
+
+// indexRanges returns the regions of a file that can hold entries within
+// the -start/-end range, using its sidecar index. ok is false when there
+// is no time filter, no index, or the index no longer matches the file.
+func (la *LogAnalyzer) indexRanges(filename string) (ranges []byteRange, ok bool) {
+	start, end := la.filters.StartTime, la.filters.EndTime
+	if (start == nil && end == nil) || filename == "-" || strings.Contains(filename, "://") {
+		return nil, false
+	}
+	data, err := os.ReadFile(filename + indexSuffix)
+	if err != nil {
+		return nil, false
+	}
+	var idx timeIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		log.Printf("%s%s: %v (reading the whole file)", filename, indexSuffix, err)
+		return nil, false
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, false
+	}
+	if info.Size() != idx.Size || !info.ModTime().Equal(idx.ModTime) {
+		if _, warned := la.warned.LoadOrStore(filename+indexSuffix, true); !warned {
+			log.Printf("%s changed since it was indexed; reading the whole file (run loganalyzer index again)", filename)
+		}
+		return nil, false
+	}
+
+	for i, b := range idx.Blocks {
+		if !b.Untimed && !b.Min.IsZero() {
+			if start != nil && b.Max.Before(*start) {
+				continue
+			}
+			if end != nil && b.Min.After(*end) {
+				continue
+			}
+		}
+		blockEnd := idx.Size
+		if i+1 < len(idx.Blocks) {
+			blockEnd = idx.Blocks[i+1].Offset
+		}
+		// Adjacent blocks are read as one region
+		if n := len(ranges); n > 0 && ranges[n-1].end == b.Offset {
+			ranges[n-1].end = blockEnd
+		} else {
+			ranges = append(ranges, byteRange{b.Offset, blockEnd})
+		}
+	}
+	return ranges, true
+}
+
+// sectionReader reads the given regions of a file one after another
+func sectionReader(f *os.File, ranges []byteRange) io.Reader {
+	readers := make([]io.Reader, len(ranges))
+	for i, r := range ranges {
+		readers[i] = io.NewSectionReader(f, r.start, r.end-r.start)
+	}
+	return io.MultiReader(readers...)
+}
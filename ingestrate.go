@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ingestRateInterval is how often the -rate status line is redrawn; its
+// rates are over the last interval
+const ingestRateInterval = time.Second
+
+// ingestRate counts the lines read while following, the entries that
+// passed the filters and the lines no format matched, and redraws a status
+// line with their rates on stderr (-rate), so it shows whether a filter
+// matches anything and how busy the log is
+type ingestRate struct {
+	lines, unmatched, matched atomic.Int64
+
+	// mu serializes the status line with the entries printed under it;
+	// drawn is whether it is on screen
+	mu    sync.Mutex
+	drawn bool
+}
+
+// line counts a line read, and whether a format matched it
+func (r *ingestRate) line(parsed bool) {
+	r.lines.Add(1)
+	if !parsed {
+		r.unmatched.Add(1)
+	}
+}
+
+// match counts an entry that passed the filters
+func (r *ingestRate) match() {
+	r.matched.Add(1)
+}
+
+// around runs print, which writes entries, with the status line cleared,
+// so entries don't start on it; the next tick draws it again under them
+func (r *ingestRate) around(print func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.drawn {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		r.drawn = false
+	}
+	print()
+}
+
+// Run redraws the status line every interval
+func (r *ingestRate) Run() {
+	var lines, matched int64
+	prev := time.Now()
+	for now := range time.Tick(ingestRateInterval) {
+		l, m, u := r.lines.Load(), r.matched.Load(), r.unmatched.Load()
+		secs := now.Sub(prev).Seconds()
+		status := fmt.Sprintf("%d lines  %.1f lines/s  matched %.1f/s (%d)  parse errors %d",
+			l, float64(l-lines)/secs, float64(m-matched)/secs, m, u)
+		lines, matched, prev = l, m, now
+
+		r.mu.Lock()
+		fmt.Fprint(os.Stderr, "\r\033[K"+status)
+		r.drawn = true
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// remoteConfig holds the settings for inputs that aren't local files
+type remoteConfig struct {
+	downloadWorkers int
+	// httpUser is "user:password" for basic auth
+	httpUser  string
+	httpToken string
+	// k8s is set by -k8s for k8s://namespace/pod/container inputs
+	k8s *k8sClient
+}
+
+// openInput opens a log source by name: "-" for stdin, an http(s), s3://,
+// ssh://, docker:// or journal:// URL, or a local path. Gzip-compressed content is decompressed
+// transparently.
+func (la *LogAnalyzer) openInput(name string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	switch {
+	case name == "-":
+		rc = io.NopCloser(os.Stdin)
+	case strings.HasPrefix(name, "s3://"):
+		// Objects are decompressed individually as they are downloaded
+		return openS3(name, la.remote.downloadWorkers)
+	case strings.HasPrefix(name, "k8s://") && la.remote.k8s != nil:
+		body, err := la.remote.k8s.podLogs(name, false)
+		if err != nil {
+			return nil, err
+		}
+		rc = body
+	case strings.HasPrefix(name, "docker://"):
+		body, err := openDocker(name, false)
+		if err != nil {
+			return nil, err
+		}
+		rc = body
+	case strings.HasPrefix(name, "journal://"):
+		body, err := la.openJournal(name, false)
+		if err != nil {
+			return nil, err
+		}
+		rc = body
+	case strings.HasPrefix(name, "ssh://"):
+		body, err := openSSH(name)
+		if err != nil {
+			return nil, err
+		}
+		rc = body
+	case strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://"):
+		body, err := la.openHTTP(name)
+		if err != nil {
+			return nil, err
+		}
+		rc = body
+	default:
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		rc = file
+		if ranges, ok := la.indexRanges(name); ok {
+			// Only the regions the time index says can match are read
+			rc = readCloser{Reader: sectionReader(file, ranges), Closer: file}
+		}
+	}
+
+	if la.progress {
+		rc = newProgressReader(name, rc)
+	}
+
+	r, err := decompress(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return readCloser{Reader: r, Closer: rc}, nil
+}
+
+// httpInputClient fetches http(s) inputs. Connecting and waiting for the
+// response headers time out, so a dead server doesn't hang the run, but
+// there is no overall timeout: large bodies are parsed while they stream.
+var httpInputClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = 60 * time.Second
+	return &http.Client{Transport: transport}
+}()
+
+// openHTTP streams a URL's body. Credentials in the URL itself are used for
+// basic auth unless -http-user or -http-token is given.
+func (la *LogAnalyzer) openHTTP(url string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The environment is read here rather than as the flag defaults, which
+	// -h would print
+	token, userInfo := la.remote.httpToken, la.remote.httpUser
+	if token == "" {
+		token = os.Getenv("LOGANALYZER_HTTP_TOKEN")
+	}
+	if userInfo == "" {
+		userInfo = os.Getenv("LOGANALYZER_HTTP_USER")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if user, password, ok := strings.Cut(userInfo, ":"); ok {
+		req.SetBasicAuth(user, password)
+	}
+
+	resp, err := httpInputClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// inputSource is the Source recorded for every entry of an input that
+// names its origin itself: the pod for k8s:// and the container for
+// docker://. It is empty for other inputs, whose lines carry their own.
+func inputSource(name string) string {
+	switch {
+	case strings.HasPrefix(name, "k8s://"):
+		return k8sSource(name)
+	case strings.HasPrefix(name, "docker://"):
+		return strings.TrimPrefix(name, "docker://")
+	}
+	return ""
+}
+
+// stdinIsPipe reports whether stdin is a pipe or redirected file rather
+// than a terminal, so `cmd | loganalyzer` can read it without -f -
+func stdinIsPipe() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// stderrIsTerminal reports whether progress lines on stderr would be seen
+// rather than end up in a redirected log
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// defaultSniffLines is how many lines of an input are buffered to pick its
+// format
+const defaultSniffLines = 50
+
+// sniffFormat picks the registered format detecting the most sample lines,
+// preferring the order parseLine tries them in, or "auto" when none detects
+// most of the non-blank lines (mixed inputs are detected line by line)
+func (la *LogAnalyzer) sniffFormat(lines []string) string {
+	nonBlank := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			nonBlank++
+		}
+	}
+	best, bestCount := "auto", nonBlank/2
+	for _, name := range parser.Names() {
+		p, _ := parser.Lookup(name)
+		count := 0
+		for _, line := range lines {
+			if p.Detect(line) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = name, count
+		}
+	}
+	return best
+}
+
+// decompress returns a reader that undoes gzip compression when the stream
+// starts with the gzip magic bytes, and passes anything else through
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// readCloser pairs a wrapping reader with the underlying closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
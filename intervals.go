@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// showIntervals reports the time between consecutive filtered entries, to
+// check that a heartbeat or a scheduled job runs as often as it should
+func (la *LogAnalyzer) showIntervals(entries []LogEntry, top int) {
+	var times []time.Time
+	for _, entry := range entries {
+		if !entry.Timestamp.IsZero() {
+			times = append(times, entry.Timestamp)
+		}
+	}
+	if len(times) < 2 {
+		fmt.Println("Need at least two timestamped entries to measure intervals")
+		return
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	type gap struct {
+		after time.Time
+		d     time.Duration
+	}
+	gaps := make([]gap, len(times)-1)
+	var total time.Duration
+	for i := 1; i < len(times); i++ {
+		gaps[i-1] = gap{times[i-1], times[i].Sub(times[i-1])}
+		total += gaps[i-1].d
+	}
+	sort.SliceStable(gaps, func(i, j int) bool { return gaps[i].d < gaps[j].d })
+	percentile := func(p float64) time.Duration {
+		return gaps[int(p*float64(len(gaps)-1))].d
+	}
+
+	fmt.Printf("=== Intervals between %d Entries ===\n", len(times))
+	fmt.Printf("Min:    %s\n", gaps[0].d)
+	fmt.Printf("Avg:    %s\n", (total / time.Duration(len(gaps))).Round(time.Millisecond))
+	fmt.Printf("Median: %s\n", percentile(0.5))
+	fmt.Printf("P95:    %s\n", percentile(0.95))
+	fmt.Printf("P99:    %s\n", percentile(0.99))
+	fmt.Printf("Max:    %s\n", gaps[len(gaps)-1].d)
+
+	fmt.Println()
+	fmt.Println("Longest Intervals:")
+	for i := len(gaps) - 1; i >= 0 && i >= len(gaps)-top; i-- {
+		g := gaps[i]
+		fmt.Printf("  %-12s %s to %s\n", g.d, g.after.Format("2006-01-02 15:04:05"), g.after.Add(g.d).Format("2006-01-02 15:04:05"))
+	}
+}
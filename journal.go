@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// journalPriorities maps the -level and -min-level filters onto journald
+// priority ranges so journalctl drops other entries before they are
+// converted
+var journalPriorities = map[string]string{
+	"FATAL": "0..2",
+	"ERROR": "3..3",
+	"WARN":  "4..4",
+	"INFO":  "5..6",
+	"DEBUG": "7..7",
+}
+
+// journalMinPriorities holds the least severe priority of each -min-level
+var journalMinPriorities = map[string]string{
+	"FATAL": "2",
+	"ERROR": "3",
+	"WARN":  "4",
+	"INFO":  "6",
+}
+
+// priorityLevel maps a syslog PRIORITY (0 emerg .. 7 debug) to a level
+func priorityLevel(priority string) string {
+	p, err := strconv.Atoi(priority)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case p <= 2:
+		return "FATAL"
+	case p == 3:
+		return "ERROR"
+	case p == 4:
+		return "WARN"
+	case p <= 6:
+		return "INFO"
+	}
+	return "DEBUG"
+}
+
+// journalArgs builds the journalctl arguments for a journal://[unit] input,
+// pushing the level and time filters down to journald
+func (la *LogAnalyzer) journalArgs(name string, follow bool) []string {
+	args := []string{"--output=json", "--no-pager", "--quiet"}
+	if unit := strings.TrimPrefix(name, "journal://"); unit != "" {
+		args = append(args, "--unit", unit)
+	}
+	if priorities, ok := journalPriorities[la.filters.Level]; ok {
+		args = append(args, "--priority", priorities)
+	} else if priority, ok := journalMinPriorities[la.filters.MinLevel]; ok {
+		// A single priority includes the more severe ones
+		args = append(args, "--priority", priority)
+	}
+	if follow {
+		// Only new entries, like -follow on a file
+		args = append(args, "--follow", "--lines=0")
+	} else {
+		if la.filters.StartTime != nil {
+			args = append(args, fmt.Sprintf("--since=@%d", la.filters.StartTime.Unix()))
+		}
+		if la.filters.EndTime != nil {
+			args = append(args, fmt.Sprintf("--until=@%d", la.filters.EndTime.Unix()))
+		}
+	}
+	return args
+}
+
+// journalReader is the converted output of a running journalctl
+type journalReader struct {
+	*io.PipeReader
+	cmd *exec.Cmd
+}
+
+func (r *journalReader) Close() error {
+	r.PipeReader.Close()
+	r.cmd.Process.Kill()
+	r.cmd.Wait()
+	return nil
+}
+
+// openJournal reads the systemd journal through journalctl, optionally for a
+// single unit given as journal://nginx.service. Each record is rewritten as
+// a JSON line with timestamp, level, message and source (the unit, or the
+// syslog identifier for entries outside a unit), which the json format reads.
+func (la *LogAnalyzer) openJournal(name string, follow bool) (io.ReadCloser, error) {
+	cmd := exec.Command("journalctl", la.journalArgs(name, follow)...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting journalctl: %v", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(convertJournal(out, pw))
+	}()
+	return &journalReader{PipeReader: pr, cmd: cmd}, nil
+}
+
+// journalRecord holds the journal fields the analyzer uses. Fields that
+// aren't valid UTF-8 are exported as byte arrays and are skipped.
+type journalRecord struct {
+	Timestamp  string          `json:"__REALTIME_TIMESTAMP"`
+	Priority   string          `json:"PRIORITY"`
+	Message    json.RawMessage `json:"MESSAGE"`
+	Unit       string          `json:"_SYSTEMD_UNIT"`
+	Identifier string          `json:"SYSLOG_IDENTIFIER"`
+}
+
+func convertJournal(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	enc := json.NewEncoder(w)
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		var message string
+		json.Unmarshal(rec.Message, &message)
+
+		out := map[string]string{
+			"level":   priorityLevel(rec.Priority),
+			"message": message,
+			"source":  rec.Unit,
+		}
+		if out["source"] == "" {
+			out["source"] = rec.Identifier
+		}
+		if usec, err := strconv.ParseInt(rec.Timestamp, 10, 64); err == nil {
+			out["timestamp"] = time.UnixMicro(usec).Format(time.RFC3339Nano)
+		}
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// followJournal processes journal entries as they are written
+func (la *LogAnalyzer) followJournal(name string, verbose bool) {
+	r, err := la.openJournal(name, true)
+	if err != nil {
+		log.Fatalf("Error following journal: %v", err)
+	}
+	defer r.Close()
+
+	fmt.Println("Following journal... (Press Ctrl+C to exit)")
+	scanner := la.newLineScanner(r)
+	for scanner.Scan() {
+		if entry := la.parseLine(scanner.Text(), "json"); entry != nil {
+			la.processLive(entry, verbose)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Error following journal: %v", err)
+	}
+	log.Fatal("journalctl exited")
+}
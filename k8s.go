@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// k8sClient talks to the Kubernetes API server: in-cluster with the pod's
+// service account, or through an explicit URL such as `kubectl proxy`
+type k8sClient struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+// newK8sClient uses apiURL when given, otherwise the in-cluster config.
+// $LOGANALYZER_K8S_TOKEN, when set, is sent as a bearer token.
+func newK8sClient(apiURL string) (*k8sClient, error) {
+	c := &k8sClient{base: strings.TrimSuffix(apiURL, "/"), token: os.Getenv("LOGANALYZER_K8S_TOKEN"), client: &http.Client{}}
+	if c.base != "" {
+		return c, nil
+	}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" {
+		return nil, fmt.Errorf("not running in a cluster; pass -k8s-api (e.g. http://127.0.0.1:8001 from kubectl proxy)")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+
+	c.base = "https://" + strings.Trim(host, "[]")
+	if strings.Contains(host, ":") {
+		c.base = "https://[" + strings.Trim(host, "[]") + "]"
+	}
+	c.base += ":" + port
+	c.token = strings.TrimSpace(string(token))
+	c.client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	return c, nil
+}
+
+func (c *k8sClient) get(path string, query url.Values) (io.ReadCloser, error) {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp.Body, nil
+}
+
+// k8sPod is the part of a Pod object the analyzer needs
+type k8sPod struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Name string `json:"name"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+// inputs returns a k8s:// input name per container of the pod, or just the
+// one container when container is set
+func (p k8sPod) inputs(container string) []string {
+	var names []string
+	for _, c := range p.Spec.Containers {
+		if container == "" || c.Name == container {
+			names = append(names, fmt.Sprintf("k8s://%s/%s/%s", p.Metadata.Namespace, p.Metadata.Name, c.Name))
+		}
+	}
+	return names
+}
+
+func (c *k8sClient) listPods(namespace, selector string) ([]k8sPod, error) {
+	body, err := c.get("/api/v1/namespaces/"+url.PathEscape(namespace)+"/pods", url.Values{"labelSelector": {selector}})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var list struct {
+		Items []k8sPod `json:"items"`
+	}
+	if err := json.NewDecoder(body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// parseK8sInput splits k8s://namespace/pod/container
+func parseK8sInput(name string) (namespace, pod, container string, err error) {
+	parts := strings.Split(strings.TrimPrefix(name, "k8s://"), "/")
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("invalid pod input %q", name)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// podLogs opens a container's log stream
+func (c *k8sClient) podLogs(name string, follow bool) (io.ReadCloser, error) {
+	namespace, pod, container, err := parseK8sInput(name)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"container": {container}}
+	if follow {
+		// Only new lines, like -follow on a file
+		query.Set("follow", "true")
+		query.Set("sinceSeconds", "1")
+	}
+	return c.get("/api/v1/namespaces/"+url.PathEscape(namespace)+"/pods/"+url.PathEscape(pod)+"/log", query)
+}
+
+// k8sSource is the Source recorded for entries from a pod input: the pod name
+func k8sSource(name string) string {
+	_, pod, _, err := parseK8sInput(name)
+	if err != nil {
+		return ""
+	}
+	return pod
+}
+
+// followK8s streams new lines from every matching pod, picking up pods that
+// start later through the watch API
+func (la *LogAnalyzer) followK8s(namespace, selector, container, format string, verbose bool) {
+	c := la.remote.k8s
+	lines := make(chan *LogEntry, 1024)
+
+	var mu sync.Mutex
+	streaming := make(map[string]bool)
+	stream := func(name string) {
+		mu.Lock()
+		if streaming[name] {
+			mu.Unlock()
+			return
+		}
+		streaming[name] = true
+		mu.Unlock()
+
+		go func() {
+			defer func() {
+				mu.Lock()
+				delete(streaming, name)
+				mu.Unlock()
+			}()
+			body, err := c.podLogs(name, true)
+			if err != nil {
+				log.Printf("Error streaming %s: %v", name, err)
+				return
+			}
+			defer body.Close()
+			scanner := la.newLineScanner(body)
+			for scanner.Scan() {
+				if entry := la.parseLine(scanner.Text(), format); entry != nil {
+					entry.Source = k8sSource(name)
+					lines <- entry
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for {
+			body, err := c.get("/api/v1/namespaces/"+url.PathEscape(namespace)+"/pods",
+				url.Values{"labelSelector": {selector}, "watch": {"true"}})
+			if err != nil {
+				log.Fatalf("Error watching pods: %v", err)
+			}
+			dec := json.NewDecoder(body)
+			for {
+				var event struct {
+					Type   string `json:"type"`
+					Object k8sPod `json:"object"`
+				}
+				if err := dec.Decode(&event); err != nil {
+					break
+				}
+				if event.Type != "DELETED" && event.Object.Status.Phase == "Running" {
+					for _, name := range event.Object.inputs(container) {
+						stream(name)
+					}
+				}
+			}
+			// Watches time out server-side; start a new one
+			body.Close()
+		}
+	}()
+
+	fmt.Printf("Following pods in %s matching %q... (Press Ctrl+C to exit)\n", namespace, selector)
+	for entry := range lines {
+		la.processLive(entry, verbose)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultMaxLineSize is the -max-line-size default
+const defaultMaxLineSize = 1 << 20
+
+// utf8BOM starts files saved by many Windows tools; left in place it would
+// keep the first line from matching its format
+const utf8BOM = "\ufeff"
+
+// longLines counts the lines cut at -max-line-size
+type longLines struct {
+	max   int
+	count atomic.Int64
+}
+
+// newLineScanner returns a Scanner over the lines of r, without a leading
+// BOM or line ending carriage returns. A line longer than -max-line-size is
+// cut to that size and the rest of it skipped, instead of the scan stopping
+// with bufio.ErrTooLong.
+func (la *LogAnalyzer) newLineScanner(r io.Reader) *bufio.Scanner {
+	return la.long.newScanner(r)
+}
+
+// newScanner is newLineScanner cutting lines at l's limit and counting
+// them in l, for readers outside an analyzer
+func (l *longLines) newScanner(r io.Reader) *bufio.Scanner {
+	max := l.limit()
+	skipping := false
+	first := true
+	split := func(data []byte, atEOF bool) (int, []byte, error) {
+		if skipping {
+			if i := bytes.IndexByte(data, '\n'); i >= 0 {
+				skipping = false
+				return i + 1, nil, nil
+			}
+			return len(data), nil, nil
+		}
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance == 0 && token == nil && err == nil && len(data) >= max {
+			l.count.Add(1)
+			skipping = true
+			advance, token = max, data[:max]
+		}
+		if token != nil {
+			if first {
+				token = bytes.TrimPrefix(token, []byte(utf8BOM))
+				first = false
+			}
+			token = bytes.TrimRight(token, "\r")
+		}
+		return advance, token, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	initial := 64 * 1024
+	if max+1 < initial {
+		initial = max + 1
+	}
+	scanner.Buffer(make([]byte, initial), max+1)
+	scanner.Split(split)
+	return scanner
+}
+
+// trimLine removes the line ending of a line read with ReadString, and the
+// BOM when the line starts the file
+func trimLine(line string, atStart bool) string {
+	if atStart {
+		line = strings.TrimPrefix(line, utf8BOM)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// clip cuts a line read without a Scanner to -max-line-size
+func (l *longLines) clip(line string) string {
+	if max := l.limit(); len(line) > max {
+		l.count.Add(1)
+		return line[:max]
+	}
+	return line
+}
+
+func (l *longLines) limit() int {
+	if l.max < 1 {
+		return defaultMaxLineSize
+	}
+	return l.max
+}
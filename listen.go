@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxSyslogMessage bounds one message on stream transports
+const maxSyslogMessage = 64 * 1024
+
+// readSyslogFrames splits a syslog stream into messages, accepting both
+// octet-counted ("123 <PRI>...") and newline-delimited framing (RFC 6587)
+func readSyslogFrames(r io.Reader, fn func(string)) error {
+	br := bufio.NewReader(r)
+	for {
+		first, err := br.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if first[0] >= '1' && first[0] <= '9' {
+			count, err := br.ReadString(' ')
+			if err != nil {
+				return err
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil || n > maxSyslogMessage {
+				return fmt.Errorf("invalid syslog frame length %q", count)
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return err
+			}
+			fn(string(buf))
+			continue
+		}
+
+		line, err := br.ReadString('\n')
+		if line != "" && line != "\n" {
+			fn(line)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// serveStream accepts connections and hands each one to handle
+func serveStream(ln net.Listener, handle func(net.Conn)) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error accepting connection: %v", err)
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			handle(conn)
+		}()
+	}
+}
+
+// listenSyslog starts the requested syslog transports, sending every
+// received message to out
+func listenSyslog(addr string, protocols []string, certFile, keyFile string, out chan<- string) error {
+	streamHandler := func(conn net.Conn) {
+		if err := readSyslogFrames(conn, func(msg string) { out <- strings.TrimRight(msg, "\r\n\x00") }); err != nil {
+			log.Printf("Error reading from %s: %v", conn.RemoteAddr(), err)
+		}
+	}
+
+	for _, proto := range protocols {
+		switch proto {
+		case "udp":
+			pc, err := net.ListenPacket("udp", addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				buf := make([]byte, maxSyslogMessage)
+				for {
+					n, _, err := pc.ReadFrom(buf)
+					if err != nil {
+						log.Printf("Error reading UDP: %v", err)
+						return
+					}
+					out <- strings.TrimRight(string(buf[:n]), "\r\n\x00")
+				}
+			}()
+		case "tcp":
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			go serveStream(ln, streamHandler)
+		case "tls":
+			if certFile == "" || keyFile == "" {
+				return fmt.Errorf("tls requires -tls-cert and -tls-key")
+			}
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return err
+			}
+			ln, err := tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{cert}})
+			if err != nil {
+				return err
+			}
+			go serveStream(ln, streamHandler)
+		default:
+			return fmt.Errorf("unknown protocol %q (udp, tcp, tls)", proto)
+		}
+	}
+	return nil
+}
+
+// listenLines accepts newline-delimited logs on a stream listener, sending
+// every line to out
+func listenLines(ln net.Listener, out chan<- string) {
+	serveStream(ln, func(conn net.Conn) {
+		scanner := bufio.NewScanner(conn)
+		scanner.Buffer(make([]byte, 64*1024), maxSyslogMessage)
+		for scanner.Scan() {
+			out <- scanner.Text()
+		}
+		if err := scanner.Err(); err != nil {
+			log.Printf("Error reading from %s: %v", conn.RemoteAddr(), err)
+		}
+	})
+}
+
+// runListen turns the analyzer into a collector: messages received over the
+// network go through the same filters, output and alerting as -follow
+func runListen(args []string) {
+	if len(args) == 0 || (args[0] != "syslog" && args[0] != "tcp" && args[0] != "unix" && args[0] != "redis") {
+		fmt.Println("Usage: loganalyzer listen syslog [-addr :5514] [-proto udp,tcp,tls] [options]")
+		fmt.Println("       loganalyzer listen tcp [-addr :5170] [-format ...] [options]")
+		fmt.Println("       loganalyzer listen unix -socket /run/loganalyzer.sock [-format ...] [options]")
+		fmt.Println("       loganalyzer listen redis [-addr localhost:6379] -stream <key> | -channel <name> [options]")
+		os.Exit(1)
+	}
+	kind := args[0]
+
+	fs := flag.NewFlagSet("listen "+kind, flag.ExitOnError)
+	input := addInputFlags(fs)
+	live := addLiveFlags(fs)
+	defaultAddr := map[string]string{"syslog": ":5514", "redis": "localhost:6379"}[kind]
+	if defaultAddr == "" {
+		defaultAddr = ":5170"
+	}
+	addr := fs.String("addr", defaultAddr, "Address to listen on")
+	socket := fs.String("socket", "", "Unix socket path for listen unix")
+	protos := fs.String("proto", "udp,tcp", "Comma-separated syslog transports: udp, tcp, tls")
+	certFile := fs.String("tls-cert", "", "Certificate file for -proto tls")
+	keyFile := fs.String("tls-key", "", "Key file for -proto tls")
+	stream := fs.String("stream", "", "Redis stream key to follow (listen redis)")
+	channel := fs.String("channel", "", "Redis pub/sub channel or pattern, comma-separated for several (listen redis)")
+	field := fs.String("field", "message", "Redis stream field holding the log line")
+	redisDB := fs.Int("db", 0, "Redis database number (password from $LOGANALYZER_REDIS_PASSWORD)")
+	verbose := fs.Bool("v", false, "Verbose output")
+	parseFlags(fs, args[1:])
+
+	analyzer := input.newAnalyzer()
+	live.apply(analyzer)
+
+	// Connections deliver concurrently; one consumer keeps output and
+	// alert state single-threaded
+	messages := make(chan string, 1024)
+	format := *input.format
+	if kind == "syslog" && format == "auto" {
+		format = "syslog"
+	}
+	parse := func(line string) *LogEntry {
+		return analyzer.parseLine(line, format)
+	}
+
+	switch kind {
+	case "syslog":
+		if err := listenSyslog(*addr, strings.Split(*protos, ","), *certFile, *keyFile, messages); err != nil {
+			log.Fatalf("Error starting listener: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Listening for syslog on %s (%s)\n", *addr, *protos)
+	case "tcp":
+		ln, err := net.Listen("tcp", *addr)
+		if err != nil {
+			log.Fatalf("Error starting listener: %v", err)
+		}
+		go listenLines(ln, messages)
+		fmt.Fprintf(os.Stderr, "Listening for log lines on %s\n", *addr)
+	case "unix":
+		if *socket == "" {
+			log.Fatal("listen unix requires -socket")
+		}
+		// A socket left behind by a previous run would make Listen fail
+		if info, err := os.Lstat(*socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(*socket)
+		}
+		ln, err := net.Listen("unix", *socket)
+		if err != nil {
+			log.Fatalf("Error starting listener: %v", err)
+		}
+		go listenLines(ln, messages)
+		fmt.Fprintf(os.Stderr, "Listening for log lines on %s\n", *socket)
+	case "redis":
+		if (*stream == "") == (*channel == "") {
+			log.Fatal("listen redis requires one of -stream or -channel")
+		}
+		conn, err := dialRedis(*addr, os.Getenv("LOGANALYZER_REDIS_PASSWORD"), *redisDB)
+		if err != nil {
+			log.Fatalf("Error connecting to Redis: %v", err)
+		}
+		go func() {
+			if *stream != "" {
+				err = readRedisStream(conn, *stream, *field, messages)
+			} else {
+				err = subscribeRedis(conn, strings.Split(*channel, ","), messages)
+			}
+			log.Fatalf("Error reading from Redis: %v", err)
+		}()
+		fmt.Fprintf(os.Stderr, "Reading from Redis %s\n", *addr)
+	}
+
+	for msg := range messages {
+		if entry := parse(msg); entry != nil {
+			analyzer.processLive(entry, *verbose)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSyslogFrames(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		err   bool
+	}{
+		{"octet counted", "5 hello6 world!", []string{"hello", "world!"}, false},
+		{"octet counted with newlines", "8 <13>a\nb\n3 <1>", []string{"<13>a\nb\n", "<1>"}, false},
+		{"newline delimited", "<13>a\n<14>b\n", []string{"<13>a\n", "<14>b\n"}, false},
+		{"blank lines skipped", "<13>a\n\n<14>b", []string{"<13>a\n", "<14>b"}, false},
+		{"mixed", "<13>a\n4 <1>b<2>c\n", []string{"<13>a\n", "<1>b", "<2>c\n"}, false},
+		{"empty", "", nil, false},
+		{"short frame", "10 abc", nil, true},
+		{"length too large", "70000 x", nil, true},
+		{"length without a space", "12", nil, true},
+	}
+	for _, tt := range tests {
+		var got []string
+		err := readSyslogFrames(strings.NewReader(tt.input), func(msg string) {
+			got = append(got, msg)
+		})
+		if (err != nil) != tt.err {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: frames = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
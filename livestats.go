@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+	"github.com/hrabid/log-analyzer/pkg/stats"
+)
+
+// liveTopErrors is how many error templates the live panel lists
+const liveTopErrors = 5
+
+type liveEvent struct {
+	at       time.Time
+	level    string
+	template string
+}
+
+// LiveStats keeps a sliding window of recent entries for the follow-mode
+// dashboard. The window is measured by arrival time, not log timestamps, so
+// it reflects what is happening now.
+type LiveStats struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	events []liveEvent
+	start  time.Time
+}
+
+func NewLiveStats(window time.Duration) *LiveStats {
+	return &LiveStats{window: window, start: time.Now()}
+}
+
+// Add records an entry that just arrived
+func (s *LiveStats) Add(entry LogEntry) {
+	ev := liveEvent{at: time.Now(), level: entry.Level}
+	if parser.IsError(entry.Level) {
+		ev.template = stats.NormalizeMessage(entry.Message)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, ev)
+}
+
+// prune drops events older than the window; callers hold the lock
+func (s *LiveStats) prune(now time.Time) {
+	drop := 0
+	for drop < len(s.events) && now.Sub(s.events[drop].at) > s.window {
+		drop++
+	}
+	s.events = s.events[drop:]
+}
+
+// Render writes the dashboard. As a panel it lists top errors; otherwise
+// it's a single summary line suitable for logs.
+func (s *LiveStats) Render(w io.Writer, panel bool) {
+	now := time.Now()
+
+	s.mu.Lock()
+	s.prune(now)
+	levels := make(map[string]int)
+	errors := make(map[string]int)
+	for _, ev := range s.events {
+		levels[ev.level]++
+		if ev.template != "" {
+			errors[ev.template]++
+		}
+	}
+	total := len(s.events)
+	s.mu.Unlock()
+
+	// Until a full window has passed, rates are over the time seen so far
+	span := s.window
+	if elapsed := now.Sub(s.start); elapsed < span {
+		span = elapsed
+	}
+	rate := 0.0
+	if span > 0 {
+		rate = float64(total) / span.Seconds()
+	}
+	errorCount := levels["ERROR"] + levels["FATAL"]
+	errorRate := 0.0
+	if total > 0 {
+		errorRate = float64(errorCount) / float64(total) * 100
+	}
+
+	names := make([]string, 0, len(levels))
+	for level := range levels {
+		names = append(names, level)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, level := range names {
+		label := level
+		if label == "" {
+			label = "-"
+		}
+		parts[i] = fmt.Sprintf("%s=%d", label, levels[level])
+	}
+
+	if !panel {
+		fmt.Fprintf(w, "%s last %s: %d entries (%.1f/s) errors %.1f%% %s\n",
+			now.Format("15:04:05"), s.window, total, rate, errorRate, strings.Join(parts, " "))
+		return
+	}
+
+	// Clear the screen and redraw from the top
+	fmt.Fprint(w, "\033[H\033[2J")
+	fmt.Fprintf(w, "=== Live (last %s, %s) ===\n", s.window, now.Format("15:04:05"))
+	fmt.Fprintf(w, "Entries:    %d (%.1f/s)\n", total, rate)
+	fmt.Fprintf(w, "Error rate: %.1f%%\n", errorRate)
+	fmt.Fprintf(w, "Levels:     %s\n", strings.Join(parts, " "))
+
+	if len(errors) > 0 {
+		fmt.Fprintln(w, "\nTop errors:")
+		templates := make([]string, 0, len(errors))
+		for t := range errors {
+			templates = append(templates, t)
+		}
+		sort.Slice(templates, func(i, j int) bool {
+			if errors[templates[i]] != errors[templates[j]] {
+				return errors[templates[i]] > errors[templates[j]]
+			}
+			return templates[i] < templates[j]
+		})
+		for i, t := range templates {
+			if i == liveTopErrors {
+				break
+			}
+			fmt.Fprintf(w, "  %5d  %s\n", errors[t], t)
+		}
+	}
+}
+
+// Run redraws the dashboard on stdout every interval, as a panel when
+// stdout is a terminal
+func (s *LiveStats) Run(interval time.Duration) {
+	panel := false
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		panel = true
+	}
+	for range time.Tick(interval) {
+		s.Render(os.Stdout, panel)
+	}
+}
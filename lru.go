@@ -0,0 +1,49 @@
+package main
+
+import "container/list"
+
+// lookupCacheSize bounds the per-address caches of the GeoIP and reverse
+// DNS lookups, which would otherwise grow with every client a long follow
+// or listen sees
+const lookupCacheSize = 100000
+
+// lruCache maps keys to values, dropping the least recently used entry
+// once it holds max. It is not safe for concurrent use.
+type lruCache[K comparable, V any] struct {
+	max   int
+	order *list.List
+	items map[K]*list.Element
+}
+
+type lruItem[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLRUCache[K comparable, V any](max int) *lruCache[K, V] {
+	return &lruCache[K, V]{max: max, order: list.New(), items: make(map[K]*list.Element)}
+}
+
+func (c *lruCache[K, V]) Get(key K) (V, bool) {
+	e, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruItem[K, V]).value, true
+}
+
+func (c *lruCache[K, V]) Put(key K, value V) {
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruItem[K, V]).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruItem[K, V]{key: key, value: value})
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem[K, V]).key)
+	}
+}
@@ -2,354 +2,857 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"regexp"
+	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
-)
 
-// LogEntry represents a parsed log entry
-type LogEntry struct {
-	Timestamp time.Time
-	Level     string
-	Message   string
-	Source    string
-	Raw       string
-}
+	"github.com/hrabid/log-analyzer/pkg/filter"
+	"github.com/hrabid/log-analyzer/pkg/output"
+	"github.com/hrabid/log-analyzer/pkg/parser"
+	"github.com/hrabid/log-analyzer/pkg/stats"
+)
 
-// LogStats holds statistics about the log file
-type LogStats struct {
-	TotalLines   int
-	ErrorCount   int
-	WarnCount    int
-	InfoCount    int
-	DebugCount   int
-	TimeRange    string
-	TopSources   map[string]int
-	TopErrors    map[string]int
-}
+// The entry and filter types live in pkg so other programs can embed the
+// parsing and analysis without the CLI
+type (
+	LogEntry      = parser.Entry
+	AccessInfo    = parser.AccessInfo
+	UserAgentInfo = parser.UserAgentInfo
+	GeoLocation   = parser.GeoLocation
+	Filters       = filter.Filter
+)
 
 // LogAnalyzer handles log parsing and analysis
 type LogAnalyzer struct {
-	entries   []LogEntry
-	patterns  map[string]*regexp.Regexp
-	filters   Filters
+	entries  []LogEntry
+	filters  Filters
+	geoip    *GeoIPReader
+	resolver *DNSResolver
+	redactor *Redactor
+	alerts   *AlertEngine
+	notify   *batchNotifier
+	live     *LiveStats
+	flusher  *statsFlusher
+	rate     *ingestRate
+	remote   remoteConfig
+	// transforms are the -plugin modules and -script files run on every
+	// parsed entry
+	transforms []transformer
+	// warned holds the transforms whose first error was reported
+	warned sync.Map
+	// derived are the -derive fields set on every parsed entry
+	derived []derivation
+	// levelMap renames parsed levels (-level-map), keyed in upper case
+	levelMap map[string]string
+	// progress shows a progress line on stderr while inputs are read
+	progress bool
+	// mmap reads local files through a memory mapping
+	mmap bool
+	// state records the follow position of local files (-state)
+	state *followState
+	// followTail is how many of the entries already in a followed file are
+	// printed before the new ones (-tail with -follow)
+	followTail int
+	// followSince and followFromStart move the start of a followed file
+	// back from its end (see followStart)
+	followSince     time.Duration
+	followFromStart bool
+	// unparsed counts the lines no format read; with strict the first one
+	// is an error
+	unparsed parseFailures
+	strict   bool
+	// long cuts lines at -max-line-size and counts them
+	long longLines
+	// binary sanitizes or skips lines with binary content (-binary)
+	binary binaryLines
+	// sniffLines is how many lines of each input pick its format in auto
+	// mode (-sniff-lines); 0 detects every line on its own
+	sniffLines int
+	// verbose reports the format picked for each input
+	verbose bool
 }
 
-// Filters contains filtering options
-type Filters struct {
-	Level     string
-	StartTime *time.Time
-	EndTime   *time.Time
-	Source    string
-	Keyword   string
-}
-
-// Common log patterns
-var logPatterns = map[string]string{
-	"apache":   `^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d+) (\d+)`,
-	"nginx":    `^(\S+) - - \[([^\]]+)\] "([^"]*)" (\d+) (\d+) "([^"]*)" "([^"]*)"`,
-	"syslog":   `^(\w+\s+\d+\s+\d+:\d+:\d+) (\S+) ([^:]+): (.*)`,
-	"generic":  `^(\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2})\s+\[(\w+)\]\s+(.*)`,
-	"json":     `^\{.*\}$`,
+// subcommands maps a leading argument to its handler; anything else is
+// handled by the flag-driven analyzer in main
+var subcommands = map[string]func(args []string){
+	"analyze":    runAnalyze,
+	"stats":      runStats,
+	"follow":     runFollow,
+	"index":      runIndex,
+	"search":     runSearch,
+	"detect":     runDetect,
+	"validate":   runValidate,
+	"bench":      runBench,
+	"trace":      runTrace,
+	"diff":       runDiff,
+	"split":      runSplit,
+	"convert":    runConvert,
+	"replay":     runReplay,
+	"scan":       runScan,
+	"bruteforce": runBruteforce,
+	"serve":      runServe,
+	"listen":     runListen,
 }
 
 func main() {
-	var (
-		filename   = flag.String("f", "", "Log file to analyze")
-		format     = flag.String("format", "auto", "Log format (apache, nginx, syslog, generic, json, auto)")
-		level      = flag.String("level", "", "Filter by log level (ERROR, WARN, INFO, DEBUG)")
-		source     = flag.String("source", "", "Filter by source/component")
-		keyword    = flag.String("keyword", "", "Filter by keyword in message")
-		startTime  = flag.String("start", "", "Start time filter (YYYY-MM-DD HH:MM:SS)")
-		endTime    = flag.String("end", "", "End time filter (YYYY-MM-DD HH:MM:SS)")
-		stats      = flag.Bool("stats", false, "Show statistics")
-		tail       = flag.Int("tail", 0, "Show last N lines")
-		head       = flag.Int("head", 0, "Show first N lines")
-		follow     = flag.Bool("follow", false, "Follow log file (like tail -f)")
-		output     = flag.String("output", "", "Output format (json, csv)")
-		verbose    = flag.Bool("v", false, "Verbose output")
-	)
-	flag.Parse()
-
-	if *filename == "" {
-		fmt.Println("Usage: loganalyzer -f <logfile> [options]")
-		flag.PrintDefaults()
+	defer profiles.stop()
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(os.Args[2:])
+			return
+		}
+	}
+
+	// Without a command the flags of analyze and follow are accepted
+	// together, as they were before the commands were split out
+	input := addInputFlags(flag.CommandLine)
+	live := addLiveFlags(flag.CommandLine)
+	opts := addAnalyzeFlags(flag.CommandLine)
+	followFlag := flag.Bool("follow", false, "Follow log file (like tail -f); same as the follow command")
+	start := addFollowFlags(flag.CommandLine)
+	parseFlags(flag.CommandLine, os.Args[1:])
+
+	if !input.hasFiles() {
+		usage()
 		os.Exit(1)
 	}
+	recordHistory(os.Args[1:])
+	if *followFlag {
+		follow(input, live, *opts.verbose, start, *opts.tail)
+		return
+	}
+	opts.run(flag.CommandLine, input)
+}
 
-	analyzer := NewLogAnalyzer()
+func usage() {
+	fmt.Println("Usage: loganalyzer <command> [options]")
+	fmt.Println("       loganalyzer -f <logfile> [options]    analyze, or follow with -follow")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  analyze      Filter and list entries or print a report (-stats, -error-rate, -templates, ...)")
+	fmt.Println("  stats        Show summary statistics")
+	fmt.Println("  follow       Print new entries as they arrive, from a file, -docker, journal:// or -k8s")
+	fmt.Println("  validate     Parse without output and report unmatched lines and empty fields")
+	fmt.Println("  index        Build a time index so -start/-end skip to the matching part of a file (-full-text for search)")
+	fmt.Println("  search       Print the entries whose message contains a text, using the index -full-text builds")
+	fmt.Println("  detect       Find time buckets with anomalous error counts, or report format detection (detect format)")
+	fmt.Println("  trace        Collect the entries of one request ID across files")
+	fmt.Println("  diff         Compare two logs or time windows")
+	fmt.Println("  split        Split entries into files by day, hour, source or level")
+	fmt.Println("  convert      Convert entries between formats")
+	fmt.Println("  replay       Replay entries with their original timing")
+	fmt.Println("  scan         Find leaked secrets (scan secrets)")
+	fmt.Println("  bruteforce   Find clients with repeated authentication failures")
+	fmt.Println("  serve        Serve a web UI and search API")
+	fmt.Println("  listen       Receive logs over syslog, TCP, a unix socket or Redis")
+	fmt.Println("  bench        Measure read, parse, filter and output throughput")
+	fmt.Println("  query        Save, run and list named queries")
+	fmt.Println("  completion   Print a bash, zsh or fish completion script")
+	fmt.Println()
+	fmt.Println("Run 'loganalyzer <command> -h' for the options of a command.")
+}
 
-	// Parse time filters
-	filters := Filters{
-		Level:   strings.ToUpper(*level),
-		Source:  *source,
-		Keyword: *keyword,
+func NewLogAnalyzer() *LogAnalyzer {
+	return &LogAnalyzer{
+		entries: make([]LogEntry, 0),
 	}
+}
 
-	if *startTime != "" {
-		if t, err := time.Parse("2006-01-02 15:04:05", *startTime); err == nil {
-			filters.StartTime = &t
-		} else {
-			log.Fatalf("Invalid start time format: %v", err)
-		}
+// fileList collects the values of a repeatable flag
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set adds a file; a directory adds every regular file directly inside it
+// except hidden files and time indexes
+func (f *fileList) Set(value string) error {
+	info, err := os.Stat(value)
+	if err != nil || !info.IsDir() {
+		*f = append(*f, value)
+		return nil
 	}
 
-	if *endTime != "" {
-		if t, err := time.Parse("2006-01-02 15:04:05", *endTime); err == nil {
-			filters.EndTime = &t
-		} else {
-			log.Fatalf("Invalid end time format: %v", err)
+	dirEntries, err := os.ReadDir(value)
+	if err != nil {
+		return err
+	}
+	added := 0
+	for _, d := range dirEntries {
+		if d.Type().IsRegular() && !strings.HasPrefix(d.Name(), ".") && !strings.HasSuffix(d.Name(), indexSuffix) && !strings.HasSuffix(d.Name(), textSuffix) {
+			*f = append(*f, filepath.Join(value, d.Name()))
+			added++
 		}
 	}
+	if added == 0 {
+		return fmt.Errorf("no log files in directory %s", value)
+	}
+	return nil
+}
 
-	analyzer.filters = filters
+// inputOptions holds the flags shared by every mode that reads log files
+type inputOptions struct {
+	files       fileList
+	format      *string
+	level       *string
+	source      *string
+	keywords    []string
+	keywordMode *string
+	caseSens    *bool
+	words       *bool
+	fuzzy       *string
+	fuzzyEdits  *int
+	startTime   *string
+	endTime     *string
+	geoip       *string
+	country     *string
+	referrer    *string
+	botOnly     *bool
+	excludeBots *bool
+	rdns        *bool
+	rdnsWorkers *int
+	rdnsTimeout *time.Duration
+	redact      *string
+	redactMode  *string
+	redactKey   *string
+	downloads   *int
+	httpUser    *string
+	httpToken   *string
+	k8s         *bool
+	namespace   *string
+	selector    *string
+	container   *string
+	k8sAPI      *string
+	progress    *bool
+	mmap        *bool
+	strict      *bool
+	rejects     *string
+	maxLine     byteSize
+	binary      *string
+	plugins     fileList
+	scripts     fileList
+	derived     []derivation
+	levelMap    map[string]string
+	where       []filter.Condition
+	fileFilter  *string
+	minLevel    *string
+	sniffLines  *int
+}
 
-	if *follow {
-		analyzer.followFile(*filename, *format, *verbose)
-	} else {
-		if err := analyzer.parseFile(*filename, *format); err != nil {
-			log.Fatalf("Error parsing file: %v", err)
+func addInputFlags(fs *flag.FlagSet) *inputOptions {
+	o := &inputOptions{
+		format:      fs.String("format", "auto", "Log format ("+strings.Join(parser.Names(), ", ")+", auto)"),
+		level:       fs.String("level", "", "Filter by log level ("+strings.Join(parser.Levels, ", ")+")"),
+		source:      fs.String("source", "", "Filter by source/component"),
+		keywordMode: fs.String("keyword-mode", "any", "Whether entries need any or all of the -keyword values"),
+		fuzzy:       fs.String("fuzzy", "", "Filter by approximate text in message, e.g. \"conection refussed\" (see -fuzzy-edits)"),
+		fuzzyEdits:  fs.Int("fuzzy-edits", 0, "Typos (inserted, deleted or changed characters) -fuzzy allows; 0 allows one per five characters"),
+		words:       fs.Bool("word", false, "Match -keyword values only as whole words, so err doesn't match transferred"),
+		caseSens:    fs.Bool("case-sensitive", false, "Match -keyword, -source, -referrer and -fail-on-match with their exact case"),
+		startTime:   fs.String("start", "", "Start time filter (YYYY-MM-DD HH:MM:SS)"),
+		endTime:     fs.String("end", "", "End time filter (YYYY-MM-DD HH:MM:SS)"),
+		geoip:       fs.String("geoip", "", "MaxMind DB (e.g. GeoLite2-City.mmdb) used to annotate client IPs"),
+		country:     fs.String("country", "", "Filter by client country ISO code or name (requires -geoip)"),
+		referrer:    fs.String("referrer", "", "Filter access log requests by referrer (substring, e.g. google.com)"),
+		botOnly:     fs.Bool("bot-only", false, "Only show access log requests from bots and crawlers"),
+		excludeBots: fs.Bool("exclude-bots", false, "Hide access log requests from bots and crawlers"),
+		rdns:        fs.Bool("rdns", false, "Annotate client IPs with their reverse DNS hostname"),
+		rdnsWorkers: fs.Int("rdns-workers", 16, "Maximum concurrent reverse DNS lookups"),
+		rdnsTimeout: fs.Duration("rdns-timeout", 2*time.Second, "Timeout for a single reverse DNS lookup"),
+		redact:      fs.String("redact", "", "Redact sensitive values as entries are read, so no output or report shows them: comma-separated email, ip, phone, creditcard or all"),
+		redactMode:  fs.String("redact-mode", "mask", "Redaction mode: mask (fixed placeholder) or hash (consistent pseudonym)"),
+		redactKey:   fs.String("redact-key", "", "Secret key for -redact-mode hash, so pseudonyms can't be reversed by guessing"),
+	}
+	o.downloads = fs.Int("download-workers", 4, "Objects requested ahead in parallel when reading an s3:// prefix")
+	o.httpUser = fs.String("http-user", "", "user:password for basic auth on http(s):// inputs (default $LOGANALYZER_HTTP_USER)")
+	o.httpToken = fs.String("http-token", "", "Bearer token for http(s):// inputs (default $LOGANALYZER_HTTP_TOKEN)")
+	o.progress = fs.Bool("progress", true, "Show bytes read, throughput and ETA on stderr while reading inputs (only when stderr is a terminal)")
+	o.mmap = fs.Bool("mmap", false, "Read local uncompressed files through a memory mapping instead of buffered reads (fewer copies and syscalls on very large files)")
+	o.strict = fs.Bool("strict", false, "Fail (exit 1) at the first line no format matches instead of keeping it as a plain-text entry")
+	o.rejects = fs.String("rejects", "", "Write the lines no format matches (kept as plain text) to this file, e.g. to refine a custom pattern until it stays empty")
+	o.maxLine = defaultMaxLineSize
+	fs.Var(&o.maxLine, "max-line-size", "Longest line kept whole (e.g. 10MB); longer lines are cut to this size and counted in -stats")
+	o.binary = fs.String("binary", "sanitize", "Lines with NUL bytes, control characters or invalid UTF-8: sanitize (escape them as \\xNN), skip or keep")
+	o.sniffLines = fs.Int("sniff-lines", defaultSniffLines, "With -format auto, lines of each input sampled to pick its format (0 detects every line on its own)")
+	o.minLevel = fs.String("min-level", "", "Only entries at least this severe (e.g. WARN keeps WARN, ERROR and FATAL)")
+	o.fileFilter = fs.String("file-filter", "", "Only entries read from inputs matching these comma-separated globs, e.g. 'web-*.log,*.gz' (full path or base name)")
+	o.k8s = fs.Bool("k8s", false, "Read the logs of Kubernetes pods matching -namespace and -selector")
+	o.namespace = fs.String("namespace", "default", "Kubernetes namespace for -k8s")
+	o.selector = fs.String("selector", "", "Label selector for -k8s pods (e.g. app=web)")
+	o.container = fs.String("container", "", "Only read this container of each pod (default all)")
+	o.k8sAPI = fs.String("k8s-api", "", "Kubernetes API URL, e.g. from kubectl proxy (default in-cluster config; token from $LOGANALYZER_K8S_TOKEN)")
+	fs.Var(&o.files, "f", "Log file, directory, http(s)/s3/ssh URL or journal://[unit] to analyze, - for stdin (repeat for several)")
+	fs.Var(&o.plugins, "plugin", "WebAssembly parser/transform plugin (.wasm) or a directory of them (repeat for several)")
+	fs.Var(&o.scripts, "script", "Lua script whose transform(entry) rewrites, re-levels or drops entries (repeat for several)")
+	fs.Func("derive", `Add a field computed from others, e.g. latency_ms=duration(message, "took %s"); functions extract, scan, duration, lower, upper, len (repeat for several)`, func(expr string) error {
+		d, err := parseDerivation(expr)
+		o.derived = append(o.derived, d)
+		return err
+	})
+	fs.Func("level-map", "Rename levels after parsing so sources agree, e.g. SEVERE=ERROR,FINE=DEBUG,50=ERROR (repeat for several)", func(value string) error {
+		if o.levelMap == nil {
+			o.levelMap = make(map[string]string)
 		}
-
-		filteredEntries := analyzer.filterEntries()
-
-		if *stats {
-			analyzer.showStats()
-			return
+		for _, pair := range strings.Split(value, ",") {
+			from, to, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(from) == "" {
+				return fmt.Errorf("expected FROM=TO, got %q", pair)
+			}
+			o.levelMap[strings.ToUpper(strings.TrimSpace(from))] = strings.ToUpper(strings.TrimSpace(to))
 		}
-
-		if *head > 0 {
-			filteredEntries = analyzer.getHead(filteredEntries, *head)
-		} else if *tail > 0 {
-			filteredEntries = analyzer.getTail(filteredEntries, *tail)
+		return nil
+	})
+	fs.Func("keyword", "Filter by keyword in message (repeat for several, see -keyword-mode)", func(keyword string) error {
+		o.keywords = append(o.keywords, keyword)
+		return nil
+	})
+	fs.Func("where", "Only entries whose field compares true, e.g. latency_ms>500 or status!=200; fields include -derive ones (repeat for several)", func(expr string) error {
+		c, err := filter.ParseCondition(expr)
+		o.where = append(o.where, c)
+		return err
+	})
+	fs.Func("docker", "Read a container's logs through the Docker API on $DOCKER_HOST (repeat for several)", func(name string) error {
+		o.files = append(o.files, "docker://"+name)
+		return nil
+	})
+	fs.Func("log4j", "Log4j/Logback PatternLayout the logs were written with, e.g. '%d{ISO8601} [%t] %-5p %c - %m%n'; adds the log4j format, used unless -format names another", func(layout string) error {
+		if _, taken := parser.Lookup("log4j"); taken {
+			return errors.New("only one layout can be given")
 		}
-
-		analyzer.outputEntries(filteredEntries, *output, *verbose)
-	}
+		p, err := parser.NewLog4jParser(layout)
+		if err != nil {
+			return err
+		}
+		parser.Register("log4j", p)
+		if *o.format == "auto" {
+			*o.format = "log4j"
+		}
+		return nil
+	})
+	return o
 }
 
-func NewLogAnalyzer() *LogAnalyzer {
-	analyzer := &LogAnalyzer{
-		entries:  make([]LogEntry, 0),
-		patterns: make(map[string]*regexp.Regexp),
+func (o *inputOptions) buildFilters() Filters {
+	filters := Filters{
+		Level:         strings.ToUpper(*o.level),
+		MinLevel:      strings.ToUpper(*o.minLevel),
+		Source:        *o.source,
+		Keywords:      o.keywords,
+		Words:         *o.words,
+		Fuzzy:         *o.fuzzy,
+		FuzzyEdits:    *o.fuzzyEdits,
+		CaseSensitive: *o.caseSens,
+		Country:       *o.country,
+		Referrer:      *o.referrer,
+		BotOnly:       *o.botOnly,
+		ExcludeBots:   *o.excludeBots,
+		Where:         o.where,
 	}
-
-	// Compile regex patterns
-	for name, pattern := range logPatterns {
-		if regex, err := regexp.Compile(pattern); err == nil {
-			analyzer.patterns[name] = regex
+	if filters.FuzzyEdits <= 0 {
+		filters.FuzzyEdits = filter.FuzzyDistance(filters.Fuzzy)
+	}
+	switch *o.keywordMode {
+	case "any":
+	case "all":
+		filters.KeywordsAll = true
+	default:
+		log.Fatalf("Invalid -keyword-mode %q (any or all)", *o.keywordMode)
+	}
+	if filters.MinLevel != "" && parser.LevelRank(filters.MinLevel) < 0 {
+		log.Fatalf("Invalid -min-level %q (%s)", *o.minLevel, strings.Join(parser.Levels, ", "))
+	}
+	if *o.fileFilter != "" {
+		for _, pattern := range strings.Split(*o.fileFilter, ",") {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				log.Fatalf("Invalid -file-filter pattern %q: %v", pattern, err)
+			}
+			filters.Files = append(filters.Files, pattern)
 		}
 	}
 
-	return analyzer
+	filters.StartTime = parseTimeFilter(*o.startTime, "start")
+	filters.EndTime = parseTimeFilter(*o.endTime, "end")
+
+	return filters
 }
 
-func (la *LogAnalyzer) parseFile(filename, format string) error {
-	file, err := os.Open(filename)
+// parseTimeFilter parses a -start/-end style flag value, returning nil when
+// it is empty and exiting when it is malformed
+func parseTimeFilter(value, name string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	t, err := time.Parse("2006-01-02 15:04:05", value)
 	if err != nil {
-		return err
+		log.Fatalf("Invalid %s time format: %v", name, err)
 	}
-	defer file.Close()
+	return &t
+}
 
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
+// newAnalyzer builds an analyzer configured with the filters and
+// enrichment sources given on the command line
+func (o *inputOptions) newAnalyzer() *LogAnalyzer {
+	analyzer := NewLogAnalyzer()
+	analyzer.filters = o.buildFilters()
+	analyzer.derived = o.derived
+	analyzer.levelMap = o.levelMap
+	analyzer.progress = *o.progress && stderrIsTerminal()
+	analyzer.mmap = *o.mmap
+	analyzer.strict = *o.strict
+	analyzer.sniffLines = *o.sniffLines
+	analyzer.long.max = int(o.maxLine)
+	switch *o.binary {
+	case "sanitize", "skip", "keep":
+		analyzer.binary.mode = *o.binary
+	default:
+		log.Fatalf("Invalid -binary %q (sanitize, skip or keep)", *o.binary)
+	}
+	if *o.rejects != "" {
+		// Written unbuffered, so the file is complete however the run ends
+		rejects, err := os.Create(*o.rejects)
+		if err != nil {
+			log.Fatalf("Error creating rejects file: %v", err)
+		}
+		analyzer.unparsed.rejects = rejects
+	}
+	analyzer.remote = remoteConfig{
+		downloadWorkers: *o.downloads,
+		httpUser:        *o.httpUser,
+		httpToken:       *o.httpToken,
+	}
 
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-		
-		if entry := la.parseLine(line, format); entry != nil {
-			la.entries = append(la.entries, *entry)
+	if *o.k8s {
+		client, err := newK8sClient(*o.k8sAPI)
+		if err != nil {
+			log.Fatalf("Error configuring Kubernetes access: %v", err)
 		}
+		analyzer.remote.k8s = client
 	}
 
-	return scanner.Err()
-}
+	if len(o.plugins) > 0 {
+		transforms, err := loadPlugins(o.plugins)
+		if err != nil {
+			log.Fatalf("Error loading plugin: %v", err)
+		}
+		analyzer.transforms = transforms
+	}
+	for _, path := range o.scripts {
+		script, err := loadScript(path)
+		if err != nil {
+			log.Fatalf("Error loading script: %v", err)
+		}
+		analyzer.transforms = append(analyzer.transforms, script)
+	}
 
-func (la *LogAnalyzer) parseLine(line, format string) *LogEntry {
-	entry := &LogEntry{Raw: line}
+	if *o.geoip != "" {
+		reader, err := OpenGeoIP(*o.geoip)
+		if err != nil {
+			log.Fatalf("Error opening GeoIP database: %v", err)
+		}
+		analyzer.geoip = reader
+	} else if *o.country != "" {
+		log.Fatal("-country requires a -geoip database")
+	}
 
-	if format == "json" || (format == "auto" && strings.HasPrefix(strings.TrimSpace(line), "{")) {
-		return la.parseJSON(line)
+	if *o.rdns {
+		analyzer.resolver = NewDNSResolver(*o.rdnsWorkers, *o.rdnsTimeout)
 	}
 
-	// Try different patterns based on format
-	patterns := []string{format}
-	if format == "auto" {
-		patterns = []string{"generic", "syslog", "apache", "nginx"}
+	if *o.redact != "" {
+		redactor, err := NewRedactor(*o.redact, *o.redactMode, *o.redactKey)
+		if err != nil {
+			log.Fatalf("Invalid redaction settings: %v", err)
+		}
+		analyzer.redactor = redactor
 	}
 
-	for _, patternName := range patterns {
-		if regex, exists := la.patterns[patternName]; exists {
-			if matches := regex.FindStringSubmatch(line); matches != nil {
-				return la.parseWithPattern(line, patternName, matches)
-			}
+	return analyzer
+}
+
+// load parses the input files and returns the analyzer with the filtered
+// entries, exiting on errors the way the main command does
+func (o *inputOptions) load() (*LogAnalyzer, []LogEntry) {
+	if !o.hasFiles() {
+		log.Fatal("No log file given (-f)")
+	}
+
+	analyzer := o.newAnalyzer()
+	o.addPods(analyzer)
+	for _, filename := range o.files {
+		if err := analyzer.parseFile(filename, *o.format); err != nil {
+			log.Fatalf("Error parsing file: %v", err)
 		}
 	}
 
-	// Fallback: treat as plain text with timestamp detection
-	return la.parseGeneric(line)
+	return analyzer, analyzer.filterEntries()
 }
 
-func (la *LogAnalyzer) parseJSON(line string) *LogEntry {
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal([]byte(line), &jsonData); err != nil {
-		return &LogEntry{Raw: line, Message: line}
+// addPods appends an input for every container of the pods matching -k8s
+func (o *inputOptions) addPods(la *LogAnalyzer) {
+	if la.remote.k8s == nil {
+		return
 	}
+	pods, err := la.remote.k8s.listPods(*o.namespace, *o.selector)
+	if err != nil {
+		log.Fatalf("Error listing pods: %v", err)
+	}
+	for _, pod := range pods {
+		o.files = append(o.files, pod.inputs(*o.container)...)
+	}
+	if len(o.files) == 0 {
+		log.Fatalf("No pods in %s match %q", *o.namespace, *o.selector)
+	}
+}
 
-	entry := &LogEntry{Raw: line}
+// liveOptions holds the flags for modes that process entries as they
+// arrive (-follow and the network listeners): alerting, notifications,
+// the live dashboard, periodic stats and the ingest rate
+type liveOptions struct {
+	rules          *string
+	notify         *string
+	webhookURL     *string
+	notifyInterval *time.Duration
+	notifyMax      *int
+	smtpAddr       *string
+	smtpUser       *string
+	mailFrom       *string
+	mailTo         *string
+	mailSubject    *string
+	liveStats      *bool
+	liveWindow     *time.Duration
+	refresh        *time.Duration
+	statsInterval  *time.Duration
+	statsJSON      *bool
+	rate           *bool
+}
 
-	// Try to extract common fields
-	if timestamp, ok := jsonData["timestamp"].(string); ok {
-		if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
-			entry.Timestamp = t
-		}
+func addLiveFlags(fs *flag.FlagSet) *liveOptions {
+	return &liveOptions{
+		rules:          fs.String("rules", "", "Alert rules file (YAML) evaluated against new entries (-follow, listen)"),
+		notify:         fs.String("notify", "", "Send new matching entries (-follow, listen) to a channel (slack, email)"),
+		webhookURL:     fs.String("webhook-url", "", "Incoming webhook URL for -notify"),
+		notifyInterval: fs.Duration("notify-interval", 30*time.Second, "Batch -notify messages, sending at most one per interval"),
+		notifyMax:      fs.Int("notify-max", 20, "Maximum entries included in one -notify message"),
+		smtpAddr:       fs.String("smtp-addr", "localhost:25", "SMTP relay (host:port) for -notify email"),
+		smtpUser:       fs.String("smtp-user", "", "SMTP username; the password is read from $LOGANALYZER_SMTP_PASSWORD"),
+		mailFrom:       fs.String("mail-from", "loganalyzer@localhost", "Sender address for -notify email"),
+		mailTo:         fs.String("mail-to", "", "Comma-separated recipients for -notify email"),
+		mailSubject:    fs.String("mail-subject", "[loganalyzer] {{.Summary}}", "Subject template for -notify email (fields: Rule, Count, Summary, Time)"),
+		liveStats:      fs.Bool("live-stats", false, "With -follow or listen, show a refreshing dashboard of the last -live-window instead of raw lines"),
+		liveWindow:     fs.Duration("live-window", 5*time.Minute, "Sliding window for -live-stats"),
+		refresh:        fs.Duration("refresh", 5*time.Second, "How often -live-stats redraws"),
+		statsInterval:  fs.Duration("stats-interval", 0, "With -follow or listen, print a stats summary of the entries of each interval (e.g. 60s) among them"),
+		statsJSON:      fs.Bool("stats-json", false, "Write -stats-interval summaries as one JSON object per line"),
+		rate:           fs.Bool("rate", false, "With -follow or listen, show lines/s read, entries/s matching the filters and parse errors on a status line on stderr (only when stderr is a terminal)"),
 	}
+}
 
-	if level, ok := jsonData["level"].(string); ok {
-		entry.Level = strings.ToUpper(level)
+// apply configures the analyzer's alerting, notification and dashboard
+// hooks, exiting on invalid settings
+func (o *liveOptions) apply(la *LogAnalyzer) {
+	if *o.rules != "" {
+		engine, err := LoadAlertRules(*o.rules)
+		if err != nil {
+			log.Fatalf("Error loading alert rules: %v", err)
+		}
+		la.alerts = engine
+	}
+	if *o.liveStats {
+		la.live = NewLiveStats(*o.liveWindow)
+		go la.live.Run(*o.refresh)
 	}
+	if *o.statsInterval > 0 {
+		la.flusher = newStatsFlusher(*o.statsInterval, *o.statsJSON)
+		go la.flusher.Run()
+	}
+	// The dashboard redraws the whole screen, status line included
+	if *o.rate && la.live == nil && stderrIsTerminal() {
+		la.rate = &ingestRate{}
+		go la.rate.Run()
+	}
+	if *o.notify != "" && *o.notifyInterval <= 0 {
+		log.Fatal("-notify-interval must be positive")
+	}
+	switch *o.notify {
+	case "":
+	case "slack":
+		if *o.webhookURL == "" {
+			log.Fatal("-notify slack requires -webhook-url")
+		}
+		la.notify = newBatchNotifier(newSlackNotifier(*o.webhookURL), *o.notifyInterval, *o.notifyMax)
+	case "email":
+		mailer, err := newEmailNotifier(*o.smtpAddr, *o.smtpUser, os.Getenv("LOGANALYZER_SMTP_PASSWORD"), *o.mailFrom, *o.mailTo, *o.mailSubject)
+		if err != nil {
+			log.Fatalf("Invalid email settings: %v", err)
+		}
+		la.notify = newBatchNotifier(mailer, *o.notifyInterval, *o.notifyMax)
+	default:
+		log.Fatalf("Unknown -notify target %q (slack, email)", *o.notify)
+	}
+}
 
-	if message, ok := jsonData["message"].(string); ok {
-		entry.Message = message
-	} else if msg, ok := jsonData["msg"].(string); ok {
-		entry.Message = msg
+// listingOnly reports whether every flag set on the command line is an
+// input flag or one of the given listing flags, i.e. no report was requested
+func listingOnly(fs *flag.FlagSet, listing ...string) bool {
+	allowed := make(map[string]bool)
+	for _, name := range listing {
+		allowed[name] = true
 	}
+	inputs := flag.NewFlagSet("", flag.ContinueOnError)
+	addInputFlags(inputs)
+	inputs.VisitAll(func(f *flag.Flag) {
+		allowed[f.Name] = true
+	})
+
+	only := true
+	fs.Visit(func(f *flag.Flag) {
+		if !allowed[f.Name] {
+			only = false
+		}
+	})
+	return only
+}
 
-	if source, ok := jsonData["source"].(string); ok {
-		entry.Source = source
-	} else if component, ok := jsonData["component"].(string); ok {
-		entry.Source = component
+func (la *LogAnalyzer) parseFile(filename, format string) error {
+	start := len(la.entries)
+	err := la.scanFile(filename, format, func(entry *LogEntry) {
+		la.entries = append(la.entries, *entry)
+	})
+	if err != nil {
+		return err
 	}
 
-	return entry
+	la.resolveHostnames(la.entries[start:])
+	return nil
 }
 
-func (la *LogAnalyzer) parseWithPattern(line, patternName string, matches []string) *LogEntry {
-	entry := &LogEntry{Raw: line}
+// scanFile parses and enriches each line of a file, passing the entries to
+// fn without retaining them
+func (la *LogAnalyzer) scanFile(filename, format string, fn func(*LogEntry)) error {
+	lineNum := 0
+	source := inputSource(filename)
+	if strings.HasPrefix(filename, "journal://") {
+		// Journal records are converted to JSON whatever -format says
+		format = "json"
+	}
+	// Lines in the regions a time index skips aren't counted, so entries
+	// read through one have no line number
+	ranges, indexed := la.indexRanges(filename)
+	session := parser.NewSession(format)
+
+	// With -strict the first line no format reads ends the scan
+	var strictErr error
+	process := func(line string) {
+		if strictErr != nil {
+			return
+		}
+		lineNum++
+		n := lineNum
+		if indexed {
+			n = 0
+		}
+		entry, matched := la.tryParse(line, session, n)
+		if !matched && la.strict {
+			want := "the " + format + " format"
+			if format == "auto" {
+				want = "any format"
+			}
+			strictErr = fmt.Errorf("%s:%d: line doesn't match %s: %q", filename, lineNum, want, line)
+			return
+		}
+		if entry != nil {
+			if source != "" {
+				entry.Source = source
+			}
+			if filename != "-" {
+				entry.File = filename
+			}
+			la.enrich(entry)
+			fn(entry)
+		}
+	}
 
-	switch patternName {
-	case "generic":
-		if len(matches) >= 4 {
-			if t, err := time.Parse("2006-01-02 15:04:05", matches[1]); err == nil {
-				entry.Timestamp = t
+	// In auto mode the first lines pick the format the rest of the file is
+	// read with, which is faster than detecting each line and keeps odd
+	// lines from being taken for another format
+	var sample []string
+	sampling := format == "auto" && la.sniffLines > 0
+	lockFormat := func() {
+		sampling = false
+		format = la.sniffFormat(sample)
+		if la.verbose {
+			if format == "auto" {
+				fmt.Fprintf(os.Stderr, "%s: no format matches most of the first %d lines, detecting line by line\n", filename, len(sample))
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: format %s\n", filename, format)
 			}
-			entry.Level = strings.ToUpper(matches[2])
-			entry.Message = matches[3]
-		}
-	case "syslog":
-		if len(matches) >= 5 {
-			if t, err := time.Parse("Jan 2 15:04:05", matches[1]); err == nil {
-				// Add current year since syslog doesn't include it
-				entry.Timestamp = t.AddDate(time.Now().Year(), 0, 0)
+		}
+		session = parser.NewSession(format)
+		for _, line := range sample {
+			process(line)
+		}
+		sample = nil
+	}
+	feed := func(line string) {
+		if !sampling {
+			process(line)
+			return
+		}
+		sample = append(sample, line)
+		if len(sample) == la.sniffLines {
+			lockFormat()
+		}
+	}
+
+	if la.mmap {
+		if data, unmap, ok := mapInput(filename); ok {
+			defer unmap()
+			if !indexed {
+				ranges = []byteRange{{0, int64(len(data))}}
 			}
-			entry.Source = matches[2]
-			entry.Message = matches[4]
-			entry.Level = la.inferLogLevel(matches[4])
-		}
-	case "apache", "nginx":
-		if len(matches) >= 4 {
-			entry.Source = matches[1]
-			if t, err := time.Parse("02/Jan/2006:15:04:05 -0700", matches[2]); err == nil {
-				entry.Timestamp = t
+			var p *progress
+			if la.progress {
+				var total int64
+				for _, r := range ranges {
+					total += r.end - r.start
+				}
+				p = startProgress(filename, total)
+				defer p.stop()
 			}
-			entry.Message = matches[3]
-			
-			// Infer level from HTTP status code
-			if len(matches) >= 5 {
-				if status, err := strconv.Atoi(matches[4]); err == nil {
-					if status >= 500 {
-						entry.Level = "ERROR"
-					} else if status >= 400 {
-						entry.Level = "WARN"
-					} else {
-						entry.Level = "INFO"
+			for _, r := range ranges {
+				region := data[r.start:r.end]
+				if r.start == 0 && bytes.HasPrefix(region, []byte(utf8BOM)) {
+					region = region[len(utf8BOM):]
+					if p != nil {
+						p.add(len(utf8BOM))
 					}
 				}
+				forEachLine(region, func(line []byte) {
+					// Entries outlive the mapping, so each line is copied once
+					feed(la.long.clip(string(line)))
+					if p != nil {
+						p.add(len(line) + 1)
+					}
+				})
 			}
+			if sampling {
+				lockFormat()
+			}
+			return strictErr
 		}
 	}
 
-	return entry
-}
-
-func (la *LogAnalyzer) parseGeneric(line string) *LogEntry {
-	entry := &LogEntry{Raw: line, Message: line}
-
-	// Try to find timestamp at beginning of line
-	timePatterns := []string{
-		"2006-01-02 15:04:05",
-		"2006/01/02 15:04:05",
-		"Jan 2 15:04:05",
-		"2006-01-02T15:04:05Z07:00",
+	r, err := la.openInput(filename)
+	if err != nil {
+		return err
 	}
+	defer r.Close()
 
-	for _, pattern := range timePatterns {
-		if len(line) >= len(pattern) {
-			if t, err := time.Parse(pattern, line[:len(pattern)]); err == nil {
-				entry.Timestamp = t
-				if len(line) > len(pattern)+1 {
-					entry.Message = strings.TrimSpace(line[len(pattern)+1:])
-				}
-				break
-			}
-		}
+	scanner := la.newLineScanner(r)
+	for strictErr == nil && scanner.Scan() {
+		feed(scanner.Text())
+	}
+	if sampling {
+		lockFormat()
+	}
+	if strictErr != nil {
+		return strictErr
 	}
+	return scanner.Err()
+}
 
-	// Infer log level from message content
-	entry.Level = la.inferLogLevel(entry.Message)
+// enrich adds data from external sources to a parsed entry, then applies
+// -redact. Every input passes through here before it is filtered, listed
+// or aggregated, so no report sees the unredacted values.
+func (la *LogAnalyzer) enrich(entry *LogEntry) {
+	if la.geoip != nil && entry.Access != nil {
+		entry.Access.Geo = la.geoip.Lookup(entry.Access.ClientIP)
+	}
+	if la.redactor != nil {
+		*entry = la.redactor.RedactEntry(*entry)
+	}
+}
 
+// parseLine parses a line on its own; entries of multi-line formats need
+// the lines before them and a session (see tryParse)
+func (la *LogAnalyzer) parseLine(line, format string) *LogEntry {
+	entry, _ := la.tryParse(line, parser.NewSession(format), 0)
 	return entry
 }
 
-func (la *LogAnalyzer) inferLogLevel(message string) string {
-	message = strings.ToUpper(message)
-	
-	if strings.Contains(message, "ERROR") || strings.Contains(message, "FATAL") || strings.Contains(message, "CRITICAL") {
-		return "ERROR"
+// tryParse is parseLine for the next line of an input's session that also
+// reports whether a format read the line, counting the lines that fell
+// back to plain text. lineNum is the line's number in its file, or 0 when
+// unknown.
+func (la *LogAnalyzer) tryParse(line string, session *parser.Session, lineNum int) (*LogEntry, bool) {
+	line, ok := la.binary.filter(line)
+	if !ok {
+		return nil, true
+	}
+	entry, matched := session.TryParse(line)
+	la.unparsed.add(session.Format(), line, lineNum, matched)
+	if la.rate != nil {
+		la.rate.line(matched)
 	}
-	if strings.Contains(message, "WARN") || strings.Contains(message, "WARNING") {
-		return "WARN"
+	if entry != nil {
+		entry.LineNum = lineNum
+		if level, ok := la.levelMap[strings.ToUpper(entry.Level)]; ok {
+			entry.Level = level
+		}
+	}
+	if entry != nil && len(la.transforms) > 0 {
+		entry = la.applyTransforms(entry)
 	}
-	if strings.Contains(message, "DEBUG") || strings.Contains(message, "TRACE") {
-		return "DEBUG"
+	if entry != nil && len(la.derived) > 0 {
+		la.applyDerivations(entry)
 	}
-	
-	return "INFO"
+	return entry, matched
 }
 
-func (la *LogAnalyzer) filterEntries() []LogEntry {
-	var filtered []LogEntry
+// transformer rewrites parsed entries, returning nil to drop one
+type transformer interface {
+	Transform(entry *LogEntry) (*LogEntry, error)
+	String() string
+}
 
-	for _, entry := range la.entries {
-		if la.filters.Level != "" && entry.Level != la.filters.Level {
+// applyTransforms runs the -plugin and -script transforms over an entry in
+// order, returning nil when one of them drops it. A failing transform
+// leaves the entry unchanged and is reported once.
+func (la *LogAnalyzer) applyTransforms(entry *LogEntry) *LogEntry {
+	for _, t := range la.transforms {
+		transformed, err := t.Transform(entry)
+		if err != nil {
+			if _, warned := la.warned.LoadOrStore(t, true); !warned {
+				log.Printf("%s: %v (entries are kept unchanged)", t, err)
+			}
 			continue
 		}
-
-		if la.filters.Source != "" && !strings.Contains(strings.ToLower(entry.Source), strings.ToLower(la.filters.Source)) {
-			continue
-		}
-
-		if la.filters.Keyword != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(la.filters.Keyword)) {
-			continue
+		if transformed == nil {
+			return nil
 		}
+		entry = transformed
+	}
+	return entry
+}
 
-		if la.filters.StartTime != nil && !entry.Timestamp.IsZero() && entry.Timestamp.Before(*la.filters.StartTime) {
-			continue
-		}
+func (la *LogAnalyzer) filterEntries() []LogEntry {
+	var filtered []LogEntry
 
-		if la.filters.EndTime != nil && !entry.Timestamp.IsZero() && entry.Timestamp.After(*la.filters.EndTime) {
-			continue
+	for _, entry := range la.entries {
+		if la.matchesFilters(entry) {
+			filtered = append(filtered, entry)
 		}
-
-		filtered = append(filtered, entry)
 	}
 
 	return filtered
@@ -370,111 +873,93 @@ func (la *LogAnalyzer) getTail(entries []LogEntry, n int) []LogEntry {
 }
 
 func (la *LogAnalyzer) outputEntries(entries []LogEntry, format string, verbose bool) {
-	switch format {
-	case "json":
-		la.outputJSON(entries)
-	case "csv":
-		la.outputCSV(entries)
-	default:
-		la.outputText(entries, verbose)
-	}
-}
-
-func (la *LogAnalyzer) outputText(entries []LogEntry, verbose bool) {
+	w := output.NewWriter(os.Stdout, format, verbose)
 	for _, entry := range entries {
-		if verbose {
-			fmt.Printf("[%s] [%s] [%s] %s\n", 
-				entry.Timestamp.Format("2006-01-02 15:04:05"), 
-				entry.Level, 
-				entry.Source, 
-				entry.Message)
-		} else {
-			if !entry.Timestamp.IsZero() {
-				fmt.Printf("%s ", entry.Timestamp.Format("15:04:05"))
-			}
-			if entry.Level != "" {
-				fmt.Printf("[%s] ", entry.Level)
-			}
-			fmt.Println(entry.Message)
+		if err := w.Write(entry); err != nil {
+			log.Fatalf("Error writing output: %v", err)
 		}
 	}
-}
-
-func (la *LogAnalyzer) outputJSON(entries []LogEntry) {
-	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
-	encoder.Encode(entries)
-}
-
-func (la *LogAnalyzer) outputCSV(entries []LogEntry) {
-	fmt.Println("Timestamp,Level,Source,Message")
-	for _, entry := range entries {
-		timestamp := ""
-		if !entry.Timestamp.IsZero() {
-			timestamp = entry.Timestamp.Format("2006-01-02 15:04:05")
-		}
-		fmt.Printf("%s,%s,%s,\"%s\"\n", timestamp, entry.Level, entry.Source, 
-			strings.ReplaceAll(entry.Message, "\"", "\"\""))
+	if err := w.Close(); err != nil {
+		log.Fatalf("Error writing output: %v", err)
 	}
 }
 
 func (la *LogAnalyzer) showStats() {
-	stats := LogStats{
-		TotalLines: len(la.entries),
-		TopSources: make(map[string]int),
-		TopErrors:  make(map[string]int),
-	}
-
-	var earliest, latest time.Time
-
+	agg := stats.NewAggregator()
 	for _, entry := range la.entries {
-		switch entry.Level {
-		case "ERROR":
-			stats.ErrorCount++
-			stats.TopErrors[entry.Message]++
-		case "WARN":
-			stats.WarnCount++
-		case "INFO":
-			stats.InfoCount++
-		case "DEBUG":
-			stats.DebugCount++
-		}
-
-		if entry.Source != "" {
-			stats.TopSources[entry.Source]++
-		}
-
-		if !entry.Timestamp.IsZero() {
-			if earliest.IsZero() || entry.Timestamp.Before(earliest) {
-				earliest = entry.Timestamp
-			}
-			if latest.IsZero() || entry.Timestamp.After(latest) {
-				latest = entry.Timestamp
-			}
-		}
-	}
-
-	if !earliest.IsZero() && !latest.IsZero() {
-		stats.TimeRange = fmt.Sprintf("%s to %s", 
-			earliest.Format("2006-01-02 15:04:05"), 
-			latest.Format("2006-01-02 15:04:05"))
+		agg.Add(entry)
 	}
+	stats := agg.Stats()
 
 	fmt.Println("=== Log Analysis Statistics ===")
 	fmt.Printf("Total Lines: %d\n", stats.TotalLines)
-	fmt.Printf("Time Range: %s\n", stats.TimeRange)
+	fmt.Printf("Time Range: %s\n", stats.TimeRange())
 	fmt.Println()
 	fmt.Printf("Log Levels:\n")
+	fmt.Printf("  FATAL: %d\n", stats.FatalCount)
 	fmt.Printf("  ERROR: %d\n", stats.ErrorCount)
 	fmt.Printf("  WARN:  %d\n", stats.WarnCount)
 	fmt.Printf("  INFO:  %d\n", stats.InfoCount)
 	fmt.Printf("  DEBUG: %d\n", stats.DebugCount)
+	fmt.Printf("  TRACE: %d\n", stats.TraceCount)
 	fmt.Println()
+	la.unparsed.show()
+	if n := la.long.count.Load(); n > 0 {
+		fmt.Printf("Long Lines: %d cut to %s (-max-line-size)\n\n", n, formatBytes(int64(la.long.limit())))
+	}
+	if n := la.binary.count.Load(); n > 0 {
+		verb := map[string]string{"sanitize": "sanitized", "skip": "skipped", "keep": "kept"}[la.binary.mode]
+		fmt.Printf("Binary Lines: %d %s (-binary)\n\n", n, verb)
+	}
 
 	if len(stats.TopSources) > 0 {
 		fmt.Println("Top Sources:")
 		la.printTopMap(stats.TopSources, 5)
 		fmt.Println()
+		la.printSources(stats.Sources, 20)
+		fmt.Println()
+	}
+
+	if !stats.Earliest.IsZero() {
+		la.printPeriods(stats)
+	}
+
+	if len(stats.TopPaths) > 0 {
+		fmt.Println("Top Paths:")
+		la.printTopMap(stats.TopPaths, 10)
+		fmt.Println()
+		fmt.Println("Top Client IPs:")
+		la.printTopMap(stats.TopClients, 10)
+		fmt.Println()
+		printStatusCodes(stats.StatusCodes)
+		fmt.Println()
+	}
+
+	if len(stats.TopReferrers) > 0 {
+		fmt.Println("Top Referrers:")
+		la.printTopMap(stats.TopReferrers, 10)
+		fmt.Println()
+	}
+
+	if len(stats.Devices) > 0 {
+		fmt.Println("Devices:")
+		la.printTopMap(stats.Devices, 5)
+		fmt.Println()
+		fmt.Println("Browsers:")
+		la.printTopMap(stats.Browsers, 5)
+		fmt.Println()
+	}
+
+	if len(stats.TopHosts) > 0 {
+		fmt.Println("Top Client Hostnames:")
+		la.printTopMap(stats.TopHosts, 10)
+		fmt.Println()
+	}
+
+	if len(stats.TopCountries) > 0 {
+		fmt.Println("Requests by Country:")
+		la.printTopMap(stats.TopCountries, 10)
+		fmt.Println()
 	}
 
 	if len(stats.TopErrors) > 0 {
@@ -483,6 +968,118 @@ func (la *LogAnalyzer) showStats() {
 	}
 }
 
+// printPeriods prints the entry counts per day when the entries span more
+// than one, and per hour when they span at most a week, including the
+// empty periods in between
+func (la *LogAnalyzer) printPeriods(s stats.Stats) {
+	// Only the levels that occur get a column
+	var levels []string
+	for _, level := range parser.Levels {
+		for _, p := range s.Daily {
+			if p.Levels[level] > 0 {
+				levels = append([]string{level}, levels...)
+				break
+			}
+		}
+	}
+
+	table := func(title string, periods map[string]*stats.PeriodStats, layout string, first time.Time, next func(time.Time) time.Time) {
+		fmt.Println(title + ":")
+		fmt.Printf("  %-16s %8s", "", "Total")
+		for _, level := range levels {
+			fmt.Printf(" %7s", level)
+		}
+		fmt.Println()
+		for t := first; !t.After(s.Latest); t = next(t) {
+			key := t.Format(layout)
+			p := periods[key]
+			if p == nil {
+				p = &stats.PeriodStats{}
+			}
+			fmt.Printf("  %-16s %8d", key, p.Total)
+			for _, level := range levels {
+				fmt.Printf(" %7d", p.Levels[level])
+			}
+			fmt.Println()
+		}
+		fmt.Println()
+	}
+
+	start := s.Earliest
+	firstDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	if len(s.Daily) > 1 {
+		table("Daily", s.Daily, stats.DayFormat, firstDay, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) })
+	}
+	if s.Latest.Sub(s.Earliest) <= 7*24*time.Hour {
+		firstHour := time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), 0, 0, 0, start.Location())
+		table("Hourly", s.Hourly, stats.HourFormat, firstHour, func(t time.Time) time.Time { return t.Add(time.Hour) })
+	}
+}
+
+// printStatusCodes prints the share of each HTTP status code, grouped by
+// class
+func printStatusCodes(codes map[int]int) {
+	total := 0
+	statuses := make([]int, 0, len(codes))
+	for status, n := range codes {
+		statuses = append(statuses, status)
+		total += n
+	}
+	sort.Ints(statuses)
+
+	fmt.Println("Status Codes:")
+	class := -1
+	for _, status := range statuses {
+		if status/100 != class {
+			class = status / 100
+			n := 0
+			for s, count := range codes {
+				if s/100 == class {
+					n += count
+				}
+			}
+			fmt.Printf("  %dxx: %d (%.1f%%)\n", class, n, 100*float64(n)/float64(total))
+		}
+		fmt.Printf("    %d: %d (%.1f%%)\n", status, codes[status], 100*float64(codes[status])/float64(total))
+	}
+}
+
+// printSources prints a row per source, those with the most errors first,
+// so a misbehaving component stands out
+func (la *LogAnalyzer) printSources(sources map[string]*stats.SourceStats, limit int) {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := sources[names[i]], sources[names[j]]
+		if a.Errors != b.Errors {
+			return a.Errors > b.Errors
+		}
+		if a.Entries != b.Entries {
+			return a.Entries > b.Entries
+		}
+		return names[i] < names[j]
+	})
+
+	timestamp := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	fmt.Println("Sources:")
+	fmt.Printf("  %-24s %8s %8s %7s  %-19s  %s\n", "Source", "Entries", "Errors", "Rate", "First", "Last")
+	for i, name := range names {
+		if i >= limit {
+			fmt.Printf("  ... %d more\n", len(names)-limit)
+			break
+		}
+		s := sources[name]
+		fmt.Printf("  %-24s %8d %8d %6.1f%%  %-19s  %s\n", name, s.Entries, s.Errors, s.ErrorRate(), timestamp(s.First), timestamp(s.Last))
+	}
+}
+
 func (la *LogAnalyzer) printTopMap(m map[string]int, limit int) {
 	type kv struct {
 		Key   string
@@ -507,53 +1104,187 @@ func (la *LogAnalyzer) printTopMap(m map[string]int, limit int) {
 }
 
 func (la *LogAnalyzer) followFile(filename, format string, verbose bool) {
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Fatalf("Error opening file: %v", err)
+	if strings.HasPrefix(filename, "ssh://") {
+		la.followSSH(filename, format, verbose)
+		return
+	}
+	if strings.HasPrefix(filename, "docker://") {
+		la.followDocker(filename, format, verbose)
+		return
+	}
+	if strings.HasPrefix(filename, "journal://") {
+		la.followJournal(filename, verbose)
+		return
 	}
-	defer file.Close()
 
-	// Seek to end of file
-	file.Seek(0, 2)
+	key, err := filepath.Abs(filename)
+	if err != nil {
+		key = filename
+	}
+	f := la.openFollowed(filename, key, false)
+	defer func() { f.Close() }()
+	if start, ok := la.followStart(f.File, filename, format); ok {
+		if _, err := f.Seek(start, io.SeekStart); err != nil {
+			log.Fatalf("Error opening file: %v", err)
+		}
+		f.offset = start
+	}
 
-	scanner := bufio.NewScanner(file)
+	// A Scanner stops for good at EOF, so read with a Reader and keep any
+	// partial line until the writer finishes it
+	reader := bufio.NewReader(f)
+	session := parser.NewSession(format)
 	fmt.Println("Following log file... (Press Ctrl+C to exit)")
+	if la.followTail > 0 && f.offset > 0 {
+		entries, err := la.tailEntries(filename, f.offset, session)
+		if err != nil {
+			log.Fatalf("Error reading file: %v", err)
+		}
+		la.outputEntries(entries, "", verbose)
+	}
 
+	var partial string
 	for {
-		if scanner.Scan() {
-			line := scanner.Text()
-			if entry := la.parseLine(line, format); entry != nil {
-				// Apply filters
-				if la.matchesFilters(*entry) {
-					la.outputEntries([]LogEntry{*entry}, "", verbose)
-				}
+		chunk, err := reader.ReadString('\n')
+		partial += chunk
+		if err != nil {
+			// Caught up: save the position and reopen the file if it was
+			// rotated or truncated
+			la.state.update(key, f.inode, f.offset, true)
+			if la.reopenRotated(filename, key, f) {
+				reader.Reset(f)
+				partial = ""
+				continue
 			}
-		} else {
 			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		line := la.long.clip(trimLine(partial, f.offset == 0))
+		f.offset += int64(len(partial))
+		partial = ""
+
+		if entry, _ := la.tryParse(line, session, 0); entry != nil {
+			entry.File = filename
+			la.processLive(entry, verbose)
 		}
+		la.state.update(key, f.inode, f.offset, false)
 	}
 }
 
-func (la *LogAnalyzer) matchesFilters(entry LogEntry) bool {
-	if la.filters.Level != "" && entry.Level != la.filters.Level {
-		return false
+// tailEntries returns the last la.followTail entries matching the filters
+// in a file's first end bytes, keeping only those in memory. The session is
+// the one the follow continues with.
+func (la *LogAnalyzer) tailEntries(filename string, end int64, session *parser.Session) ([]LogEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
+	// The ingest rate is of new lines
+	rate := la.rate
+	la.rate = nil
+	defer func() { la.rate = rate }()
+
+	entries := make([]LogEntry, 0, la.followTail)
+	next := 0
+	scanner := la.newLineScanner(io.LimitReader(file, end))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		entry, _ := la.tryParse(la.long.clip(scanner.Text()), session, lineNum)
+		if entry == nil {
+			continue
+		}
+		entry.File = filename
+		la.enrich(entry)
+		if !la.matchesFilters(*entry) {
+			continue
+		}
+		// A ring of the last followTail entries, oldest at next once full
+		if len(entries) < la.followTail {
+			entries = append(entries, *entry)
+		} else {
+			entries[next] = *entry
+			next = (next + 1) % la.followTail
+		}
+	}
+	entries = append(entries[next:], entries[:next]...)
+	la.resolveHostnames(entries)
+	return entries, scanner.Err()
+}
 
-	if la.filters.Source != "" && !strings.Contains(strings.ToLower(entry.Source), strings.ToLower(la.filters.Source)) {
-		return false
+// followedFile is a followed file with the inode it was opened with and the
+// offset read up to
+type followedFile struct {
+	*os.File
+	inode  uint64
+	offset int64
+}
+
+// openFollowed opens a file to follow at the position -state saved for it,
+// or at its end. A file replacing a rotated or truncated one is read from
+// its start, as its lines are all new.
+func (la *LogAnalyzer) openFollowed(filename, key string, replaced bool) *followedFile {
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatalf("Error opening file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Error opening file: %v", err)
+	}
+	f := &followedFile{File: file, inode: fileInode(info)}
+	if !replaced {
+		f.offset = la.state.resumeOffset(key, f.inode, info.Size())
 	}
+	if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+		log.Fatalf("Error opening file: %v", err)
+	}
+	return f
+}
 
-	if la.filters.Keyword != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(la.filters.Keyword)) {
+// reopenRotated reopens a followed file from its start when it was rotated
+// (the name now holds another inode) or truncated, reporting whether it did
+func (la *LogAnalyzer) reopenRotated(filename, key string, f *followedFile) bool {
+	info, err := os.Stat(filename)
+	if err != nil || (fileInode(info) == f.inode && info.Size() >= f.offset) {
 		return false
 	}
+	f.Close()
+	*f = *la.openFollowed(filename, key, true)
+	return true
+}
 
-	if la.filters.StartTime != nil && !entry.Timestamp.IsZero() && entry.Timestamp.Before(*la.filters.StartTime) {
-		return false
+// processLive runs a newly arrived entry through enrichment, the filters,
+// and then the output, dashboard, periodic stats, alerting and notification
+// hooks
+func (la *LogAnalyzer) processLive(entry *LogEntry, verbose bool) {
+	la.enrich(entry)
+	la.resolveHostnames([]LogEntry{*entry})
+	if !la.matchesFilters(*entry) {
+		return
 	}
 
-	if la.filters.EndTime != nil && !entry.Timestamp.IsZero() && entry.Timestamp.After(*la.filters.EndTime) {
-		return false
+	switch {
+	case la.live != nil:
+		la.live.Add(*entry)
+	case la.rate != nil:
+		la.rate.match()
+		la.rate.around(func() { la.outputEntries([]LogEntry{*entry}, "", verbose) })
+	default:
+		la.outputEntries([]LogEntry{*entry}, "", verbose)
+	}
+	if la.flusher != nil {
+		la.flusher.Add(*entry)
+	}
+	if la.alerts != nil {
+		la.alerts.Check(*entry)
 	}
+	if la.notify != nil {
+		la.notify.Add(*entry)
+	}
+}
 
-	return true
-}
\ No newline at end of file
+func (la *LogAnalyzer) matchesFilters(entry LogEntry) bool {
+	return la.filters.Match(entry)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readFollowed reads what is left of a followed file, advancing its offset
+// as followFile does
+func readFollowed(t *testing.T, f *followedFile) string {
+	t.Helper()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.offset += int64(len(data))
+	return string(data)
+}
+
+func TestReopenRotated(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("old 1\nold 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, state := range []*followState{nil, {path: filepath.Join(dir, "state.json"), files: map[string]fileState{}}} {
+		la := &LogAnalyzer{state: state}
+		f := la.openFollowed(path, path, false)
+		if got := readFollowed(t, f); got != "" {
+			t.Errorf("state %v: a new follow read %q, want it to start at the end", state != nil, got)
+		}
+		if la.reopenRotated(path, path, f) {
+			t.Errorf("state %v: reopened a file that was not rotated", state != nil)
+		}
+
+		// Rotated: the name now holds a new file, written before the
+		// follow noticed
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("new 1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if !la.reopenRotated(path, path, f) {
+			t.Fatalf("state %v: rotation not noticed", state != nil)
+		}
+		if got := readFollowed(t, f); got != "new 1\n" {
+			t.Errorf("state %v: after rotation read %q, want %q", state != nil, got, "new 1\n")
+		}
+
+		// Truncated in place, then written again
+		if err := os.WriteFile(path, []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if !la.reopenRotated(path, path, f) {
+			t.Fatalf("state %v: truncation not noticed", state != nil)
+		}
+		if got := readFollowed(t, f); got != "x\n" {
+			t.Errorf("state %v: after truncation read %q, want %q", state != nil, got, "x\n")
+		}
+		f.Close()
+
+		if err := os.WriteFile(path, []byte("old 1\nold 2\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
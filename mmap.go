@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+)
+
+// mapInput maps a plain local file into memory. ok is false for anything
+// else (stdin, remote and compressed inputs, empty files, platforms
+// without mmap), which the caller then reads the usual way.
+func mapInput(name string) (data []byte, unmap func() error, ok bool) {
+	if name == "-" || strings.Contains(name, "://") {
+		return nil, nil, false
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, false
+	}
+	// The mapping stays valid after the file is closed
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return nil, nil, false
+	}
+	data, unmap, err = mmapFile(f, info.Size())
+	if err != nil {
+		return nil, nil, false
+	}
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		unmap()
+		return nil, nil, false
+	}
+	return data, unmap, true
+}
+
+// forEachLine calls fn with each line of data, sliced from the mapping
+// without copying. Like newLineScanner it drops trailing carriage returns,
+// but lines have no length limit.
+func forEachLine(data []byte, fn func(line []byte)) {
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		line := data
+		if end >= 0 {
+			line, data = data[:end], data[end+1:]
+		} else {
+			data = nil
+		}
+		fn(bytes.TrimRight(line, "\r"))
+	}
+}
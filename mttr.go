@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// defaultRecovery matches the messages that usually end an outage
+var defaultRecovery = regexp.MustCompile(`(?i)\b(recovered|reconnected|restored|back (up|online)|healthy again|resumed)\b`)
+
+// recoveryPair names what starts an episode (a failure) and what ends it.
+// A nil failure pattern means any ERROR or FATAL entry.
+type recoveryPair struct {
+	failure, recovery *regexp.Regexp
+}
+
+func (p recoveryPair) String() string {
+	failure := "errors"
+	if p.failure != nil {
+		failure = p.failure.String()
+	}
+	return failure + " => " + p.recovery.String()
+}
+
+// parseRecoveryPair reads a -mttr-pair value: "failure regex=>recovery regex"
+func parseRecoveryPair(value string) (recoveryPair, error) {
+	failure, recovery, ok := strings.Cut(value, "=>")
+	if !ok {
+		return recoveryPair{}, fmt.Errorf("%q: want failure=>recovery", value)
+	}
+	f, err := regexp.Compile(strings.TrimSpace(failure))
+	if err != nil {
+		return recoveryPair{}, err
+	}
+	r, err := regexp.Compile(strings.TrimSpace(recovery))
+	if err != nil {
+		return recoveryPair{}, err
+	}
+	return recoveryPair{failure: f, recovery: r}, nil
+}
+
+// episode is a stretch from the first failure to the recovery after it
+type episode struct {
+	source     string
+	start, end time.Time
+	failures   int
+	first      string
+}
+
+// findEpisodes pairs each failure onset with the next recovery from the
+// same source; failures in between belong to the open episode. Episodes
+// still open at the end have a zero end.
+func findEpisodes(entries []LogEntry, pair recoveryPair) []episode {
+	var episodes []episode
+	open := make(map[string]*episode)
+	for _, entry := range entries {
+		if entry.Timestamp.IsZero() {
+			continue
+		}
+		if pair.recovery.MatchString(entry.Message) {
+			if e := open[entry.Source]; e != nil {
+				e.end = entry.Timestamp
+				episodes = append(episodes, *e)
+				delete(open, entry.Source)
+			}
+			continue
+		}
+		failed := parser.IsError(entry.Level)
+		if pair.failure != nil {
+			failed = pair.failure.MatchString(entry.Message)
+		}
+		if !failed {
+			continue
+		}
+		if e := open[entry.Source]; e != nil {
+			e.failures++
+			continue
+		}
+		open[entry.Source] = &episode{source: entry.Source, start: entry.Timestamp, failures: 1, first: entry.Message}
+	}
+	for _, e := range open {
+		episodes = append(episodes, *e)
+	}
+	sort.Slice(episodes, func(i, j int) bool { return episodes[i].start.Before(episodes[j].start) })
+	return episodes
+}
+
+// showMTTR summarizes the downtime episodes of each pair and their mean
+// time to recovery
+func (la *LogAnalyzer) showMTTR(entries []LogEntry, pairs []recoveryPair) {
+	if len(pairs) == 0 {
+		pairs = []recoveryPair{{recovery: defaultRecovery}}
+	}
+	sorted := make([]LogEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	fmt.Println("=== Time to Recovery ===")
+	for _, pair := range pairs {
+		episodes := findEpisodes(sorted, pair)
+		fmt.Printf("%s\n", pair)
+		if len(episodes) == 0 {
+			fmt.Println("  No failures")
+			fmt.Println()
+			continue
+		}
+
+		var total, longest time.Duration
+		recovered := 0
+		for _, e := range episodes {
+			if e.end.IsZero() {
+				continue
+			}
+			d := e.end.Sub(e.start)
+			total += d
+			if d > longest {
+				longest = d
+			}
+			recovered++
+		}
+		fmt.Printf("  Episodes: %d (%d recovered, %d ongoing)\n", len(episodes), recovered, len(episodes)-recovered)
+		if recovered > 0 {
+			fmt.Printf("  Downtime: %s, MTTR %s, longest %s\n", total, (total / time.Duration(recovered)).Round(time.Second), longest)
+		}
+		for _, e := range episodes {
+			end, duration := "ongoing", ""
+			if !e.end.IsZero() {
+				end = e.end.Format("2006-01-02 15:04:05")
+				duration = e.end.Sub(e.start).String()
+			}
+			source := ""
+			if e.source != "" {
+				source = " [" + e.source + "]"
+			}
+			first := e.first
+			if len(first) > 80 {
+				first = first[:80] + "..."
+			}
+			fmt.Printf("    %s to %-19s %10s %5d failures%s  %s\n", e.start.Format("2006-01-02 15:04:05"), end, duration, e.failures, source, first)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// slackTextLimit keeps messages well under Slack's per-message size limit
+const slackTextLimit = 3500
+
+// slackNotifier posts alerts to a Slack incoming webhook
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newSlackNotifier(url string) *slackNotifier {
+	return &slackNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *slackNotifier) Notify(alert Alert) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n```\n", alert.Summary)
+	for _, entry := range alert.Entries {
+		line := fmt.Sprintf("%s [%s] %s\n", entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Level, entry.Message)
+		if b.Len()+len(line) > slackTextLimit {
+			b.WriteString("...\n")
+			break
+		}
+		b.WriteString(line)
+	}
+	b.WriteString("```")
+
+	body, err := json.Marshal(map[string]string{"text": b.String()})
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// batchNotifier collects entries and sends them as one alert per interval,
+// so a burst of errors becomes a single message instead of a flood
+type batchNotifier struct {
+	notifier Notifier
+	interval time.Duration
+	max      int
+
+	mu      sync.Mutex
+	pending []LogEntry
+	count   int
+}
+
+// newBatchNotifier starts a notifier that flushes every interval, sending at
+// most max entries per message
+func newBatchNotifier(n Notifier, interval time.Duration, max int) *batchNotifier {
+	b := &batchNotifier{notifier: n, interval: interval, max: max}
+	go func() {
+		for range time.Tick(interval) {
+			b.flush()
+		}
+	}()
+	return b
+}
+
+// Add queues an entry for the next batch
+func (b *batchNotifier) Add(entry LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.count++
+	if b.max <= 0 || len(b.pending) < b.max {
+		b.pending = append(b.pending, entry)
+	}
+}
+
+func (b *batchNotifier) flush() {
+	b.mu.Lock()
+	entries, count := b.pending, b.count
+	b.pending, b.count = nil, 0
+	b.mu.Unlock()
+
+	if count == 0 {
+		return
+	}
+	summary := fmt.Sprintf("%d new matching entries", count)
+	if count > len(entries) {
+		summary += fmt.Sprintf(" (showing first %d)", len(entries))
+	}
+	alert := Alert{Rule: "follow", Time: time.Now(), Count: count, Summary: summary, Entries: entries}
+	if err := b.notifier.Notify(alert); err != nil {
+		log.Printf("Error sending notification: %v", err)
+	}
+}
+
+// emailNotifier sends alerts as plain-text mail through an SMTP relay
+type emailNotifier struct {
+	addr    string
+	auth    smtp.Auth
+	from    string
+	to      []string
+	subject *template.Template
+}
+
+// newEmailNotifier parses the subject template, which can refer to the
+// alert's fields, e.g. "[{{.Count}} errors] {{.Rule}}"
+func newEmailNotifier(addr, user, password, from, to, subject string) (*emailNotifier, error) {
+	tmpl, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template: %v", err)
+	}
+	e := &emailNotifier{addr: addr, from: from, subject: tmpl}
+	for _, rcpt := range strings.Split(to, ",") {
+		if rcpt = strings.TrimSpace(rcpt); rcpt != "" {
+			e.to = append(e.to, rcpt)
+		}
+	}
+	if len(e.to) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	if user != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		e.auth = smtp.PlainAuth("", user, password, host)
+	}
+	return e, nil
+}
+
+func (e *emailNotifier) Notify(alert Alert) error {
+	var subject bytes.Buffer
+	if err := e.subject.Execute(&subject, alert); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.Join(strings.Fields(subject.String()), " ")))
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n", alert.Summary)
+	for _, entry := range alert.Entries {
+		fmt.Fprintf(&msg, "%s [%s] %s\r\n", entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Level, entry.Message)
+	}
+
+	return smtp.SendMail(e.addr, e.auth, e.from, e.to, msg.Bytes())
+}
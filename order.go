@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OrderReport summarizes timestamps that go backwards in file order
+type OrderReport struct {
+	Checked     int
+	OutOfOrder  int
+	MaxJump     time.Duration
+	MaxJumpAt   LogEntry
+	MaxJumpFrom time.Time
+}
+
+func (r *OrderReport) observe(prev time.Time, entry LogEntry) {
+	r.Checked++
+	if !entry.Timestamp.Before(prev) {
+		return
+	}
+	r.OutOfOrder++
+	if jump := prev.Sub(entry.Timestamp); jump > r.MaxJump {
+		r.MaxJump = jump
+		r.MaxJumpAt = entry
+		r.MaxJumpFrom = prev
+	}
+}
+
+// checkOrder compares each timestamped entry against the previous one, both
+// overall and within its own source, since interleaved writers with skewed
+// clocks only show up per source
+func checkOrder(entries []LogEntry) (*OrderReport, map[string]*OrderReport) {
+	overall := &OrderReport{}
+	perSource := make(map[string]*OrderReport)
+	lastBySource := make(map[string]time.Time)
+	var last time.Time
+
+	for _, entry := range entries {
+		if entry.Timestamp.IsZero() {
+			continue
+		}
+		if !last.IsZero() {
+			overall.observe(last, entry)
+		}
+		last = entry.Timestamp
+
+		if entry.Source == "" {
+			continue
+		}
+		r, ok := perSource[entry.Source]
+		if !ok {
+			r = &OrderReport{}
+			perSource[entry.Source] = r
+		}
+		if prev, ok := lastBySource[entry.Source]; ok {
+			r.observe(prev, entry)
+		}
+		lastBySource[entry.Source] = entry.Timestamp
+	}
+
+	return overall, perSource
+}
+
+func (la *LogAnalyzer) showOrderCheck(entries []LogEntry) {
+	overall, perSource := checkOrder(entries)
+
+	fmt.Println("=== Timestamp Order Check ===")
+	fmt.Printf("Entries Checked: %d\n", overall.Checked)
+	fmt.Printf("Out of Order: %d (%.2f%%)\n", overall.OutOfOrder, percentOf(overall.OutOfOrder, overall.Checked))
+	if overall.OutOfOrder > 0 {
+		fmt.Printf("Max Backwards Jump: %s (%s after %s)\n", overall.MaxJump,
+			overall.MaxJumpAt.Timestamp.Format("2006-01-02 15:04:05.000"),
+			overall.MaxJumpFrom.Format("2006-01-02 15:04:05.000"))
+		fmt.Printf("  %s\n", overall.MaxJumpAt.Raw)
+	}
+
+	var sources []string
+	for source, r := range perSource {
+		if r.OutOfOrder > 0 {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return perSource[sources[i]].MaxJump > perSource[sources[j]].MaxJump
+	})
+
+	fmt.Println()
+	fmt.Println("Sources with Out-of-Order Entries:")
+	for _, source := range sources {
+		r := perSource[source]
+		fmt.Printf("  %s: %d of %d, max jump %s\n", source, r.OutOfOrder, r.Checked, r.MaxJump)
+	}
+}
+
+func percentOf(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
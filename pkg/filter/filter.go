@@ -0,0 +1,258 @@
+// Package filter selects log entries by level, source, keyword, time range
+// and access log attributes.
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// Filter contains filtering options; zero values match everything
+type Filter struct {
+	Level string
+	// MinLevel keeps entries at least this severe, in parser.Levels order
+	MinLevel  string
+	StartTime *time.Time
+	EndTime   *time.Time
+	Source    string
+	// Keywords are matched in the message; any of them is enough unless
+	// KeywordsAll is set
+	Keywords    []string
+	KeywordsAll bool
+	// Words matches keywords only as whole words, so "err" doesn't match
+	// "transferred"
+	Words bool
+	// Fuzzy matches messages containing text within FuzzyEdits edits
+	// (insertions, deletions or substitutions) of it, for misremembered or
+	// slightly varying messages
+	Fuzzy      string
+	FuzzyEdits int
+	// CaseSensitive matches the source, keywords, referrer and patterns
+	// from Compile exactly instead of ignoring case
+	CaseSensitive bool
+	Country       string
+	Referrer      string
+	BotOnly       bool
+	ExcludeBots   bool
+	Where         []Condition
+	// Files are glob patterns for the entry's input file, matched against
+	// its full name or its base name
+	Files []string
+}
+
+// Condition compares a named entry field (see parser.Entry.Field) with a
+// value, e.g. latency_ms>500 or status!=200
+type Condition struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// conditionOps are checked in order so two-character operators win
+var conditionOps = []string{">=", "<=", "!=", "=", ">", "<"}
+
+// ParseCondition reads "field<op>value" with op one of = != > >= < <=
+func ParseCondition(s string) (Condition, error) {
+	for i := range s {
+		for _, op := range conditionOps {
+			if strings.HasPrefix(s[i:], op) {
+				c := Condition{Field: strings.TrimSpace(s[:i]), Op: op, Value: strings.TrimSpace(s[i+len(op):])}
+				if c.Field == "" {
+					return Condition{}, fmt.Errorf("condition %q has no field", s)
+				}
+				return c, nil
+			}
+		}
+	}
+	return Condition{}, fmt.Errorf("condition %q needs one of = != > >= < <=", s)
+}
+
+// Match compares numerically when both sides are numbers and as strings
+// otherwise, where only = and != can match. Entries without the field
+// never match.
+func (c Condition) Match(entry *parser.Entry) bool {
+	v, ok := entry.Field(c.Field)
+	if !ok {
+		return false
+	}
+
+	a, errA := strconv.ParseFloat(v, 64)
+	b, errB := strconv.ParseFloat(c.Value, 64)
+	if errA != nil || errB != nil {
+		switch c.Op {
+		case "=":
+			return v == c.Value
+		case "!=":
+			return v != c.Value
+		}
+		return false
+	}
+
+	switch c.Op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// Match reports whether an entry passes every set option. Source, keywords
+// and referrer match case-insensitive substrings (exactly with
+// CaseSensitive); entries without a timestamp pass the time range.
+func (f Filter) Match(entry parser.Entry) bool {
+	if f.Level != "" && entry.Level != f.Level {
+		return false
+	}
+
+	if f.MinLevel != "" && parser.LevelRank(entry.Level) < parser.LevelRank(f.MinLevel) {
+		return false
+	}
+
+	if f.Source != "" && !strings.Contains(f.fold(entry.Source), f.fold(f.Source)) {
+		return false
+	}
+
+	if len(f.Keywords) > 0 && !f.matchKeywords(entry.Message) {
+		return false
+	}
+
+	if f.Fuzzy != "" && !fuzzyContains(f.fold(entry.Message), f.fold(f.Fuzzy), f.FuzzyEdits) {
+		return false
+	}
+
+	if f.StartTime != nil && !entry.Timestamp.IsZero() && entry.Timestamp.Before(*f.StartTime) {
+		return false
+	}
+
+	if f.EndTime != nil && !entry.Timestamp.IsZero() && entry.Timestamp.After(*f.EndTime) {
+		return false
+	}
+
+	if f.Referrer != "" && (entry.Access == nil || !strings.Contains(f.fold(entry.Access.Referrer), f.fold(f.Referrer))) {
+		return false
+	}
+
+	if f.Country != "" && (entry.Access == nil || !MatchesCountry(entry.Access.Geo, f.Country)) {
+		return false
+	}
+
+	if f.BotOnly || f.ExcludeBots {
+		isBot := entry.Access != nil && entry.Access.Agent != nil && entry.Access.Agent.Bot
+		if f.BotOnly && !isBot {
+			return false
+		}
+		if f.ExcludeBots && isBot {
+			return false
+		}
+	}
+
+	for _, c := range f.Where {
+		if !c.Match(&entry) {
+			return false
+		}
+	}
+
+	if len(f.Files) > 0 && !MatchesFile(entry.File, f.Files) {
+		return false
+	}
+
+	return true
+}
+
+// matchKeywords reports whether a message contains any of the keywords, or
+// all of them with KeywordsAll
+func (f Filter) matchKeywords(message string) bool {
+	message = f.fold(message)
+	for _, keyword := range f.Keywords {
+		found := f.contains(message, f.fold(keyword))
+		if found && !f.KeywordsAll {
+			return true
+		}
+		if !found && f.KeywordsAll {
+			return false
+		}
+	}
+	return f.KeywordsAll
+}
+
+// contains reports whether s holds substr, as a whole word with Words
+func (f Filter) contains(s, substr string) bool {
+	if !f.Words || substr == "" {
+		return strings.Contains(s, substr)
+	}
+	for i := 0; ; {
+		j := strings.Index(s[i:], substr)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(substr)
+		before, _ := utf8.DecodeLastRuneInString(s[:start])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(s) || !isWordRune(after)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		i = start + size
+	}
+}
+
+// isWordRune reports whether r is part of a word: a letter, digit or _
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// fold lowercases s unless matching is case-sensitive
+func (f Filter) fold(s string) string {
+	if f.CaseSensitive {
+		return s
+	}
+	return strings.ToLower(s)
+}
+
+// Compile compiles a regular expression matched against entries, ignoring
+// case unless CaseSensitive is set
+func (f Filter) Compile(pattern string) (*regexp.Regexp, error) {
+	if !f.CaseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// MatchesFile reports whether a file name matches any of the glob
+// patterns, in full or by its base name
+func MatchesFile(file string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, file); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(file)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesCountry compares a country filter against an ISO code or name
+func MatchesCountry(loc *parser.GeoLocation, country string) bool {
+	if loc == nil {
+		return false
+	}
+	return strings.EqualFold(loc.CountryCode, country) || strings.EqualFold(loc.Country, country)
+}
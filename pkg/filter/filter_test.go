@@ -0,0 +1,178 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+func TestFilterMatch(t *testing.T) {
+	noon := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	before, after := noon.Add(-time.Hour), noon.Add(time.Hour)
+	entry := parser.Entry{
+		Timestamp: noon,
+		Level:     "WARN",
+		Message:   "Transferred 12 files, retrying upload",
+		Source:    "Uploader",
+		File:      "/var/log/app/upload.log",
+		Fields:    map[string]interface{}{"latency_ms": "750", "region": "eu"},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"zero value", Filter{}, true},
+		{"level", Filter{Level: "WARN"}, true},
+		{"other level", Filter{Level: "ERROR"}, false},
+		{"min level below", Filter{MinLevel: "INFO"}, true},
+		{"min level above", Filter{MinLevel: "ERROR"}, false},
+		{"source ignores case", Filter{Source: "upload"}, true},
+		{"source case-sensitive", Filter{Source: "upload", CaseSensitive: true}, false},
+		{"any keyword", Filter{Keywords: []string{"timeout", "retrying"}}, true},
+		{"all keywords", Filter{Keywords: []string{"timeout", "retrying"}, KeywordsAll: true}, false},
+		{"substring keyword", Filter{Keywords: []string{"err"}}, true},
+		{"whole word keyword", Filter{Keywords: []string{"err"}, Words: true}, false},
+		{"whole word match", Filter{Keywords: []string{"files"}, Words: true}, true},
+		{"fuzzy", Filter{Fuzzy: "retryng", FuzzyEdits: 1}, true},
+		{"fuzzy too far", Filter{Fuzzy: "restarting", FuzzyEdits: 1}, false},
+		{"inside time range", Filter{StartTime: &before, EndTime: &after}, true},
+		{"after end", Filter{EndTime: &before}, false},
+		{"before start", Filter{StartTime: &after}, false},
+		{"where numeric", Filter{Where: []Condition{{Field: "latency_ms", Op: ">", Value: "500"}}}, true},
+		{"where string", Filter{Where: []Condition{{Field: "region", Op: "!=", Value: "eu"}}}, false},
+		{"file base name", Filter{Files: []string{"upload*.log"}}, true},
+		{"other file", Filter{Files: []string{"*.json"}}, false},
+		{"referrer without access", Filter{Referrer: "google"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(entry); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterMatchUntimed(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if !(Filter{StartTime: &start}).Match(parser.Entry{Message: "no timestamp"}) {
+		t.Error("entry without a timestamp did not pass the time range")
+	}
+}
+
+func TestFilterMatchAccess(t *testing.T) {
+	entry := parser.Entry{Access: &parser.AccessInfo{
+		Referrer: "https://www.Google.com/search",
+		Geo:      &parser.GeoLocation{CountryCode: "DE", Country: "Germany"},
+		Agent:    &parser.UserAgentInfo{Bot: true},
+	}}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"referrer", Filter{Referrer: "google"}, true},
+		{"referrer case-sensitive", Filter{Referrer: "google", CaseSensitive: true}, false},
+		{"referrer exact case", Filter{Referrer: "Google", CaseSensitive: true}, true},
+		{"country code", Filter{Country: "de"}, true},
+		{"country name", Filter{Country: "germany"}, true},
+		{"other country", Filter{Country: "FR"}, false},
+		{"bots only", Filter{BotOnly: true}, true},
+		{"exclude bots", Filter{ExcludeBots: true}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(entry); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		filter  Filter
+		pattern string
+		line    string
+		want    bool
+	}{
+		{Filter{}, `error \d+`, "ERROR 42 in worker", true},
+		{Filter{CaseSensitive: true}, `error \d+`, "ERROR 42 in worker", false},
+		{Filter{CaseSensitive: true}, `ERROR \d+`, "ERROR 42 in worker", true},
+		{Filter{}, `^panic:`, "recovered from panic: x", false},
+	}
+	for _, tt := range tests {
+		re, err := tt.filter.Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.pattern, err)
+		}
+		if got := re.MatchString(tt.line); got != tt.want {
+			t.Errorf("case-sensitive %v: %q matches %q = %v, want %v", tt.filter.CaseSensitive, tt.pattern, tt.line, got, tt.want)
+		}
+	}
+	if _, err := (Filter{}).Compile("("); err == nil {
+		t.Error("Compile accepted an invalid pattern")
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Condition
+		wantErr bool
+	}{
+		{"status>=500", Condition{Field: "status", Op: ">=", Value: "500"}, false},
+		{"level != ERROR", Condition{Field: "level", Op: "!=", Value: "ERROR"}, false},
+		{"latency_ms<250", Condition{Field: "latency_ms", Op: "<", Value: "250"}, false},
+		{"path=/a=b", Condition{Field: "path", Op: "=", Value: "/a=b"}, false},
+		{"=500", Condition{}, true},
+		{"status", Condition{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCondition(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCondition(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseCondition(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConditionMatch(t *testing.T) {
+	entry := &parser.Entry{Level: "ERROR", Fields: map[string]interface{}{"status": "502", "host": "api"}}
+	tests := []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Field: "status", Op: ">", Value: "500"}, true},
+		{Condition{Field: "status", Op: "<=", Value: "499"}, false},
+		{Condition{Field: "status", Op: "=", Value: "502.0"}, true},
+		{Condition{Field: "host", Op: "=", Value: "api"}, true},
+		{Condition{Field: "host", Op: ">", Value: "a"}, false},
+		{Condition{Field: "level", Op: "=", Value: "ERROR"}, true},
+		{Condition{Field: "missing", Op: "!=", Value: "x"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.cond.Match(entry); got != tt.want {
+			t.Errorf("%+v: Match = %v, want %v", tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyDistance(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 1},
+		{"abc", 1},
+		{"connection", 2},
+		{"connection refused", 3},
+	}
+	for _, tt := range tests {
+		if got := FuzzyDistance(tt.query); got != tt.want {
+			t.Errorf("FuzzyDistance(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package filter
+
+// FuzzyDistance is the number of edits a fuzzy search allows when none is
+// given: one per five characters of the query, and at least one
+func FuzzyDistance(query string) int {
+	return max(1, len([]rune(query))/5)
+}
+
+// fuzzyContains reports whether some substring of s is within maxEdits
+// insertions, deletions or substitutions of query (Sellers' algorithm: an
+// edit distance where the match may start and end anywhere in s)
+func fuzzyContains(s, query string, maxEdits int) bool {
+	q := []rune(query)
+	if len(q) <= maxEdits {
+		return true
+	}
+	// col[i] is the fewest edits matching q[:i] to a substring of s ending
+	// at the current rune
+	col := make([]int, len(q)+1)
+	for i := range col {
+		col[i] = i
+	}
+	for _, r := range s {
+		// diag is the previous column's value at i-1; row 0 stays 0, as a
+		// match may start at any rune
+		diag := 0
+		for i := 1; i <= len(q); i++ {
+			cost := 1
+			if q[i-1] == r {
+				cost = 0
+			}
+			next := min(diag+cost, col[i]+1, col[i-1]+1)
+			diag, col[i] = col[i], next
+		}
+		if col[len(q)] <= maxEdits {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+// Package output writes log entries as text, JSON, NDJSON, CSV or logfmt.
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// Formats lists the accepted output format names
+var Formats = []string{"text", "json", "ndjson", "csv", "logfmt"}
+
+// Writer writes entries one at a time in some output format. Close must be
+// called to finish the output (closing brackets, flushing).
+type Writer interface {
+	Write(entry parser.Entry) error
+	Close() error
+}
+
+// NewWriter returns a writer for the given output format; an empty or
+// unknown format produces the plain text listing
+func NewWriter(w io.Writer, format string, verbose bool) Writer {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "json":
+		return &jsonArrayWriter{w: bw}
+	case "ndjson":
+		return &ndjsonWriter{w: bw, enc: json.NewEncoder(bw)}
+	case "csv":
+		return &csvWriter{w: bw}
+	case "logfmt":
+		return &logfmtWriter{w: bw}
+	default:
+		return &textWriter{w: bw, verbose: verbose}
+	}
+}
+
+type textWriter struct {
+	w       *bufio.Writer
+	verbose bool
+}
+
+func (t *textWriter) Write(entry parser.Entry) error {
+	if t.verbose {
+		// Prefixed like grep -Hn, for sed -n '<line>p' on the original
+		if entry.File != "" {
+			fmt.Fprintf(t.w, "%s:", entry.File)
+		}
+		if entry.LineNum > 0 {
+			fmt.Fprintf(t.w, "%d:", entry.LineNum)
+		}
+		if entry.File != "" || entry.LineNum > 0 {
+			t.w.WriteString(" ")
+		}
+		source := entry.Source
+		if entry.Access != nil && entry.Access.Hostname != "" {
+			source = fmt.Sprintf("%s %s", source, entry.Access.Hostname)
+		}
+		if entry.Access != nil && entry.Access.Geo != nil {
+			source = fmt.Sprintf("%s %s", source, entry.Access.Geo)
+		}
+		_, err := fmt.Fprintf(t.w, "[%s] [%s] [%s] %s\n",
+			entry.Timestamp.Format("2006-01-02 15:04:05"),
+			entry.Level,
+			source,
+			entry.Message)
+		return err
+	}
+
+	if !entry.Timestamp.IsZero() {
+		fmt.Fprintf(t.w, "%s ", entry.Timestamp.Format("15:04:05"))
+	}
+	if entry.Level != "" {
+		fmt.Fprintf(t.w, "[%s] ", entry.Level)
+	}
+	_, err := fmt.Fprintln(t.w, entry.Message)
+	return err
+}
+
+func (t *textWriter) Close() error {
+	return t.w.Flush()
+}
+
+// jsonArrayWriter writes an indented JSON array, one element per entry
+type jsonArrayWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func (j *jsonArrayWriter) Write(entry parser.Entry) error {
+	data, err := json.MarshalIndent(entry, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	if j.count == 0 {
+		j.w.WriteString("[\n  ")
+	} else {
+		j.w.WriteString(",\n  ")
+	}
+	j.count++
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonArrayWriter) Close() error {
+	if j.count == 0 {
+		j.w.WriteString("[]\n")
+	} else {
+		j.w.WriteString("\n]\n")
+	}
+	return j.w.Flush()
+}
+
+// ndjsonWriter writes one compact JSON object per line
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(entry parser.Entry) error {
+	return n.enc.Encode(entry)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
+
+type csvWriter struct {
+	w             *bufio.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(entry parser.Entry) error {
+	if !c.headerWritten {
+		c.w.WriteString("Timestamp,Level,Source,Message,File,Line\n")
+		c.headerWritten = true
+	}
+
+	timestamp := ""
+	if !entry.Timestamp.IsZero() {
+		timestamp = entry.Timestamp.Format("2006-01-02 15:04:05")
+	}
+	line := ""
+	if entry.LineNum > 0 {
+		line = fmt.Sprint(entry.LineNum)
+	}
+	_, err := fmt.Fprintf(c.w, "%s,%s,%s,\"%s\",\"%s\",%s\n", timestamp, entry.Level, entry.Source,
+		strings.ReplaceAll(entry.Message, "\"", "\"\""), strings.ReplaceAll(entry.File, "\"", "\"\""), line)
+	return err
+}
+
+func (c *csvWriter) Close() error {
+	if !c.headerWritten {
+		c.w.WriteString("Timestamp,Level,Source,Message,File,Line\n")
+	}
+	return c.w.Flush()
+}
+
+// logfmtWriter writes key=value lines as understood by logfmt parsers
+type logfmtWriter struct {
+	w *bufio.Writer
+}
+
+func (l *logfmtWriter) Write(entry parser.Entry) error {
+	var pairs []string
+	if !entry.Timestamp.IsZero() {
+		pairs = append(pairs, "time="+entry.Timestamp.Format(time.RFC3339Nano))
+	}
+	if entry.Level != "" {
+		pairs = append(pairs, "level="+strings.ToLower(entry.Level))
+	}
+	if entry.Source != "" {
+		pairs = append(pairs, "source="+logfmtValue(entry.Source))
+	}
+	pairs = append(pairs, "msg="+logfmtValue(entry.Message))
+	if entry.File != "" {
+		pairs = append(pairs, "file="+logfmtValue(entry.File))
+	}
+	if entry.LineNum > 0 {
+		pairs = append(pairs, fmt.Sprintf("line=%d", entry.LineNum))
+	}
+
+	if a := entry.Access; a != nil {
+		extra := map[string]string{
+			"method":   a.Method,
+			"path":     a.Path,
+			"status":   fmt.Sprint(a.Status),
+			"bytes":    fmt.Sprint(a.Bytes),
+			"ua":       a.UserAgent,
+			"referrer": a.Referrer,
+			"host":     a.Hostname,
+			"cache":    a.CacheStatus,
+		}
+		keys := make([]string, 0, len(extra))
+		for k, v := range extra {
+			if v != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			pairs = append(pairs, k+"="+logfmtValue(extra[k]))
+		}
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+logfmtValue(parser.FieldString(entry.Fields[k])))
+	}
+
+	_, err := l.w.WriteString(strings.Join(pairs, " ") + "\n")
+	return err
+}
+
+func (l *logfmtWriter) Close() error {
+	return l.w.Flush()
+}
+
+// logfmtValue quotes a value when it is empty or contains spaces, quotes or
+// equals signs
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " =\"\t\n") {
+		return fmt.Sprintf("%q", v)
+	}
+	return v
+}
@@ -0,0 +1,94 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+var testEntries = []parser.Entry{
+	{
+		Timestamp: time.Date(2024, 3, 1, 12, 0, 5, 0, time.UTC),
+		Level:     "ERROR",
+		Message:   `upload "report" failed`,
+		Source:    "worker-1",
+		File:      "app.log",
+		LineNum:   7,
+		Fields:    map[string]interface{}{"retry": "2", "user": "Jane Doe"},
+	},
+	{Level: "INFO", Message: "started"},
+}
+
+// write writes the test entries in a format and returns the output
+func write(t *testing.T, format string, verbose bool, entries []parser.Entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, format, verbose)
+	for _, entry := range entries {
+		if err := w.Write(entry); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.String()
+}
+
+func TestWriters(t *testing.T) {
+	tests := []struct {
+		format  string
+		verbose bool
+		want    string
+	}{
+		{"", false, "12:00:05 [ERROR] upload \"report\" failed\n[INFO] started\n"},
+		{"text", true, "app.log:7: [2024-03-01 12:00:05] [ERROR] [worker-1] upload \"report\" failed\n" +
+			"[0001-01-01 00:00:00] [INFO] [] started\n"},
+		{"csv", false, "Timestamp,Level,Source,Message,File,Line\n" +
+			"2024-03-01 12:00:05,ERROR,worker-1,\"upload \"\"report\"\" failed\",\"app.log\",7\n" +
+			",INFO,,\"started\",\"\",\n"},
+		{"logfmt", false, "time=2024-03-01T12:00:05Z level=error source=worker-1 msg=\"upload \\\"report\\\" failed\" file=app.log line=7 retry=2 user=\"Jane Doe\"\n" +
+			"level=info msg=started\n"},
+	}
+	for _, tt := range tests {
+		if got := write(t, tt.format, tt.verbose, testEntries); got != tt.want {
+			t.Errorf("format %q:\ngot  %q\nwant %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestJSONWriters(t *testing.T) {
+	var array []parser.Entry
+	if err := json.Unmarshal([]byte(write(t, "json", false, testEntries)), &array); err != nil {
+		t.Fatalf("json output does not decode: %v", err)
+	}
+	if len(array) != 2 || array[0].Message != testEntries[0].Message || array[0].Fields["user"] != "Jane Doe" {
+		t.Errorf("json output = %+v", array)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace([]byte(write(t, "ndjson", false, testEntries))), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("ndjson wrote %d lines, want 2", len(lines))
+	}
+	var entry parser.Entry
+	if err := json.Unmarshal(lines[1], &entry); err != nil || entry.Message != "started" {
+		t.Errorf("ndjson line 2 = %s (%v)", lines[1], err)
+	}
+}
+
+func TestEmptyOutput(t *testing.T) {
+	tests := map[string]interface{}{
+		"json":   "[]\n",
+		"ndjson": "",
+		"csv":    "Timestamp,Level,Source,Message,File,Line\n",
+		"text":   "",
+	}
+	for format, want := range tests {
+		if got := write(t, format, false, nil); got != want {
+			t.Errorf("empty %s output = %q, want %q", format, got, want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	apachePattern = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d+) (\d+)`)
+	// Anything after the user agent is kept to find a cache status, as
+	// logged by nginx's $upstream_cache_status or varnishncsa's hitmiss
+	nginxPattern = regexp.MustCompile(`^(\S+) - - \[([^\]]+)\] "([^"]*)" (\d+) (\d+) "([^"]*)" "([^"]*)"(.*)`)
+)
+
+// cacheStatuses are the cache results recognized after the user agent
+var cacheStatuses = map[string]bool{
+	"HIT": true, "MISS": true, "STALE": true, "EXPIRED": true, "BYPASS": true,
+	"UPDATING": true, "REVALIDATED": true, "PASS": true, "SYNTH": true, "PIPE": true,
+}
+
+// accessParser reads apache common and nginx combined access log lines; the
+// combined pattern adds the referrer and user agent
+type accessParser struct {
+	pattern *regexp.Regexp
+}
+
+func (p accessParser) Detect(line string) bool {
+	return p.pattern.MatchString(line)
+}
+
+func (p accessParser) Parse(line string) (*Entry, error) {
+	matches := p.pattern.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, errNoMatch
+	}
+
+	entry := &Entry{Raw: line, Source: matches[1], Message: matches[3]}
+	if t, err := time.Parse("02/Jan/2006:15:04:05 -0700", matches[2]); err == nil {
+		entry.Timestamp = t
+	}
+
+	access := &AccessInfo{ClientIP: matches[1]}
+	access.Method, access.Path, access.Protocol = SplitRequestLine(matches[3])
+	access.Bytes, _ = strconv.ParseInt(matches[5], 10, 64)
+	if len(matches) >= 8 {
+		if matches[6] != "-" {
+			access.Referrer = matches[6]
+		}
+		access.UserAgent = matches[7]
+		access.Agent = ParseUserAgent(matches[7])
+	}
+	if len(matches) >= 9 {
+		for _, field := range strings.Fields(matches[8]) {
+			if status := strings.ToUpper(strings.Trim(field, `"`)); cacheStatuses[status] {
+				access.CacheStatus = status
+				break
+			}
+		}
+	}
+	entry.Access = access
+
+	// Infer level from HTTP status code
+	if status, err := strconv.Atoi(matches[4]); err == nil {
+		access.Status = status
+		entry.Level = statusLevel(status)
+	}
+
+	return entry, nil
+}
+
+// statusLevel maps an HTTP status to a level: 5xx are errors and 4xx
+// warnings
+func statusLevel(status int) string {
+	switch {
+	case status >= 500:
+		return "ERROR"
+	case status >= 400:
+		return "WARN"
+	}
+	return "INFO"
+}
+
+// SplitRequestLine breaks `GET /path HTTP/1.1` into its parts
+func SplitRequestLine(request string) (method, path, protocol string) {
+	parts := strings.Fields(request)
+	switch len(parts) {
+	case 0:
+	case 1:
+		path = parts[0]
+	case 2:
+		method, path = parts[0], parts[1]
+	default:
+		method, path, protocol = parts[0], parts[1], parts[2]
+	}
+	return method, path, protocol
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// [Wed Oct 05 12:00:00.123456 2023] [core:error] [pid 123:tid 456] [client 1.2.3.4:5678] AH00126: message
+// Apache 2.2 logs "[error]" without the module and no pid.
+var apacheErrorPattern = regexp.MustCompile(`^\[(\w{3} \w{3} \d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4})\] \[(?:([\w.-]+):)?(\w+)\](?: \[pid (\d+)(?::tid \d+)?\])?(?: \[client ([^\]]+)\])? (.*)`)
+
+// apacheErrorLevels maps Apache LogLevel names onto the standard levels
+var apacheErrorLevels = map[string]string{
+	"emerg": "FATAL", "alert": "FATAL", "crit": "FATAL",
+	"error": "ERROR", "warn": "WARN", "notice": "INFO", "info": "INFO",
+	"debug": "DEBUG",
+}
+
+// apacheErrorParser reads Apache httpd error_log lines. The module, pid and
+// client address go to Fields; the module is the entry's source.
+type apacheErrorParser struct{}
+
+func (apacheErrorParser) Detect(line string) bool {
+	return apacheErrorPattern.MatchString(line)
+}
+
+func (apacheErrorParser) Parse(line string) (*Entry, error) {
+	m := apacheErrorPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errNoMatch
+	}
+
+	entry := &Entry{Raw: line, Source: m[2], Message: m[6]}
+	for _, layout := range []string{"Mon Jan 02 15:04:05.000000 2006", "Mon Jan 02 15:04:05 2006"} {
+		if t, err := time.Parse(layout, m[1]); err == nil {
+			entry.Timestamp = t
+			break
+		}
+	}
+	entry.Level = apacheErrorLevels[m[3]]
+	if strings.HasPrefix(m[3], "trace") {
+		entry.Level = "TRACE"
+	}
+	if entry.Level == "" {
+		entry.Level = strings.ToUpper(m[3])
+	}
+	if m[2] != "" {
+		entry.SetField("module", m[2])
+	}
+	if m[4] != "" {
+		entry.SetField("pid", m[4])
+	}
+	if m[5] != "" {
+		// The client port is dropped so addresses group together
+		client := m[5]
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+		entry.SetField("client", client)
+	}
+	return entry, nil
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+)
+
+// caddyLog is the part of a Caddy access log record the parser reads
+type caddyLog struct {
+	TS      float64 `json:"ts"`
+	Logger  string  `json:"logger"`
+	Request struct {
+		RemoteIP string              `json:"remote_ip"`
+		ClientIP string              `json:"client_ip"`
+		Proto    string              `json:"proto"`
+		Method   string              `json:"method"`
+		Host     string              `json:"host"`
+		URI      string              `json:"uri"`
+		Headers  map[string][]string `json:"headers"`
+	} `json:"request"`
+	Duration float64 `json:"duration"`
+	Size     int64   `json:"size"`
+	Status   int     `json:"status"`
+}
+
+// caddyParser reads Caddy's JSON access logs, whose timestamp is epoch
+// seconds and whose request details are nested. It is tried before the
+// generic JSON parser.
+type caddyParser struct{}
+
+func (caddyParser) Detect(line string) bool {
+	return strings.HasPrefix(line, "{") && strings.Contains(line, `"logger":"http.log.access`)
+}
+
+func (caddyParser) Parse(line string) (*Entry, error) {
+	var rec caddyLog
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return nil, err
+	}
+	if rec.Request.URI == "" || rec.Status == 0 {
+		return nil, errNoMatch
+	}
+
+	r := rec.Request
+	access := &AccessInfo{
+		ClientIP: r.ClientIP,
+		Method:   r.Method,
+		Path:     r.URI,
+		Protocol: r.Proto,
+		Status:   rec.Status,
+		Bytes:    rec.Size,
+	}
+	if access.ClientIP == "" {
+		access.ClientIP = r.RemoteIP
+	}
+	if ua := r.Headers["User-Agent"]; len(ua) > 0 {
+		access.UserAgent = ua[0]
+		access.Agent = ParseUserAgent(ua[0])
+	}
+	if ref := r.Headers["Referer"]; len(ref) > 0 {
+		access.Referrer = ref[0]
+	}
+
+	entry := &Entry{
+		Raw:     line,
+		Source:  access.ClientIP,
+		Level:   statusLevel(rec.Status),
+		Message: strings.TrimSpace(r.Method + " " + r.URI + " " + r.Proto),
+		Access:  access,
+	}
+	if rec.TS > 0 {
+		sec, frac := math.Modf(rec.TS)
+		entry.Timestamp = time.Unix(int64(sec), int64(frac*1e9)).UTC()
+	}
+	// Seconds as a Go duration, so -slowest reads it without guessing
+	entry.SetField("duration", (time.Duration(rec.Duration * float64(time.Second))).String())
+	if r.Host != "" {
+		entry.SetField("request_host", r.Host)
+	}
+	entry.SetField("logger", rec.Logger)
+	return entry, nil
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cloudfrontPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}\t\d{2}:\d{2}:\d{2}\t`)
+
+// Positions of the CloudFront standard log fields the parser reads
+const (
+	cfDate = iota
+	cfTime
+	cfEdgeLocation
+	cfBytes
+	cfClientIP
+	cfMethod
+	cfHost
+	cfPath
+	cfStatus
+	cfReferrer
+	cfUserAgent
+	cfQuery
+	cfCookie
+	cfResultType
+	cfRequestID
+	cfHostHeader
+	cfProtocol
+	cfRequestBytes
+	cfTimeTaken
+	cfForwardedFor
+	cfSSLProtocol
+	cfSSLCipher
+	cfResponseResultType
+	cfProtocolVersion
+)
+
+// cloudfrontParser reads CloudFront standard (access) logs: tab-separated
+// fields with URL-encoded values. The #Version and #Fields header lines
+// produce no entry.
+type cloudfrontParser struct{}
+
+func (cloudfrontParser) Detect(line string) bool {
+	return cloudfrontPattern.MatchString(line) || strings.HasPrefix(line, "#Version: ") ||
+		strings.HasPrefix(line, "#Fields: date time x-edge-location")
+}
+
+func (cloudfrontParser) Parse(line string) (*Entry, error) {
+	if strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	f := strings.Split(line, "\t")
+	if len(f) <= cfResultType || !cloudfrontPattern.MatchString(line) {
+		return nil, errNoMatch
+	}
+	unescape := func(s string) string {
+		if s == "-" {
+			return ""
+		}
+		if u, err := url.QueryUnescape(s); err == nil {
+			return u
+		}
+		return s
+	}
+
+	access := &AccessInfo{
+		ClientIP:    f[cfClientIP],
+		Method:      f[cfMethod],
+		Path:        f[cfPath],
+		Referrer:    unescape(f[cfReferrer]),
+		UserAgent:   unescape(f[cfUserAgent]),
+		CacheStatus: strings.ToUpper(f[cfResultType]),
+	}
+	if query := f[cfQuery]; query != "-" {
+		access.Path += "?" + query
+	}
+	if len(f) > cfProtocolVersion {
+		access.Protocol = f[cfProtocolVersion]
+	}
+	access.Bytes, _ = strconv.ParseInt(f[cfBytes], 10, 64)
+	access.Status, _ = strconv.Atoi(f[cfStatus])
+	if access.UserAgent != "" {
+		access.Agent = ParseUserAgent(access.UserAgent)
+	}
+
+	entry := &Entry{
+		Raw:    line,
+		Source: access.ClientIP,
+		Level:  statusLevel(access.Status),
+		Access: access,
+	}
+	entry.Message = strings.TrimSpace(access.Method + " " + access.Path + " " + access.Protocol)
+	if t, err := time.Parse("2006-01-02 15:04:05", f[cfDate]+" "+f[cfTime]); err == nil {
+		entry.Timestamp = t
+	}
+	entry.SetField("edge_location", f[cfEdgeLocation])
+	if len(f) > cfTimeTaken {
+		entry.SetField("time_taken", f[cfTimeTaken])
+	}
+	return entry, nil
+}
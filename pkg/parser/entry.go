@@ -0,0 +1,131 @@
+// Package parser turns log lines in the supported formats (syslog, apache
+// access and error logs, nginx, CloudFront, Caddy, Traefik, Rails, Python
+// logging, Go's log package, logrus, the generic "timestamp [LEVEL]
+// message" layout and JSON) into entries.
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry represents a parsed log entry
+type Entry struct {
+	Timestamp time.Time
+	Level     string
+	Message   string
+	Source    string
+	Raw       string
+	// File is the input the entry was read from, empty for stdin
+	File string `json:",omitempty"`
+	// LineNum is the entry's line number in its file, 0 when unknown
+	LineNum int         `json:",omitempty"`
+	Access  *AccessInfo `json:",omitempty"`
+	// Fields holds the other keys of JSON lines, named regex groups and
+	// values derived with -derive. JSON values keep their decoded type
+	// (string, float64, bool, nil or []interface{}); the others are text.
+	Fields map[string]interface{} `json:",omitempty"`
+}
+
+// Field returns the value of a named entry field: source (or ip), level,
+// message (or msg), raw, file, referrer (of access log entries), or a key
+// of Fields as text
+func (e *Entry) Field(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "source", "ip":
+		return e.Source, true
+	case "level":
+		return e.Level, true
+	case "message", "msg":
+		return e.Message, true
+	case "raw":
+		return e.Raw, true
+	case "file":
+		return e.File, true
+	case "referrer", "referer":
+		if e.Access == nil {
+			return "", true
+		}
+		return e.Access.Referrer, true
+	}
+	v, ok := e.Fields[name]
+	return FieldString(v), ok
+}
+
+// SetField stores a value in Fields
+func (e *Entry) SetField(name string, value interface{}) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{})
+	}
+	e.Fields[name] = value
+}
+
+// FieldString formats a Fields value as text: numbers without exponents,
+// null as empty and arrays as JSON
+func FieldString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return ""
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// Levels lists the standard levels from the least to the most severe
+var Levels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// LevelRank returns the position of a level in Levels, or -1 for a level
+// that isn't one of them
+func LevelRank(level string) int {
+	for i, l := range Levels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsError reports whether a level is ERROR or FATAL
+func IsError(level string) bool {
+	return level == "ERROR" || level == "FATAL"
+}
+
+// AccessInfo holds the request details of an apache/nginx access log entry
+type AccessInfo struct {
+	ClientIP  string
+	Method    string
+	Path      string
+	Protocol  string
+	Status    int
+	Bytes     int64
+	Referrer  string         `json:",omitempty"`
+	UserAgent string         `json:",omitempty"`
+	Agent     *UserAgentInfo `json:",omitempty"`
+	Geo       *GeoLocation   `json:",omitempty"`
+	Hostname  string         `json:",omitempty"`
+	// CacheStatus is the CDN or proxy cache result (HIT, MISS, STALE...)
+	CacheStatus string `json:",omitempty"`
+}
+
+// GeoLocation is the subset of a MaxMind record the analyzer reports on
+type GeoLocation struct {
+	CountryCode string
+	Country     string
+	City        string
+}
+
+func (g *GeoLocation) String() string {
+	if g.City != "" {
+		return fmt.Sprintf("(%s, %s)", g.CountryCode, g.City)
+	}
+	return fmt.Sprintf("(%s)", g.CountryCode)
+}
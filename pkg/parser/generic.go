@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var genericPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2})\s+\[(\w+)\]\s+(.*)`)
+
+// genericParser reads "2006-01-02 15:04:05 [LEVEL] message" lines
+type genericParser struct{}
+
+func (genericParser) Detect(line string) bool {
+	return genericPattern.MatchString(line)
+}
+
+func (genericParser) Parse(line string) (*Entry, error) {
+	matches := genericPattern.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, errNoMatch
+	}
+
+	entry := &Entry{Raw: line}
+	if t, err := time.Parse("2006-01-02 15:04:05", matches[1]); err == nil {
+		entry.Timestamp = t
+	}
+	entry.Level = strings.ToUpper(matches[2])
+	entry.Message = matches[3]
+	return entry, nil
+}
+
+// ParseGeneric reads a line as plain text, taking a leading timestamp when
+// there is one and inferring the level from the message
+func ParseGeneric(line string) *Entry {
+	entry := &Entry{Raw: line, Message: line}
+
+	// Try to find timestamp at beginning of line
+	timePatterns := []string{
+		"2006-01-02 15:04:05",
+		"2006/01/02 15:04:05",
+		"Jan 2 15:04:05",
+		"2006-01-02T15:04:05Z07:00",
+	}
+
+	for _, pattern := range timePatterns {
+		if len(line) >= len(pattern) {
+			if t, err := time.Parse(pattern, line[:len(pattern)]); err == nil {
+				entry.Timestamp = t
+				if len(line) > len(pattern)+1 {
+					entry.Message = strings.TrimSpace(line[len(pattern)+1:])
+				}
+				break
+			}
+		}
+	}
+
+	// Infer log level from message content
+	entry.Level = InferLevel(entry.Message)
+
+	return entry
+}
+
+// InferLevel guesses a level from keywords in a message, defaulting to INFO
+func InferLevel(message string) string {
+	message = strings.ToUpper(message)
+
+	if strings.Contains(message, "FATAL") || strings.Contains(message, "CRITICAL") || strings.Contains(message, "PANIC") {
+		return "FATAL"
+	}
+	if strings.Contains(message, "ERROR") {
+		return "ERROR"
+	}
+	if strings.Contains(message, "WARN") || strings.Contains(message, "WARNING") {
+		return "WARN"
+	}
+	if strings.Contains(message, "DEBUG") {
+		return "DEBUG"
+	}
+	if strings.Contains(message, "TRACE") {
+		return "TRACE"
+	}
+
+	return "INFO"
+}
@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+)
+
+// The standard library log package's default prefix, with microseconds
+// when Lmicroseconds is set and the caller with Lshortfile or Llongfile:
+// 2023/10/05 12:00:00 main.go:42: message
+var goLogPattern = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) (?:(\S+\.go:\d+): )?(.*)`)
+
+// goLogParser reads lines written by Go's log package. The caller's
+// file:line is the entry's source; the level is inferred from the message.
+type goLogParser struct{}
+
+func (goLogParser) Detect(line string) bool {
+	return goLogPattern.MatchString(line)
+}
+
+func (goLogParser) Parse(line string) (*Entry, error) {
+	m := goLogPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errNoMatch
+	}
+
+	entry := &Entry{Raw: line, Source: m[2], Message: m[3], Level: InferLevel(m[3])}
+	if t, err := time.Parse("2006/01/02 15:04:05.999999", m[1]); err == nil {
+		entry.Timestamp = t
+	}
+	return entry, nil
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonParser reads structured JSON lines
+type jsonParser struct{}
+
+func (jsonParser) Detect(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "{")
+}
+
+func (jsonParser) Parse(line string) (*Entry, error) {
+	return parseJSON(line)
+}
+
+// levelAliases maps the level names of zap, zerolog, logrus and other
+// loggers onto the standard levels
+var levelAliases = map[string]string{
+	"WARNING": "WARN", "CRITICAL": "FATAL", "PANIC": "FATAL", "DPANIC": "FATAL",
+}
+
+// jsonTimeLayouts are tried in order on string timestamps; zap's ISO8601
+// encoder writes the offset without a colon
+var jsonTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05.999999999Z0700"}
+
+// ParseJSON reads the timestamp/ts/time, level, message/msg and
+// source/component/logger (or caller) fields of a JSON line, following the
+// conventions of zap and zerolog, and keeps every other key in Fields,
+// nested objects flattened to dotted keys (http.status). A line with an
+// error but no message takes the error as its message. Invalid JSON is kept
+// as the message.
+func ParseJSON(line string) *Entry {
+	entry, err := parseJSON(line)
+	if err != nil {
+		return &Entry{Raw: line, Message: line}
+	}
+	return entry
+}
+
+// parseJSON is ParseJSON that reports invalid JSON, so the jsonParser
+// leaves such lines to the plain-text fallback
+func parseJSON(line string) (*Entry, error) {
+	var jsonData map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &jsonData); err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{Raw: line}
+	// used holds the keys read into the entry's own fields
+	used := make(map[string]bool)
+
+	// Try to extract common fields
+	for _, key := range []string{"timestamp", "ts", "time"} {
+		if t, ok := jsonTime(jsonData[key]); ok {
+			entry.Timestamp = t
+			used[key] = true
+			break
+		}
+	}
+
+	// Numeric levels (bunyan, pino) are kept as numbers for -level-map
+	switch level := jsonData["level"].(type) {
+	case string:
+		entry.Level = strings.ToUpper(level)
+		if mapped, ok := levelAliases[entry.Level]; ok {
+			entry.Level = mapped
+		}
+		used["level"] = true
+	case float64:
+		entry.Level = strconv.FormatFloat(level, 'f', -1, 64)
+		used["level"] = true
+	}
+
+	if message, ok := jsonData["message"].(string); ok {
+		entry.Message = message
+		used["message"] = true
+	} else if msg, ok := jsonData["msg"].(string); ok {
+		entry.Message = msg
+		used["msg"] = true
+	} else if err, ok := jsonData["error"].(string); ok {
+		// zerolog's Err() without Msg() logs only the error
+		entry.Message = err
+	}
+
+	for _, key := range []string{"source", "component", "logger", "caller"} {
+		if source, ok := jsonData[key].(string); ok {
+			entry.Source = source
+			used[key] = true
+			break
+		}
+	}
+
+	for key, value := range jsonData {
+		if !used[key] {
+			setJSONField(entry, key, value)
+		}
+	}
+
+	return entry, nil
+}
+
+// jsonTime reads an RFC 3339 timestamp or a Unix time, whose unit follows
+// from its magnitude: seconds (zap's default ts) below 1e11, milliseconds
+// (zerolog's TimeFormatUnixMs) below 1e14, microseconds below 1e17 and
+// nanoseconds above. Numbers before 2001 in seconds are taken for something
+// else, such as a request time.
+func jsonTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		for _, layout := range jsonTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	case float64:
+		switch {
+		case v < 1e9:
+			return time.Time{}, false
+		case v >= 1e17:
+			return time.Unix(0, int64(v)).UTC(), true
+		case v >= 1e14:
+			return time.UnixMicro(int64(v)).UTC(), true
+		case v >= 1e11:
+			return time.UnixMilli(int64(v)).UTC(), true
+		}
+		// A float64 epoch holds about microsecond precision
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// setJSONField stores a decoded JSON value in Fields as it was decoded, so
+// JSON output keeps its type; objects become one field per key
+func setJSONField(entry *Entry, key string, value interface{}) {
+	if v, ok := value.(map[string]interface{}); ok {
+		for k, nested := range v {
+			setJSONField(entry, key+"."+k, nested)
+		}
+		return
+	}
+	entry.SetField(key, value)
+}
@@ -0,0 +1,242 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// log4jDateFormats are the named %d formats of Log4j 2 and Logback. ISO8601
+// has a T in Log4j 2 and a space in Logback, so both are accepted.
+var log4jDateFormats = map[string][]string{
+	"DEFAULT":       {"yyyy-MM-dd HH:mm:ss,SSS"},
+	"ISO8601":       {"yyyy-MM-dd'T'HH:mm:ss,SSS", "yyyy-MM-dd HH:mm:ss,SSS"},
+	"ISO8601_BASIC": {"yyyyMMdd'T'HHmmss,SSS"},
+	"ABSOLUTE":      {"HH:mm:ss,SSS"},
+	"DATE":          {"dd MMM yyyy HH:mm:ss,SSS"},
+}
+
+// log4jGroups maps conversion words onto the named groups they fill; the
+// level, logger and message fill the entry and the others go to Fields.
+// The caller's file and line are named apart from the entry's own.
+var log4jGroups = map[string]string{
+	"p": "level", "le": "level", "level": "level",
+	"c": "source", "lo": "source", "logger": "source",
+	"m": "message", "msg": "message", "message": "message",
+	"t": "thread", "thread": "thread",
+	"C": "class", "class": "class",
+	"M": "method", "method": "method",
+	"L": "caller_line", "line": "caller_line",
+	"F": "caller_file", "file": "caller_file",
+	"l": "location", "location": "location",
+	"r": "elapsed", "relative": "elapsed",
+	"x": "ndc", "NDC": "ndc",
+	"X": "mdc", "mdc": "mdc", "MDC": "mdc",
+}
+
+// log4jPatterns is what each group matches; groups not listed match a
+// single word
+var log4jPatterns = map[string]string{
+	"message":     `.*`,
+	"thread":      `.*?`,
+	"ndc":         `.*?`,
+	"mdc":         `.*?`,
+	"caller_line": `\d+`,
+	"elapsed":     `\d+`,
+}
+
+// log4jConversion matches a conversion after its %: the format modifier
+// (-5, .30), the conversion word and its {options}
+var log4jConversion = regexp.MustCompile(`^(-?)(\d*)(?:\.-?\d+)?([a-zA-Z]+)((?:\{[^}]*\})*)`)
+
+// NewLog4jParser returns a Parser for lines written with a Log4j or Logback
+// PatternLayout such as "%d{ISO8601} [%t] %-5p %c - %m%n". The date, level,
+// logger and message fill the entry; the thread, MDC values (%X{key}) and
+// location conversions go to Fields.
+func NewLog4jParser(layout string) (Parser, error) {
+	pattern, timeLayouts, err := compileLog4j(layout)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexParser{re: re, layouts: timeLayouts}, nil
+}
+
+// compileLog4j turns a PatternLayout into a regular expression with the
+// named groups regexParser reads, and the Go layouts of its date
+func compileLog4j(layout string) (pattern string, timeLayouts []string, err error) {
+	var b strings.Builder
+	b.WriteString("^")
+	rest := strings.TrimSpace(layout)
+	for rest != "" {
+		i := strings.IndexByte(rest, '%')
+		if i < 0 {
+			b.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		b.WriteString(regexp.QuoteMeta(rest[:i]))
+		rest = rest[i+1:]
+		if strings.HasPrefix(rest, "%") {
+			b.WriteString("%")
+			rest = rest[1:]
+			continue
+		}
+
+		m := log4jConversion.FindStringSubmatch(rest)
+		if m == nil {
+			return "", nil, fmt.Errorf("bad conversion at %%%s", rest)
+		}
+		rest = rest[len(m[0]):]
+		leftAlign, width, word, options := m[1] == "-", m[2] != "", m[3], m[4]
+
+		var group string
+		switch word {
+		case "n":
+			// Lines are read without their line ending
+			continue
+		case "ex", "exception", "throwable", "xEx", "xException", "rEx", "wEx":
+			// Stack traces follow on lines of their own
+			continue
+		case "d", "date":
+			formats, err := log4jDate(options)
+			if err != nil {
+				return "", nil, err
+			}
+			var alternatives []string
+			for _, format := range formats {
+				re, goLayout, err := javaDateLayout(format)
+				if err != nil {
+					return "", nil, err
+				}
+				alternatives = append(alternatives, re)
+				timeLayouts = append(timeLayouts, goLayout)
+			}
+			group = `(?P<timestamp>` + strings.Join(alternatives, "|") + `)`
+		default:
+			name, ok := log4jGroups[word]
+			if !ok {
+				return "", nil, fmt.Errorf("unsupported conversion %%%s", word)
+			}
+			if name == "mdc" && len(options) > 2 {
+				// %X{requestId} is its own field
+				name = groupName(options[1 : len(options)-1])
+			}
+			match, ok := log4jPatterns[name]
+			if !ok {
+				match = `\S+`
+			}
+			group = `(?P<` + name + `>` + match + `)`
+		}
+
+		// Padded values are followed (-5) or preceded (5) by spaces
+		switch {
+		case width && leftAlign:
+			group += ` *`
+		case width:
+			group = ` *` + group
+		}
+		b.WriteString(group)
+	}
+	b.WriteString("$")
+	return b.String(), timeLayouts, nil
+}
+
+// log4jDate returns the Java date formats of a %d conversion's options
+func log4jDate(options string) ([]string, error) {
+	if options == "" {
+		return log4jDateFormats["DEFAULT"], nil
+	}
+	// A second option is the time zone, which the timestamp doesn't show
+	format := options[1:strings.IndexByte(options, '}')]
+	if named, ok := log4jDateFormats[format]; ok {
+		return named, nil
+	}
+	if strings.HasPrefix(format, "UNIX") {
+		return nil, fmt.Errorf("unsupported date format %s", format)
+	}
+	return []string{format}, nil
+}
+
+// javaDateLetters maps runs of SimpleDateFormat letters onto a regular
+// expression and Go layout element; runs of S are handled separately
+var javaDateLetters = map[string][2]string{
+	"yyyy": {`\d{4}`, "2006"}, "yy": {`\d{2}`, "06"}, "y": {`\d{4}`, "2006"},
+	"MMMM": {`[A-Za-z]+`, "January"}, "MMM": {`[A-Za-z]{3}`, "Jan"},
+	"MM": {`\d{2}`, "01"}, "M": {`\d{1,2}`, "1"},
+	"dd": {`\d{2}`, "02"}, "d": {`\d{1,2}`, "2"},
+	"HH": {`\d{2}`, "15"}, "H": {`\d{1,2}`, "15"},
+	"hh": {`\d{2}`, "03"}, "h": {`\d{1,2}`, "3"},
+	"mm": {`\d{2}`, "04"}, "m": {`\d{1,2}`, "4"},
+	"ss": {`\d{2}`, "05"}, "s": {`\d{1,2}`, "5"},
+	"a":    {`[AaPp][Mm]`, "PM"},
+	"EEEE": {`[A-Za-z]+`, "Monday"}, "EEE": {`[A-Za-z]{3}`, "Mon"},
+	"z": {`[A-Za-z]+`, "MST"}, "Z": {`[+-]\d{4}`, "-0700"},
+	"X": {`(?:Z|[+-]\d{2})`, "Z07"}, "XX": {`(?:Z|[+-]\d{4})`, "Z0700"},
+	"XXX": {`(?:Z|[+-]\d{2}:\d{2})`, "Z07:00"},
+}
+
+// javaDateLayout converts a SimpleDateFormat pattern such as
+// "yyyy-MM-dd HH:mm:ss,SSS" into a regular expression matching it and the
+// equivalent Go time layout
+func javaDateLayout(format string) (pattern, layout string, err error) {
+	var re, goLayout strings.Builder
+	for i := 0; i < len(format); {
+		c := format[i]
+		switch {
+		case c == '\'':
+			// Quoted text, with '' for a quote
+			end := strings.IndexByte(format[i+1:], '\'')
+			if end < 0 {
+				end = len(format) - i - 1
+			}
+			text := format[i+1 : i+1+end]
+			if end == 0 {
+				text = "'"
+			}
+			re.WriteString(regexp.QuoteMeta(text))
+			goLayout.WriteString(text)
+			i += end + 2
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(format) && format[j] == c {
+				j++
+			}
+			run := format[i:j]
+			i = j
+			if c == 'S' {
+				re.WriteString(fmt.Sprintf(`\d{%d}`, len(run)))
+				goLayout.WriteString(strings.Repeat("0", len(run)))
+				continue
+			}
+			// Longer runs than the table knows use its longest form
+			elem, ok := javaDateLetters[run]
+			for !ok && len(run) > 1 {
+				run = run[:len(run)-1]
+				elem, ok = javaDateLetters[run]
+			}
+			if !ok {
+				return "", "", fmt.Errorf("unsupported date letter %c in %q", c, format)
+			}
+			re.WriteString(elem[0])
+			goLayout.WriteString(elem[1])
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+			goLayout.WriteByte(c)
+			i++
+		}
+	}
+	return re.String(), goLayout.String(), nil
+}
+
+// groupName makes an MDC key usable as a regexp group name
+func groupName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, key)
+}
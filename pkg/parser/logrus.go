@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logrusParser reads logrus's default text output, which is logfmt with
+// time, level and msg keys:
+// time="2023-10-05T12:00:00Z" level=info msg="started" port=8080
+// Other keys go to Fields.
+type logrusParser struct{}
+
+func (logrusParser) Detect(line string) bool {
+	return (strings.HasPrefix(line, "time=") || strings.HasPrefix(line, "level=")) &&
+		strings.Contains(line, " msg=")
+}
+
+func (logrusParser) Parse(line string) (*Entry, error) {
+	pairs, ok := parseLogfmt(line)
+	if !ok || !hasLogfmtKey(pairs, "msg") {
+		return nil, errNoMatch
+	}
+
+	entry := &Entry{Raw: line}
+	for _, kv := range pairs {
+		key, value := kv[0], kv[1]
+		switch key {
+		case "time":
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				entry.Timestamp = t
+			}
+		case "level":
+			entry.Level = strings.ToUpper(value)
+			if mapped, ok := levelAliases[entry.Level]; ok {
+				entry.Level = mapped
+			}
+		case "msg":
+			entry.Message = value
+		default:
+			entry.SetField(key, value)
+		}
+	}
+	if entry.Message == "" {
+		entry.Message, _ = entry.Field("error")
+	}
+	return entry, nil
+}
+
+// hasLogfmtKey reports whether one of the pairs has the key
+func hasLogfmtKey(pairs [][2]string, key string) bool {
+	for _, kv := range pairs {
+		if kv[0] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLogfmt splits a line into its key=value pairs in order. Quoted values
+// use Go escapes; a key without a value has an empty one.
+func parseLogfmt(line string) (pairs [][2]string, ok bool) {
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		end := strings.IndexAny(rest, "= ")
+		if end == 0 {
+			return nil, false
+		}
+		if end < 0 || rest[end] == ' ' {
+			if end < 0 {
+				end = len(rest)
+			}
+			pairs = append(pairs, [2]string{rest[:end], ""})
+			rest = strings.TrimLeft(rest[end:], " ")
+			continue
+		}
+		key := rest[:end]
+		rest = rest[end+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else {
+			end = strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			value = rest[:end]
+			rest = rest[end:]
+		}
+		pairs = append(pairs, [2]string{key, value})
+		rest = strings.TrimLeft(rest, " ")
+	}
+	return pairs, true
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Parser handles one log format. Detect reports whether a line looks like
+// the format and is used when the format is "auto"; Parse returns an error
+// for lines it can't read, which then fall back to ParseGeneric, and a nil
+// entry for lines of the format that hold none, such as file headers.
+type Parser interface {
+	Detect(line string) bool
+	Parse(line string) (*Entry, error)
+}
+
+// errNoMatch is returned by the pattern-based parsers for other lines
+var errNoMatch = errors.New("line does not match the format")
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Parser{}
+	// order is the registration order, which is the order "auto" tries
+	// the formats in
+	order []string
+)
+
+// Register makes a format available by name, to -format and to "auto"
+// detection after the formats registered before it. It panics when the
+// name is taken, like database/sql.Register.
+func Register(name string, p Parser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if name == "auto" {
+		panic("parser: \"auto\" is reserved")
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("parser: Register called twice for format %q", name))
+	}
+	registry[name] = p
+	order = append(order, name)
+}
+
+// Lookup returns the parser registered under name
+func Lookup(name string) (Parser, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names lists the registered formats in detection order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]string(nil), order...)
+}
+
+func init() {
+	// nginx (combined) before apache so the referrer and user agent aren't
+	// lost to the shorter common log format match; for the same reason
+	// traefik, which extends both combined and JSON lines, and caddy come
+	// first
+	Register("traefik", traefikParser{})
+	Register("caddy", caddyParser{})
+	Register("json", jsonParser{})
+	Register("generic", genericParser{})
+	Register("syslog", syslogParser{})
+	Register("nginx", accessParser{pattern: nginxPattern})
+	Register("apache", accessParser{pattern: apachePattern})
+	Register("apache-error", apacheErrorParser{})
+	Register("cloudfront", cloudfrontParser{})
+	Register("rails", railsParser{})
+	Register("python", pythonParser{})
+	Register("go", goLogParser{})
+	Register("logrus", logrusParser{})
+}
+
+// ParseLine parses a line in the given format, or with the first registered
+// format that detects it when format is "auto". Lines no parser reads are
+// still returned, as plain text with a detected timestamp and level; lines
+// that hold no entry (headers) return nil.
+func ParseLine(line, format string) *Entry {
+	entry, _ := TryParse(line, format)
+	return entry
+}
+
+// TryParse parses a line like ParseLine and reports whether a format read
+// it; when none did, the entry is the ParseGeneric fallback
+func TryParse(line, format string) (*Entry, bool) {
+	if format != "auto" {
+		if p, ok := Lookup(format); ok {
+			if entry, err := p.Parse(line); err == nil {
+				return entry, true
+			}
+		}
+		return ParseGeneric(line), false
+	}
+
+	for _, name := range Names() {
+		p, _ := Lookup(name)
+		if !p.Detect(line) {
+			continue
+		}
+		if entry, err := p.Parse(line); err == nil {
+			return entry, true
+		}
+	}
+
+	// Fallback: treat as plain text with timestamp detection
+	return ParseGeneric(line), false
+}
+
+// formatParser is the Parser for a format name, including "auto"
+type formatParser struct {
+	format string
+}
+
+// New returns a Parser for a registered format name, or for "auto" to pick
+// a format line by line
+func New(format string) Parser {
+	return formatParser{format: format}
+}
+
+func (p formatParser) Detect(line string) bool {
+	if p.format != "auto" {
+		f, ok := Lookup(p.format)
+		return ok && f.Detect(line)
+	}
+	for _, name := range Names() {
+		if f, _ := Lookup(name); f.Detect(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p formatParser) Parse(line string) (*Entry, error) {
+	return ParseLine(line, p.format), nil
+}
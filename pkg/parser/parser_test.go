@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTryParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		format  string
+		matched bool
+		want    Entry
+	}{
+		{
+			name:    "generic",
+			line:    "2024-03-01 12:00:00 [error] disk full",
+			format:  "auto",
+			matched: true,
+			want:    Entry{Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Level: "ERROR", Message: "disk full"},
+		},
+		{
+			name:    "json",
+			line:    `{"time":"2024-03-01T12:00:00Z","level":"warn","msg":"slow query","logger":"db"}`,
+			format:  "auto",
+			matched: true,
+			want:    Entry{Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Level: "WARN", Message: "slow query", Source: "db"},
+		},
+		{
+			name:    "syslog with priority",
+			line:    "<11>Mar  1 12:00:00 web01 nginx: upstream timed out",
+			format:  "syslog",
+			matched: true,
+			want:    Entry{Level: "ERROR", Message: "upstream timed out", Source: "web01"},
+		},
+		{
+			name:    "rfc 5424 syslog",
+			line:    "<14>1 2024-03-01T12:00:00Z web01 sshd 42 - - Accepted publickey",
+			format:  "auto",
+			matched: true,
+			want:    Entry{Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Level: "INFO", Message: "sshd: Accepted publickey", Source: "web01"},
+		},
+		{
+			name:    "nginx",
+			line:    `10.0.0.1 - - [01/Mar/2024:12:00:00 +0000] "GET /health HTTP/1.1" 503 12 "-" "curl/8.0"`,
+			format:  "auto",
+			matched: true,
+			want:    Entry{Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Level: "ERROR", Source: "10.0.0.1"},
+		},
+		{
+			name:    "plain text fallback",
+			line:    "unexpected error in worker",
+			format:  "auto",
+			matched: false,
+			want:    Entry{Level: "ERROR", Message: "unexpected error in worker"},
+		},
+		{
+			name:    "line not in the given format",
+			line:    "2024-03-01 12:00:00 [INFO] started",
+			format:  "json",
+			matched: false,
+			want:    Entry{Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Level: "INFO"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, matched := TryParse(tt.line, tt.format)
+			if matched != tt.matched {
+				t.Errorf("matched = %v, want %v", matched, tt.matched)
+			}
+			if entry == nil {
+				t.Fatal("entry is nil")
+			}
+			if entry.Raw != tt.line {
+				t.Errorf("Raw = %q, want %q", entry.Raw, tt.line)
+			}
+			if !tt.want.Timestamp.IsZero() && !entry.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("Timestamp = %v, want %v", entry.Timestamp, tt.want.Timestamp)
+			}
+			if entry.Level != tt.want.Level {
+				t.Errorf("Level = %q, want %q", entry.Level, tt.want.Level)
+			}
+			if tt.want.Message != "" && entry.Message != tt.want.Message {
+				t.Errorf("Message = %q, want %q", entry.Message, tt.want.Message)
+			}
+			if entry.Source != tt.want.Source {
+				t.Errorf("Source = %q, want %q", entry.Source, tt.want.Source)
+			}
+		})
+	}
+}
+
+func TestParseAccessLine(t *testing.T) {
+	line := `203.0.113.9 - - [01/Mar/2024:12:00:00 +0000] "POST /api/login HTTP/2.0" 401 57 "https://example.com/" "Mozilla/5.0"`
+	entry := ParseLine(line, "nginx")
+	if entry.Access == nil {
+		t.Fatal("Access is nil")
+	}
+	a := entry.Access
+	if a.Method != "POST" || a.Path != "/api/login" || a.Protocol != "HTTP/2.0" {
+		t.Errorf("request = %q %q %q, want POST /api/login HTTP/2.0", a.Method, a.Path, a.Protocol)
+	}
+	if a.Status != 401 || a.Bytes != 57 {
+		t.Errorf("status, bytes = %d, %d, want 401, 57", a.Status, a.Bytes)
+	}
+	if a.Referrer != "https://example.com/" {
+		t.Errorf("Referrer = %q", a.Referrer)
+	}
+	if entry.Level != "WARN" {
+		t.Errorf("Level = %q, want WARN", entry.Level)
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	entry := ParseLine(`{"msg":"done","status":200,"latency":0.25,"user":{"id":"u1"},"tags":["a","b"]}`, "json")
+	want := map[string]string{
+		"status":  "200",
+		"latency": "0.25",
+		"user.id": "u1",
+		"tags":    `["a","b"]`,
+	}
+	for key, value := range want {
+		if got, ok := entry.Field(key); !ok || got != value {
+			t.Errorf("Field(%q) = %q, %v, want %q", key, got, ok, value)
+		}
+	}
+	if _, ok := entry.Field("msg"); !ok {
+		t.Error("Field(msg) not found")
+	}
+
+	// Fields keep their JSON types, so JSON output does too
+	data, err := json.Marshal(entry.Fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"latency":0.25,"status":200,"tags":["a","b"],"user.id":"u1"}`; got != want {
+		t.Errorf("Fields as JSON = %s, want %s", got, want)
+	}
+}
+
+func TestJSONEpochTime(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ts   string
+		want time.Time
+	}{
+		{"1709294400", want},
+		{"1709294400.5", want.Add(500 * time.Millisecond)},
+		{"1709294400000", want},
+		{"1709294400000000", want},
+		{"1709294400000000000", want},
+		{"250", time.Time{}},
+	}
+	for _, tt := range tests {
+		entry := ParseLine(`{"ts":`+tt.ts+`,"msg":"x"}`, "json")
+		if !entry.Timestamp.Equal(tt.want) {
+			t.Errorf("ts %s: Timestamp = %v, want %v", tt.ts, entry.Timestamp, tt.want)
+		}
+	}
+}
+
+func TestSetPriority(t *testing.T) {
+	tests := []struct {
+		pri      string
+		ok       bool
+		level    string
+		facility string
+		severity string
+	}{
+		{"0", true, "FATAL", "kern", "emerg"},
+		{"11", true, "ERROR", "user", "err"},
+		{"38", true, "INFO", "auth", "info"},
+		{"191", true, "DEBUG", "local7", "debug"},
+		{"192", false, "", "", ""},
+		{"", false, "", "", ""},
+		{"x", false, "", "", ""},
+	}
+	for _, tt := range tests {
+		entry := &Entry{}
+		if ok := SetPriority(entry, tt.pri); ok != tt.ok {
+			t.Errorf("SetPriority(%q) = %v, want %v", tt.pri, ok, tt.ok)
+			continue
+		}
+		facility, _ := entry.Field("facility")
+		severity, _ := entry.Field("severity")
+		if entry.Level != tt.level || facility != tt.facility || severity != tt.severity {
+			t.Errorf("SetPriority(%q) set %q %q %q, want %q %q %q", tt.pri,
+				entry.Level, facility, severity, tt.level, tt.facility, tt.severity)
+		}
+	}
+}
+
+func TestLevelRank(t *testing.T) {
+	if LevelRank("DEBUG") >= LevelRank("WARN") || LevelRank("WARN") >= LevelRank("FATAL") {
+		t.Errorf("levels out of order: %v", Levels)
+	}
+	if got := LevelRank("NOTICE"); got != -1 {
+		t.Errorf("LevelRank(NOTICE) = %d, want -1", got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	for _, name := range []string{"auto", "json"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", name)
+				}
+			}()
+			Register(name, genericParser{})
+		}()
+	}
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+)
+
+// Python logging's common "%(asctime)s - %(name)s - %(levelname)s -
+// %(message)s" layout:
+// 2024-10-10 13:55:36,123 - app.db - WARNING - message
+var pythonPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:,\d{3})?) - (.+?) - ([A-Z]+) - (.*)`)
+
+// pythonLevels maps the logging module's level names onto the standard
+// levels; DEBUG, INFO and ERROR are the same
+var pythonLevels = map[string]string{
+	"WARNING": "WARN", "CRITICAL": "FATAL", "NOTSET": "DEBUG",
+}
+
+// pythonParser reads Python logging lines, taking the logger name as the
+// entry's source
+type pythonParser struct{}
+
+func (pythonParser) Detect(line string) bool {
+	return pythonPattern.MatchString(line)
+}
+
+func (pythonParser) Parse(line string) (*Entry, error) {
+	m := pythonPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errNoMatch
+	}
+
+	entry := &Entry{Raw: line, Source: m[2], Level: m[3], Message: m[4]}
+	if t, err := time.Parse("2006-01-02 15:04:05,000", m[1]); err == nil {
+		entry.Timestamp = t
+	} else if t, err := time.Parse("2006-01-02 15:04:05", m[1]); err == nil {
+		entry.Timestamp = t
+	}
+	if level, ok := pythonLevels[m[3]]; ok {
+		entry.Level = level
+	}
+	return entry, nil
+}
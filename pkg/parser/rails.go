@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Rails' Logger::Formatter prefix, used when production.log isn't
+	// written with the plain formatter:
+	// I, [2024-10-10T13:55:36.123456 #12345]  INFO -- : message
+	railsLoggerPattern = regexp.MustCompile(`^[A-Z], \[(\S+) #(\d+)\]\s+\w+ -- [^:]*: `)
+	// Tags from config.log_tags, such as the request id: [abc-123] message
+	railsTagsPattern = regexp.MustCompile(`^(?:\[[^\]]*\] )+`)
+
+	railsStartedPattern    = regexp.MustCompile(`^Started (\S+) "([^"]*)" for (\S+) at (.+)$`)
+	railsProcessingPattern = regexp.MustCompile(`^Processing by (\S+)#(\S+) as (\S+)`)
+	railsCompletedPattern  = regexp.MustCompile(`^Completed (\d+) .*?in (\d+(?:\.\d+)?)ms`)
+)
+
+// railsParser reads Rails production.log, turning each request's
+// "Started ... / Processing by ... / Completed ..." lines into one entry
+// with controller, action, format and duration fields. Lines are grouped
+// per process and log tags, so requests logged with a request id tag can
+// interleave. Read without a Session, only the Completed lines give
+// entries.
+type railsParser struct{}
+
+func (railsParser) Detect(line string) bool {
+	_, _, msg := splitRailsLine(line)
+	return strings.HasPrefix(msg, "Started ") ||
+		strings.HasPrefix(msg, "Processing by ") ||
+		strings.HasPrefix(msg, "Completed ")
+}
+
+func (p railsParser) Parse(line string) (*Entry, error) {
+	return p.NewState().Parse(line)
+}
+
+func (railsParser) NewState() Parser {
+	return &railsState{pending: map[string]*Entry{}}
+}
+
+// splitRailsLine separates the logger prefix and tags from the message;
+// key identifies the process and tags the line was logged under
+func splitRailsLine(line string) (key, loggedAt, msg string) {
+	msg = line
+	if m := railsLoggerPattern.FindStringSubmatch(msg); m != nil {
+		loggedAt, key = m[1], m[2]
+		msg = msg[len(m[0]):]
+	}
+	if tags := railsTagsPattern.FindString(msg); tags != "" {
+		key += tags
+		msg = msg[len(tags):]
+	}
+	return key, loggedAt, msg
+}
+
+// railsState holds the requests of one input that have started but not
+// completed
+type railsState struct {
+	pending map[string]*Entry
+}
+
+func (s *railsState) Detect(line string) bool {
+	key, _, _ := splitRailsLine(line)
+	_, inRequest := s.pending[key]
+	return inRequest || railsParser{}.Detect(line)
+}
+
+func (s *railsState) Parse(line string) (*Entry, error) {
+	key, loggedAt, msg := splitRailsLine(line)
+	entry := s.pending[key]
+
+	if m := railsStartedPattern.FindStringSubmatch(msg); m != nil {
+		// A request that never completed is dropped
+		entry = &Entry{
+			Raw:     line,
+			Source:  m[3],
+			Message: m[1] + " " + m[2],
+			Access:  &AccessInfo{ClientIP: m[3], Method: m[1], Path: m[2]},
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05 -0700", m[4]); err == nil {
+			entry.Timestamp = t
+		} else if t, err := time.Parse("2006-01-02T15:04:05.999999", loggedAt); err == nil {
+			entry.Timestamp = t
+		}
+		s.pending[key] = entry
+		return nil, nil
+	}
+
+	m := railsCompletedPattern.FindStringSubmatch(msg)
+	if m == nil {
+		if entry == nil {
+			if strings.HasPrefix(msg, "Processing by ") {
+				// The Started line was before the start of the input
+				return nil, nil
+			}
+			return nil, errNoMatch
+		}
+		entry.Raw += "\n" + line
+		if m := railsProcessingPattern.FindStringSubmatch(msg); m != nil {
+			entry.SetField("controller", m[1])
+			entry.SetField("action", m[2])
+			entry.SetField("format", m[3])
+		}
+		return nil, nil
+	}
+
+	if entry == nil {
+		entry = &Entry{Raw: line, Message: msg, Access: &AccessInfo{}}
+		if t, err := time.Parse("2006-01-02T15:04:05.999999", loggedAt); err == nil {
+			entry.Timestamp = t
+		}
+	} else {
+		entry.Raw += "\n" + line
+		delete(s.pending, key)
+	}
+	entry.Access.Status, _ = strconv.Atoi(m[1])
+	entry.Level = statusLevel(entry.Access.Status)
+	if ms, err := strconv.ParseFloat(m[2], 64); err == nil {
+		entry.SetField("duration", time.Duration(ms*float64(time.Millisecond)).String())
+	}
+	return entry, nil
+}
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// regexParser reads lines with a user-supplied pattern
+type regexParser struct {
+	re *regexp.Regexp
+	// layouts are tried in order on the timestamp group
+	layouts []string
+}
+
+// NewRegexParser returns a Parser for a custom pattern. The named groups
+// timestamp, level, message and source fill the entry and any other named
+// group is stored in Fields. The timestamp is read with timeLayout, or as
+// RFC 3339 or "2006-01-02 15:04:05" when it is empty. Without a message
+// group the whole line is the message; without a level group the level is
+// inferred from the message.
+func NewRegexParser(re *regexp.Regexp, timeLayout string) Parser {
+	layouts := []string{timeLayout}
+	if timeLayout == "" {
+		layouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05"}
+	}
+	return regexParser{re: re, layouts: layouts}
+}
+
+func (p regexParser) Detect(line string) bool {
+	return p.re.MatchString(line)
+}
+
+func (p regexParser) Parse(line string) (*Entry, error) {
+	matches := p.re.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, errNoMatch
+	}
+
+	entry := &Entry{Raw: line, Message: line}
+	for i, name := range p.re.SubexpNames() {
+		value := matches[i]
+		switch name {
+		case "":
+		case "timestamp":
+			entry.Timestamp = p.parseTime(value)
+		case "level":
+			entry.Level = strings.ToUpper(value)
+		case "message":
+			entry.Message = value
+		case "source":
+			entry.Source = value
+		default:
+			entry.SetField(name, value)
+		}
+	}
+	if entry.Level == "" {
+		entry.Level = InferLevel(entry.Message)
+	}
+	return entry, nil
+}
+
+func (p regexParser) parseTime(value string) time.Time {
+	for _, layout := range p.layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
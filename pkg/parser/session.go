@@ -0,0 +1,68 @@
+package parser
+
+// Assembler is implemented by formats whose entries span several lines.
+// NewState returns a Parser for one input that sees its lines in order:
+// its Parse returns a nil entry for the lines that only add to a pending
+// entry, and the complete entry with the line that ends it.
+type Assembler interface {
+	Parser
+	NewState() Parser
+}
+
+// Session parses the lines of one input in order, keeping the state of the
+// multi-line formats between them. Other formats parse as with TryParse.
+type Session struct {
+	format string
+	states map[string]Parser
+}
+
+// NewSession starts parsing an input in the given format, or "auto"
+func NewSession(format string) *Session {
+	return &Session{format: format}
+}
+
+// Format is the format the session was started with
+func (s *Session) Format() string {
+	return s.format
+}
+
+// lookup returns the parser for a format, with this input's state for
+// multi-line formats
+func (s *Session) lookup(name string) (Parser, bool) {
+	p, ok := Lookup(name)
+	a, multi := p.(Assembler)
+	if !ok || !multi {
+		return p, ok
+	}
+	if state, ok := s.states[name]; ok {
+		return state, true
+	}
+	if s.states == nil {
+		s.states = map[string]Parser{}
+	}
+	s.states[name] = a.NewState()
+	return s.states[name], true
+}
+
+// TryParse parses the next line of the input like the package TryParse
+func (s *Session) TryParse(line string) (*Entry, bool) {
+	if s.format != "auto" {
+		if p, ok := s.lookup(s.format); ok {
+			if entry, err := p.Parse(line); err == nil {
+				return entry, true
+			}
+		}
+		return ParseGeneric(line), false
+	}
+
+	for _, name := range Names() {
+		p, _ := s.lookup(name)
+		if !p.Detect(line) {
+			continue
+		}
+		if entry, err := p.Parse(line); err == nil {
+			return entry, true
+		}
+	}
+	return ParseGeneric(line), false
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	syslogPattern = regexp.MustCompile(`^(?:<(\d{1,3})>)?(\w+\s+\d+\s+\d+:\d+:\d+) (\S+) ([^:]+): (.*)`)
+	// <PRI>VERSION TIMESTAMP HOST APP PROCID MSGID [SD] MSG
+	rfc5424Pattern = regexp.MustCompile(`^<(\d{1,3})>\d{1,2} (\S+) (\S+) (\S+) (\S+) (\S+) (-|(?:\[.*?\])+) ?(.*)$`)
+)
+
+// syslogParser reads BSD syslog lines: "Jan  2 15:04:05 host program: message",
+// optionally with the <PRI> header of lines captured off the wire, and RFC
+// 5424 messages. Other lines with a <PRI> header parse as plain text with
+// the header's level.
+type syslogParser struct{}
+
+func (syslogParser) Detect(line string) bool {
+	return syslogPattern.MatchString(line) || rfc5424Pattern.MatchString(line)
+}
+
+func (syslogParser) Parse(line string) (*Entry, error) {
+	if m := rfc5424Pattern.FindStringSubmatch(line); m != nil {
+		entry := &Entry{Raw: line}
+		SetPriority(entry, m[1])
+		if t, err := time.Parse(time.RFC3339Nano, m[2]); err == nil {
+			entry.Timestamp = t
+		}
+		if m[3] != "-" {
+			entry.Source = m[3]
+		}
+		entry.Message = strings.TrimPrefix(m[8], "\ufeff")
+		if m[4] != "-" {
+			entry.Message = m[4] + ": " + entry.Message
+		}
+		return entry, nil
+	}
+
+	matches := syslogPattern.FindStringSubmatch(line)
+	if matches == nil {
+		if rest, ok := strings.CutPrefix(line, "<"); ok {
+			if pri, msg, ok := strings.Cut(rest, ">"); ok {
+				entry := ParseGeneric(msg)
+				entry.Raw = line
+				if SetPriority(entry, pri) {
+					return entry, nil
+				}
+			}
+		}
+		return nil, errNoMatch
+	}
+
+	entry := &Entry{Raw: line}
+	if t, err := time.Parse("Jan 2 15:04:05", matches[2]); err == nil {
+		// Add current year since syslog doesn't include it
+		entry.Timestamp = t.AddDate(time.Now().Year(), 0, 0)
+	}
+	entry.Source = matches[3]
+	entry.Message = matches[5]
+	if !SetPriority(entry, matches[1]) {
+		entry.Level = InferLevel(matches[5])
+	}
+	return entry, nil
+}
+
+var (
+	// syslogFacilities names the facility codes (PRI / 8) of RFC 5424
+	syslogFacilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "audit", "alert", "clock",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+	// syslogSeverities names the severities (PRI % 8)
+	syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+	// syslogLevels maps the severities onto the analyzer's levels
+	syslogLevels = []string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+)
+
+// SetPriority decodes a syslog PRI value (facility*8 + severity) into the
+// entry's level and its "facility" and "severity" fields. It reports false,
+// leaving the entry alone, when pri is empty or out of range.
+func SetPriority(entry *Entry, pri string) bool {
+	n, err := strconv.Atoi(pri)
+	if err != nil || n < 0 || n > 191 {
+		return false
+	}
+	entry.Level = syslogLevels[n%8]
+	entry.SetField("facility", syslogFacilities[n/8])
+	entry.SetField("severity", syslogSeverities[n%8])
+	return true
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"encoding/json"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Traefik's common log format adds the request count, router, backend URL
+// and duration after the combined fields:
+// ... "GET / HTTP/1.1" 200 512 "-" "curl/8" 42 "web@docker" "http://10.0.0.2:80" 12ms
+var traefikPattern = regexp.MustCompile(`^(\S+) - \S+ \[([^\]]+)\] "([^"]*)" (\d+) (\d+|-) "([^"]*)" "([^"]*)" \d+ "([^"]*)" "([^"]*)" (\d+)ms`)
+
+// traefikLog is the part of a Traefik JSON access log record the parser
+// reads; Duration is in nanoseconds
+type traefikLog struct {
+	ClientHost            string `json:"ClientHost"`
+	DownstreamContentSize int64  `json:"DownstreamContentSize"`
+	DownstreamStatus      int    `json:"DownstreamStatus"`
+	Duration              int64  `json:"Duration"`
+	RequestMethod         string `json:"RequestMethod"`
+	RequestPath           string `json:"RequestPath"`
+	RequestProtocol       string `json:"RequestProtocol"`
+	RouterName            string `json:"RouterName"`
+	ServiceName           string `json:"ServiceName"`
+	ServiceURL            string `json:"ServiceURL"`
+	StartUTC              string `json:"StartUTC"`
+	UserAgent             string `json:"request_User-Agent"`
+	Referrer              string `json:"request_Referer"`
+}
+
+// traefikParser reads Traefik access logs in the common log format or as
+// JSON, keeping the router, service and duration as fields
+type traefikParser struct{}
+
+func (traefikParser) Detect(line string) bool {
+	if strings.HasPrefix(line, "{") {
+		return strings.Contains(line, `"DownstreamStatus"`)
+	}
+	return traefikPattern.MatchString(line)
+}
+
+func (traefikParser) Parse(line string) (*Entry, error) {
+	if strings.HasPrefix(line, "{") {
+		return parseTraefikJSON(line)
+	}
+	m := traefikPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, errNoMatch
+	}
+
+	access := &AccessInfo{ClientIP: m[1]}
+	access.Method, access.Path, access.Protocol = SplitRequestLine(m[3])
+	access.Status, _ = strconv.Atoi(m[4])
+	access.Bytes, _ = strconv.ParseInt(m[5], 10, 64)
+	if m[6] != "-" {
+		access.Referrer = m[6]
+	}
+	access.UserAgent = m[7]
+	access.Agent = ParseUserAgent(m[7])
+
+	entry := &Entry{Raw: line, Source: m[1], Message: m[3], Level: statusLevel(access.Status), Access: access}
+	if t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[2]); err == nil {
+		entry.Timestamp = t
+	}
+	if m[8] != "-" {
+		entry.SetField("router", m[8])
+	}
+	if m[9] != "-" {
+		entry.SetField("service_url", m[9])
+	}
+	ms, _ := strconv.ParseInt(m[10], 10, 64)
+	entry.SetField("duration", (time.Duration(ms) * time.Millisecond).String())
+	return entry, nil
+}
+
+func parseTraefikJSON(line string) (*Entry, error) {
+	var rec traefikLog
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return nil, err
+	}
+	if rec.DownstreamStatus == 0 {
+		return nil, errNoMatch
+	}
+
+	access := &AccessInfo{
+		ClientIP: rec.ClientHost,
+		Method:   rec.RequestMethod,
+		Path:     rec.RequestPath,
+		Protocol: rec.RequestProtocol,
+		Status:   rec.DownstreamStatus,
+		Bytes:    rec.DownstreamContentSize,
+		Referrer: rec.Referrer,
+	}
+	if host, _, err := net.SplitHostPort(access.ClientIP); err == nil {
+		access.ClientIP = host
+	}
+	if rec.UserAgent != "" {
+		access.UserAgent = rec.UserAgent
+		access.Agent = ParseUserAgent(rec.UserAgent)
+	}
+
+	entry := &Entry{
+		Raw:     line,
+		Source:  access.ClientIP,
+		Level:   statusLevel(access.Status),
+		Message: strings.TrimSpace(access.Method + " " + access.Path + " " + access.Protocol),
+		Access:  access,
+	}
+	if t, err := time.Parse(time.RFC3339Nano, rec.StartUTC); err == nil {
+		entry.Timestamp = t
+	}
+	if rec.RouterName != "" {
+		entry.SetField("router", rec.RouterName)
+	}
+	if rec.ServiceName != "" {
+		entry.SetField("service", rec.ServiceName)
+	}
+	if rec.ServiceURL != "" {
+		entry.SetField("service_url", rec.ServiceURL)
+	}
+	entry.SetField("duration", time.Duration(rec.Duration).String())
+	return entry, nil
+}
@@ -0,0 +1,103 @@
+package parser
+
+import "strings"
+
+// UserAgentInfo is the classification of a User-Agent header
+type UserAgentInfo struct {
+	Browser string
+	OS      string
+	Device  string
+	Bot     bool
+}
+
+// botMarkers are substrings (lowercase) identifying crawlers, monitors and
+// HTTP libraries rather than people behind a browser
+var botMarkers = []string{
+	"bot", "crawler", "spider", "slurp", "crawl", "mediapartners",
+	"facebookexternalhit", "embedly", "quora link preview", "whatsapp",
+	"curl/", "wget/", "python-requests", "python-urllib", "go-http-client",
+	"java/", "okhttp", "libwww-perl", "httpclient", "axios/", "node-fetch",
+	"headlesschrome", "phantomjs", "uptimerobot", "pingdom", "monitor",
+	"scanner", "nikto", "sqlmap", "nmap", "masscan", "zgrab",
+}
+
+// browserMarkers are checked in order; several browsers include the tokens
+// of the ones they derive from (Edge and Opera claim to be Chrome, Chrome
+// claims to be Safari), so the more specific ones come first
+var browserMarkers = []struct{ token, name string }{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"yabrowser/", "Yandex"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"chromium/", "Chromium"},
+	{"safari/", "Safari"},
+	{"msie ", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+}
+
+var osMarkers = []struct{ token, name string }{
+	{"windows phone", "Windows Phone"},
+	{"windows", "Windows"},
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"android", "Android"},
+	{"cros", "ChromeOS"},
+	{"linux", "Linux"},
+	{"freebsd", "FreeBSD"},
+}
+
+// ParseUserAgent classifies a User-Agent string into browser, OS, device
+// type and whether it belongs to a bot
+func ParseUserAgent(ua string) *UserAgentInfo {
+	if ua == "" || ua == "-" {
+		return nil
+	}
+
+	lower := strings.ToLower(ua)
+	info := &UserAgentInfo{Browser: "Other", OS: "Other"}
+
+	for _, m := range botMarkers {
+		if strings.Contains(lower, m) {
+			info.Bot = true
+			break
+		}
+	}
+
+	for _, m := range browserMarkers {
+		if strings.Contains(lower, m.token) {
+			info.Browser = m.name
+			break
+		}
+	}
+
+	for _, m := range osMarkers {
+		if strings.Contains(lower, m.token) {
+			info.OS = m.name
+			break
+		}
+	}
+
+	switch {
+	case info.Bot:
+		info.Device = "Bot"
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") ||
+		(strings.Contains(lower, "android") && !strings.Contains(lower, "mobile")):
+		info.Device = "Tablet"
+	case strings.Contains(lower, "mobile") || strings.Contains(lower, "iphone") ||
+		strings.Contains(lower, "ipod") || strings.Contains(lower, "windows phone"):
+		info.Device = "Mobile"
+	default:
+		info.Device = "Desktop"
+	}
+
+	return info
+}
@@ -0,0 +1,252 @@
+// Package stats aggregates log entries into level counts, the covered time
+// range and top sources, errors, countries, devices and client hosts.
+package stats
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// Stats holds statistics about a set of entries
+type Stats struct {
+	TotalLines   int
+	FatalCount   int
+	ErrorCount   int
+	WarnCount    int
+	InfoCount    int
+	DebugCount   int
+	TraceCount   int
+	Earliest     time.Time
+	Latest       time.Time
+	TopSources   map[string]int
+	Sources      map[string]*SourceStats
+	TopErrors    map[string]int
+	TopCountries map[string]int
+	Devices      map[string]int
+	Browsers     map[string]int
+	TopHosts     map[string]int
+	// TopPaths (without query strings), TopClients and StatusCodes count
+	// access log requests, which don't feed TopErrors
+	TopPaths    map[string]int
+	TopClients  map[string]int
+	StatusCodes map[int]int
+	// TopReferrers is keyed by the referring host
+	TopReferrers map[string]int
+	// Hourly and Daily are keyed by HourFormat and DayFormat
+	Hourly map[string]*PeriodStats
+	Daily  map[string]*PeriodStats
+}
+
+// SourceStats summarizes the entries of one source
+type SourceStats struct {
+	Entries int
+	// Errors counts ERROR and FATAL entries
+	Errors int
+	First  time.Time
+	Last   time.Time
+}
+
+// ErrorRate returns the percentage of the source's entries that are errors
+func (s *SourceStats) ErrorRate() float64 {
+	if s.Entries == 0 {
+		return 0
+	}
+	return float64(s.Errors) * 100 / float64(s.Entries)
+}
+
+// Layouts of the Hourly and Daily keys, which sort in time order
+const (
+	HourFormat = "2006-01-02 15:00"
+	DayFormat  = "2006-01-02"
+)
+
+// PeriodStats counts the entries of an hour or a day by level
+type PeriodStats struct {
+	Total  int
+	Levels map[string]int
+}
+
+func addPeriod(periods map[string]*PeriodStats, key, level string) {
+	p := periods[key]
+	if p == nil {
+		p = &PeriodStats{Levels: make(map[string]int)}
+		periods[key] = p
+	}
+	p.Total++
+	p.Levels[level]++
+}
+
+// TimeRange formats the span of the entry timestamps, or "" when none had one
+func (s *Stats) TimeRange() string {
+	if s.Earliest.IsZero() || s.Latest.IsZero() {
+		return ""
+	}
+	return s.Earliest.Format("2006-01-02 15:04:05") + " to " + s.Latest.Format("2006-01-02 15:04:05")
+}
+
+// Aggregator accumulates Stats one entry at a time, so entries don't need
+// to be kept in memory
+type Aggregator struct {
+	stats Stats
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{stats: Stats{
+		TopSources:   make(map[string]int),
+		Sources:      make(map[string]*SourceStats),
+		Hourly:       make(map[string]*PeriodStats),
+		Daily:        make(map[string]*PeriodStats),
+		TopErrors:    make(map[string]int),
+		TopCountries: make(map[string]int),
+		Devices:      make(map[string]int),
+		Browsers:     make(map[string]int),
+		TopHosts:     make(map[string]int),
+		TopPaths:     make(map[string]int),
+		TopClients:   make(map[string]int),
+		StatusCodes:  make(map[int]int),
+		TopReferrers: make(map[string]int),
+	}}
+}
+
+// Add counts an entry. Error messages are grouped after NormalizeMessage.
+func (a *Aggregator) Add(entry parser.Entry) {
+	s := &a.stats
+	s.TotalLines++
+
+	switch entry.Level {
+	case "FATAL":
+		s.FatalCount++
+	case "ERROR":
+		s.ErrorCount++
+	case "WARN":
+		s.WarnCount++
+	case "INFO":
+		s.InfoCount++
+	case "DEBUG":
+		s.DebugCount++
+	case "TRACE":
+		s.TraceCount++
+	}
+
+	if a := entry.Access; a != nil {
+		path, _, _ := strings.Cut(a.Path, "?")
+		s.TopPaths[path]++
+		s.TopClients[a.ClientIP]++
+		s.StatusCodes[a.Status]++
+		if a.Referrer != "" {
+			s.TopReferrers[referrerHost(a.Referrer)]++
+		}
+	} else if parser.IsError(entry.Level) {
+		s.TopErrors[NormalizeMessage(entry.Message)]++
+	}
+
+	if entry.Source != "" {
+		s.TopSources[entry.Source]++
+		src := s.Sources[entry.Source]
+		if src == nil {
+			src = &SourceStats{}
+			s.Sources[entry.Source] = src
+		}
+		src.Entries++
+		if parser.IsError(entry.Level) {
+			src.Errors++
+		}
+		if !entry.Timestamp.IsZero() {
+			if src.First.IsZero() || entry.Timestamp.Before(src.First) {
+				src.First = entry.Timestamp
+			}
+			if entry.Timestamp.After(src.Last) {
+				src.Last = entry.Timestamp
+			}
+		}
+	}
+
+	if entry.Access != nil && entry.Access.Geo != nil && entry.Access.Geo.Country != "" {
+		s.TopCountries[entry.Access.Geo.Country]++
+	}
+
+	if entry.Access != nil && entry.Access.Hostname != "" {
+		s.TopHosts[entry.Access.Hostname]++
+	}
+
+	if entry.Access != nil && entry.Access.Agent != nil {
+		s.Devices[entry.Access.Agent.Device]++
+		s.Browsers[entry.Access.Agent.Browser]++
+	}
+
+	if !entry.Timestamp.IsZero() {
+		addPeriod(s.Hourly, entry.Timestamp.Format(HourFormat), entry.Level)
+		addPeriod(s.Daily, entry.Timestamp.Format(DayFormat), entry.Level)
+		if s.Earliest.IsZero() || entry.Timestamp.Before(s.Earliest) {
+			s.Earliest = entry.Timestamp
+		}
+		if s.Latest.IsZero() || entry.Timestamp.After(s.Latest) {
+			s.Latest = entry.Timestamp
+		}
+	}
+}
+
+// referrerHost returns the host of a referrer URL, or the referrer itself
+// when it has none
+func referrerHost(referrer string) string {
+	if u, err := url.Parse(referrer); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return referrer
+}
+
+// Stats returns the statistics of the entries added so far
+func (a *Aggregator) Stats() Stats {
+	return a.stats
+}
+
+// messageNormalizers replace variable values with placeholders, in order:
+// the more specific patterns must run before plain numbers eat their digits
+var messageNormalizers = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+	// match, when set, keeps the matches it rejects
+	match func(string) bool
+}{
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "{UUID}", nil},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "{IP}", nil},
+	// Compressed addresses need a group on each side of the ::, so Ruby and
+	// C++ scopes like ActiveRecord::RecordNotFound and std::bad_alloc stay
+	{regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b|\b[0-9a-f]{1,4}(?::[0-9a-f]{1,4})*::[0-9a-f]{1,4}(?::[0-9a-f]{1,4})*\b`), "{IP}", nil},
+	{regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]{6,}\b`), "{HEX}", isHexID},
+	// Numbers are digit runs not preceded by a letter: whole tokens, values
+	// after = or : and numbers with units (250ms), but not the digits of
+	// words like e2e or db2
+	{regexp.MustCompile(`(^|[^\pL\d])\d+`), "${1}{N}", nil},
+}
+
+// isHexID reports whether a hex word is an ID rather than a short word like
+// e2e or db2: 0x-prefixed, or mixing letters and digits
+func isHexID(s string) bool {
+	if len(s) > 2 && (s[1] == 'x' || s[1] == 'X') {
+		return true
+	}
+	return strings.ContainsAny(s, "0123456789") && strings.ContainsAny(s, "abcdefABCDEF")
+}
+
+// NormalizeMessage strips UUIDs, IPs, hex IDs and numbers from a message so
+// messages differing only in those values compare equal
+func NormalizeMessage(message string) string {
+	for _, n := range messageNormalizers {
+		if n.match == nil {
+			message = n.pattern.ReplaceAllString(message, n.placeholder)
+			continue
+		}
+		message = n.pattern.ReplaceAllStringFunc(message, func(s string) string {
+			if !n.match(s) {
+				return s
+			}
+			return n.placeholder
+		})
+	}
+	return message
+}
@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"user 42 logged in", "user {N} logged in"},
+		{"request 3f2504e0-4f89-11d3-9a0c-0305e82c3301 failed", "request {UUID} failed"},
+		{"connection from 10.0.0.12:5432 refused", "connection from {IP} refused"},
+		{"connection from 2001:db8:0:0:0:0:2:1 refused", "connection from {IP} refused"},
+		{"connection from fe80::1ff:fe23:4567:890a refused", "connection from {IP} refused"},
+		{"bound to 2001:db8::1", "bound to {IP}"},
+		{"ActiveRecord::RecordNotFound raised", "ActiveRecord::RecordNotFound raised"},
+		{"terminate called after std::bad_alloc", "terminate called after std::bad_alloc"},
+		{"Foo::Bar::Baz missing", "Foo::Bar::Baz missing"},
+		{"commit 9fceb02d0ae598e95dc970b74767f19372d61af8 pushed", "commit {HEX} pushed"},
+		{"object at 0xdeadbeef freed", "object at {HEX} freed"},
+		{"trace a1b2c3 done", "trace {HEX} done"},
+		{"deadbeef is not an ID", "deadbeef is not an ID"},
+		{"e2e suite failed", "e2e suite failed"},
+		{"db2 timeout", "db2 timeout"},
+		{"took 250ms, retry=3", "took {N}ms, retry={N}"},
+		{"worker-12 exited with 1", "worker-{N} exited with {N}"},
+		{"42 jobs queued", "{N} jobs queued"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeMessage(tt.message); got != tt.want {
+			t.Errorf("NormalizeMessage(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2024, 3, 1, hour, min, 0, 0, time.UTC) }
+	a := NewAggregator()
+	for _, entry := range []parser.Entry{
+		{Timestamp: at(9, 30), Level: "INFO", Source: "api", Message: "started"},
+		{Timestamp: at(10, 5), Level: "ERROR", Source: "api", Message: "user 17 not found"},
+		{Timestamp: at(10, 45), Level: "ERROR", Source: "api", Message: "user 23 not found"},
+		{Level: "WARN", Source: "db", Message: "slow query"},
+		{Timestamp: at(11, 0), Level: "INFO", Source: "1.2.3.4", Access: &parser.AccessInfo{
+			ClientIP: "1.2.3.4", Path: "/search?q=x", Status: 200, Referrer: "https://example.com/page",
+		}},
+	} {
+		a.Add(entry)
+	}
+	s := a.Stats()
+
+	if s.TotalLines != 5 || s.InfoCount != 2 || s.ErrorCount != 2 || s.WarnCount != 1 {
+		t.Errorf("counts = %d total, %d info, %d error, %d warn", s.TotalLines, s.InfoCount, s.ErrorCount, s.WarnCount)
+	}
+	if got := s.TopErrors["user {N} not found"]; got != 2 {
+		t.Errorf("TopErrors = %v, want the two errors grouped", s.TopErrors)
+	}
+	api := s.Sources["api"]
+	if api == nil {
+		t.Fatal("no Sources[api]")
+	}
+	if api.Entries != 3 || api.Errors != 2 || !api.First.Equal(at(9, 30)) || !api.Last.Equal(at(10, 45)) {
+		t.Errorf("Sources[api] = %+v", api)
+	}
+	if rate := api.ErrorRate(); rate < 66 || rate > 67 {
+		t.Errorf("ErrorRate = %v, want 66.7", rate)
+	}
+	if p := s.Hourly["2024-03-01 10:00"]; p == nil || p.Total != 2 || p.Levels["ERROR"] != 2 {
+		t.Errorf("Hourly[10:00] = %+v", p)
+	}
+	if p := s.Daily["2024-03-01"]; p == nil || p.Total != 4 {
+		t.Errorf("Daily = %+v, want the 4 timestamped entries", p)
+	}
+	if s.TopPaths["/search"] != 1 || s.StatusCodes[200] != 1 || s.TopReferrers["example.com"] != 1 {
+		t.Errorf("access stats = %v %v %v", s.TopPaths, s.StatusCodes, s.TopReferrers)
+	}
+	if got, want := s.TimeRange(), "2024-03-01 09:30:00 to 2024-03-01 11:00:00"; got != want {
+		t.Errorf("TimeRange = %q, want %q", got, want)
+	}
+}
+
+func TestTimeRangeUntimed(t *testing.T) {
+	a := NewAggregator()
+	a.Add(parser.Entry{Message: "no timestamp"})
+	if s := a.Stats(); s.TimeRange() != "" {
+		t.Errorf("TimeRange = %q, want empty", s.TimeRange())
+	}
+}
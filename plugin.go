@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmPlugin is a WebAssembly module loaded with -plugin. Data crosses the
+// boundary as bytes in the module's memory:
+//
+//	alloc(size i32) i32              memory for the host to write input into
+//	free(ptr i32, size i32)          optional, releases alloc'd or result memory
+//	detect(ptr i32, len i32) i32     non-zero when the line is in the format
+//	parse(ptr i32, len i32) i64      line -> entry JSON
+//	transform(ptr i32, len i32) i64  entry JSON -> entry JSON
+//
+// parse and transform return the result as ptr<<32 | len; a zero length
+// means the line isn't handled (parse) or the entry is dropped (transform).
+// Entry JSON has the fields of the ndjson output.
+type wasmPlugin struct {
+	name string
+	// Module instances aren't safe for concurrent calls
+	mu  sync.Mutex
+	mod api.Module
+
+	alloc, free, detect, parse, transform api.Function
+}
+
+// loadPlugin compiles and instantiates a module. WASI is provided so
+// modules built by TinyGo, Rust or AssemblyScript for wasi run as is;
+// their output goes to stderr.
+func loadPlugin(ctx context.Context, rt wazero.Runtime, path string) (*wasmPlugin, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := wazero.NewModuleConfig().
+		WithName(path).
+		WithStdout(os.Stderr).
+		WithStderr(os.Stderr).
+		WithStartFunctions("_initialize")
+	mod, err := rt.InstantiateWithConfig(ctx, code, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	p := &wasmPlugin{
+		name:      strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		mod:       mod,
+		alloc:     mod.ExportedFunction("alloc"),
+		free:      mod.ExportedFunction("free"),
+		detect:    mod.ExportedFunction("detect"),
+		parse:     mod.ExportedFunction("parse"),
+		transform: mod.ExportedFunction("transform"),
+	}
+	if p.alloc == nil {
+		return nil, fmt.Errorf("%s: module does not export alloc", path)
+	}
+	if p.parse == nil && p.transform == nil {
+		return nil, fmt.Errorf("%s: module exports neither parse nor transform", path)
+	}
+	return p, nil
+}
+
+// invoke copies input into the module's memory and calls fn(ptr, len)
+func (p *wasmPlugin) invoke(ctx context.Context, fn api.Function, input []byte) ([]uint64, error) {
+	res, err := p.alloc.Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, err
+	}
+	ptr := uint32(res[0])
+	if !p.mod.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("%s: alloc returned memory out of range", p.name)
+	}
+
+	res, err = fn.Call(ctx, uint64(ptr), uint64(len(input)))
+	if p.free != nil {
+		p.free.Call(ctx, uint64(ptr), uint64(len(input)))
+	}
+	return res, err
+}
+
+func (p *wasmPlugin) String() string {
+	return "Plugin " + p.name
+}
+
+// call passes input to fn and returns the bytes its packed ptr<<32|len
+// result points at, or nil for a zero-length result
+func (p *wasmPlugin) call(fn api.Function, input []byte) ([]byte, error) {
+	ctx := context.Background()
+	res, err := p.invoke(ctx, fn, input)
+	if err != nil {
+		return nil, err
+	}
+
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	if outLen == 0 {
+		return nil, nil
+	}
+	out, ok := p.mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("%s: result out of range", p.name)
+	}
+	// Copy before the module reuses the memory
+	out = append([]byte(nil), out...)
+	if p.free != nil {
+		p.free.Call(ctx, uint64(outPtr), uint64(outLen))
+	}
+	return out, nil
+}
+
+// Detect makes plugins with a parse export usable as a registered format.
+// Without a detect export a plugin never claims lines under -format auto.
+func (p *wasmPlugin) Detect(line string) bool {
+	if p.detect == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res, err := p.invoke(context.Background(), p.detect, []byte(line))
+	return err == nil && uint32(res[0]) != 0
+}
+
+func (p *wasmPlugin) Parse(line string) (*LogEntry, error) {
+	p.mu.Lock()
+	out, err := p.call(p.parse, []byte(line))
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, fmt.Errorf("%s: line not handled", p.name)
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal(out, &entry); err != nil {
+		return nil, fmt.Errorf("%s: invalid entry: %v", p.name, err)
+	}
+	if entry.Raw == "" {
+		entry.Raw = line
+	}
+	return &entry, nil
+}
+
+// Transform rewrites an entry, returning nil when the plugin drops it
+func (p *wasmPlugin) Transform(entry *LogEntry) (*LogEntry, error) {
+	in, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	out, err := p.call(p.transform, in)
+	p.mu.Unlock()
+	if err != nil || out == nil {
+		return nil, err
+	}
+
+	var transformed LogEntry
+	if err := json.Unmarshal(out, &transformed); err != nil {
+		return nil, fmt.Errorf("%s: invalid entry: %v", p.name, err)
+	}
+	return &transformed, nil
+}
+
+// loadPlugins instantiates the -plugin modules. Those with a parse export
+// are registered as a format named after the file (-format myformat for
+// myformat.wasm); those with a transform export are returned to run on
+// every parsed entry, in the order given.
+func loadPlugins(paths []string) ([]transformer, error) {
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+
+	var transforms []transformer
+	for _, path := range paths {
+		if filepath.Ext(path) != ".wasm" {
+			continue
+		}
+		p, err := loadPlugin(ctx, rt, path)
+		if err != nil {
+			return nil, err
+		}
+		if p.parse != nil {
+			if _, taken := parser.Lookup(p.name); taken {
+				return nil, fmt.Errorf("%s: format %q already exists", path, p.name)
+			}
+			parser.Register(p.name, p)
+		}
+		if p.transform != nil {
+			transforms = append(transforms, p)
+		}
+	}
+	return transforms, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// precursorLimit is how many preceding templates are listed per error
+const precursorLimit = 5
+
+// showPrecursors reports, for each of the top error templates, which other
+// message templates most often appear within window before an occurrence
+func (la *LogAnalyzer) showPrecursors(entries []LogEntry, topErrors int, window time.Duration) {
+	sorted := make([]LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Timestamp.IsZero() {
+			sorted = append(sorted, entry)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	miner := NewTemplateMiner()
+	templates := make([]*LogTemplate, len(sorted))
+	errorCounts := make(map[*LogTemplate]int)
+	for i, entry := range sorted {
+		templates[i] = miner.Add(entry.Message)
+		if parser.IsError(entry.Level) {
+			errorCounts[templates[i]]++
+		}
+	}
+
+	var errorTemplates []*LogTemplate
+	for t := range errorCounts {
+		errorTemplates = append(errorTemplates, t)
+	}
+	sort.Slice(errorTemplates, func(i, j int) bool {
+		return errorCounts[errorTemplates[i]] > errorCounts[errorTemplates[j]]
+	})
+	if len(errorTemplates) > topErrors {
+		errorTemplates = errorTemplates[:topErrors]
+	}
+
+	fmt.Printf("=== Error Precursors (within %s) ===\n", window)
+	if len(errorTemplates) == 0 {
+		fmt.Println("No errors found")
+		return
+	}
+
+	for _, target := range errorTemplates {
+		preceded := make(map[*LogTemplate]int)
+		occurrences := 0
+
+		for i, entry := range sorted {
+			if templates[i] != target || !parser.IsError(entry.Level) {
+				continue
+			}
+			occurrences++
+
+			seen := make(map[*LogTemplate]bool)
+			from := entry.Timestamp.Add(-window)
+			for j := i - 1; j >= 0 && !sorted[j].Timestamp.Before(from); j-- {
+				if t := templates[j]; t != target && !seen[t] {
+					seen[t] = true
+					preceded[t]++
+				}
+			}
+		}
+
+		fmt.Printf("\n%s (%d occurrences)\n", target, occurrences)
+
+		var candidates []*LogTemplate
+		for t := range preceded {
+			candidates = append(candidates, t)
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return preceded[candidates[i]] > preceded[candidates[j]]
+		})
+		if len(candidates) == 0 {
+			fmt.Println("  no other messages in the preceding window")
+			continue
+		}
+		for i, t := range candidates {
+			if i >= precursorLimit {
+				break
+			}
+			fmt.Printf("  %5.1f%%  %s\n", float64(preceded[t])*100/float64(occurrences), t)
+		}
+	}
+}
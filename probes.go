@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// probePathLimit is how many probed paths are listed per client
+const probePathLimit = 10
+
+// Prober is a client whose requests look like a vulnerability scan: many
+// distinct paths, mostly answered with 404 or 400
+type Prober struct {
+	ClientIP string
+	Requests int
+	Failed   int
+	// Paths counts the distinct paths that failed
+	Paths map[string]int
+}
+
+// FailRatio is the share of the client's requests that got a 400 or 404
+func (p Prober) FailRatio() float64 {
+	if p.Requests == 0 {
+		return 0
+	}
+	return float64(p.Failed) / float64(p.Requests)
+}
+
+// findProbers returns clients with at least minPaths distinct failing paths
+// and a failure ratio of at least minRatio, most paths first
+func findProbers(entries []LogEntry, minPaths int, minRatio float64) []Prober {
+	clients := make(map[string]*Prober)
+	for _, entry := range entries {
+		a := entry.Access
+		if a == nil {
+			continue
+		}
+		p, ok := clients[a.ClientIP]
+		if !ok {
+			p = &Prober{ClientIP: a.ClientIP, Paths: make(map[string]int)}
+			clients[a.ClientIP] = p
+		}
+		p.Requests++
+		if a.Status == 404 || a.Status == 400 {
+			p.Failed++
+			p.Paths[a.Path]++
+		}
+	}
+
+	var probers []Prober
+	for _, p := range clients {
+		if len(p.Paths) >= minPaths && p.FailRatio() >= minRatio {
+			probers = append(probers, *p)
+		}
+	}
+	sort.Slice(probers, func(i, j int) bool {
+		if len(probers[i].Paths) != len(probers[j].Paths) {
+			return len(probers[i].Paths) > len(probers[j].Paths)
+		}
+		return probers[i].ClientIP < probers[j].ClientIP
+	})
+	return probers
+}
+
+func (la *LogAnalyzer) showProbes(entries []LogEntry, minPaths int, minRatio float64) {
+	probers := findProbers(entries, minPaths, minRatio)
+
+	fmt.Printf("=== Scanner Probes (>= %d distinct 404/400 paths, >= %.0f%% failed) ===\n", minPaths, minRatio*100)
+	if len(probers) == 0 {
+		fmt.Println("No scanning clients found")
+		return
+	}
+
+	for _, p := range probers {
+		fmt.Printf("\n%s: %d requests, %d failed (%.1f%%), %d distinct paths\n",
+			p.ClientIP, p.Requests, p.Failed, p.FailRatio()*100, len(p.Paths))
+
+		paths := make([]string, 0, len(p.Paths))
+		for path := range p.Paths {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for i, path := range paths {
+			if i == probePathLimit {
+				fmt.Printf("  ... and %d more\n", len(paths)-probePathLimit)
+				break
+			}
+			fmt.Printf("  %s\n", path)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"sync"
+	"syscall"
+)
+
+// profiles holds the -cpuprofile and -memprofile output of the running
+// command; parseFlags starts it and main stops it when the command returns
+var profiles profiler
+
+type profiler struct {
+	once    sync.Once
+	cpu     *os.File
+	memPath string
+}
+
+// start begins CPU profiling into cpuPath, remembers memPath for the heap
+// profile written by stop, and serves net/http/pprof on listen; empty
+// values leave that profile off. While a profile file is being written an
+// interrupt stops the profiles before exiting, so following or serving
+// can be profiled too.
+func (p *profiler) start(cpuPath, memPath, listen string) error {
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return err
+		}
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		p.cpu = f
+	}
+	p.memPath = memPath
+
+	if listen != "" {
+		// A mux of its own, so the profiles aren't served by the serve
+		// command's handlers
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		go func() {
+			if err := http.ListenAndServe(listen, mux); err != nil {
+				fmt.Fprintf(os.Stderr, "pprof: %v\n", err)
+			}
+		}()
+		fmt.Fprintf(os.Stderr, "Serving profiles on http://%s/debug/pprof/\n", listen)
+	}
+
+	if p.cpu != nil || p.memPath != "" {
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-interrupts
+			p.stop()
+			os.Exit(130)
+		}()
+	}
+	return nil
+}
+
+// stop finishes the CPU profile and writes the heap profile. Commands that
+// exit with an error status end without them.
+func (p *profiler) stop() {
+	p.once.Do(func() {
+		if p.cpu != nil {
+			runtimepprof.StopCPUProfile()
+			p.cpu.Close()
+		}
+		if p.memPath == "" {
+			return
+		}
+		f, err := os.Create(p.memPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing heap profile: %v\n", err)
+			return
+		}
+		defer f.Close()
+		// Up-to-date statistics of what is still allocated
+		runtime.GC()
+		if err := runtimepprof.WriteHeapProfile(f); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing heap profile: %v\n", err)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// progressDelay keeps small files from flashing a progress line
+	progressDelay    = time.Second
+	progressInterval = 200 * time.Millisecond
+)
+
+// progress redraws a status line on stderr with the bytes read, the
+// throughput and, when the total is known, the percentage done and an ETA
+type progress struct {
+	name    string
+	total   int64
+	read    atomic.Int64
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// startProgress starts drawing for an input of total bytes (0 if unknown)
+func startProgress(name string, total int64) *progress {
+	p := &progress{
+		name:    filepath.Base(name),
+		total:   total,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if name == "-" {
+		p.name = "stdin"
+	}
+	go p.run(time.Now())
+	return p
+}
+
+func (p *progress) add(n int) {
+	p.read.Add(int64(n))
+}
+
+// stop clears the status line, so output printed afterwards starts on a
+// clean line
+func (p *progress) stop() {
+	p.once.Do(func() {
+		close(p.done)
+		<-p.stopped
+	})
+}
+
+// progressReader counts the bytes read from an input. It wraps the input
+// before decompression, so the count matches the size on disk.
+type progressReader struct {
+	io.ReadCloser
+	progress *progress
+}
+
+func newProgressReader(name string, rc io.ReadCloser) *progressReader {
+	var total int64
+	if f, ok := rc.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			total = info.Size()
+		}
+	}
+	return &progressReader{ReadCloser: rc, progress: startProgress(name, total)}
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.progress.add(n)
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	r.progress.stop()
+	return r.ReadCloser.Close()
+}
+
+func (p *progress) run(start time.Time) {
+	defer close(p.stopped)
+
+	delay := time.NewTimer(progressDelay)
+	select {
+	case <-p.done:
+		delay.Stop()
+		return
+	case <-delay.C:
+	}
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		fmt.Fprint(os.Stderr, "\r\033[K"+p.status(time.Since(start)))
+		select {
+		case <-p.done:
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *progress) status(elapsed time.Duration) string {
+	read := p.read.Load()
+	rate := float64(read) / elapsed.Seconds()
+
+	line := p.name + "  " + formatBytes(read)
+	if p.total > 0 {
+		line += fmt.Sprintf(" / %s  %3.0f%%", formatBytes(p.total), 100*float64(read)/float64(p.total))
+	}
+	line += "  " + formatBytes(int64(rate)) + "/s"
+	if p.total > 0 && rate > 0 && read < p.total {
+		eta := time.Duration(float64(p.total-read) / rate * float64(time.Second))
+		line += "  ETA " + eta.Round(time.Second).String()
+	}
+	return line
+}
+
+// formatBytes prints a size with a binary unit: 512 B, 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
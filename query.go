@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SavedQuery is a named command line stored with `query save`
+type SavedQuery struct {
+	Args        []string  `json:"args"`
+	Description string    `json:"description,omitempty"`
+	Saved       time.Time `json:"saved"`
+}
+
+// queriesPath is $LOGANALYZER_QUERIES, so a team can share a file kept in
+// a repository, or queries.json next to the config file
+func queriesPath() string {
+	if path := os.Getenv("LOGANALYZER_QUERIES"); path != "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configPath()), "queries.json")
+}
+
+// historyPath holds one JSON line per analyzer run
+func historyPath() string {
+	return filepath.Join(filepath.Dir(configPath()), "history")
+}
+
+func loadQueries() (map[string]SavedQuery, error) {
+	queries := make(map[string]SavedQuery)
+	data, err := os.ReadFile(queriesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return queries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("%s: %v", queriesPath(), err)
+	}
+	return queries, nil
+}
+
+func saveQueries(queries map[string]SavedQuery) error {
+	// Indented with sorted keys so a shared file diffs cleanly
+	data, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := queriesPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// historyEntry is one recorded run
+type historyEntry struct {
+	Time time.Time `json:"time"`
+	Args []string  `json:"args"`
+}
+
+// historyMax is how many runs the history keeps, dropping the oldest
+const historyMax = 500
+
+// secretFlags are the flags whose values aren't written to the history
+var secretFlags = map[string]bool{
+	"http-user":   true,
+	"http-token":  true,
+	"redact-key":  true,
+	"webhook-url": true,
+}
+
+// stripSecrets returns args with the values of secretFlags replaced, both
+// as -flag=value and as -flag value
+func stripSecrets(args []string) []string {
+	out := make([]string, len(args))
+	copy(out, args)
+	for i := 0; i < len(out); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(out[i], "-"), "=")
+		if !strings.HasPrefix(out[i], "-") || !secretFlags[name] {
+			continue
+		}
+		if hasValue {
+			out[i] = out[i][:strings.IndexByte(out[i], '=')+1] + "REDACTED"
+		} else if i+1 < len(out) {
+			i++
+			out[i] = "REDACTED"
+		}
+	}
+	return out
+}
+
+// recordHistory appends an analyzer run to the history file when
+// $LOGANALYZER_HISTORY is set, without the values of secretFlags and
+// keeping the last historyMax runs. Failures are ignored: history must
+// never stop an analysis.
+func recordHistory(args []string) {
+	if len(args) == 0 || os.Getenv("LOGANALYZER_HISTORY") == "" {
+		return
+	}
+	history, err := loadHistory()
+	if err != nil {
+		return
+	}
+	history = append(history, historyEntry{Time: time.Now(), Args: stripSecrets(args)})
+	if len(history) > historyMax {
+		history = history[len(history)-historyMax:]
+	}
+
+	path := historyPath()
+	if os.MkdirAll(filepath.Dir(path), 0o755) != nil {
+		return
+	}
+	// Rewritten through a temporary file so a crash can't truncate it
+	f, err := os.CreateTemp(filepath.Dir(path), "history-*")
+	if err != nil {
+		return
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range history {
+		enc.Encode(e)
+	}
+	if f.Close() != nil || os.Chmod(f.Name(), 0o600) != nil || os.Rename(f.Name(), path) != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func loadHistory() ([]historyEntry, error) {
+	f, err := os.Open(historyPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := new(longLines).newScanner(f)
+	for scanner.Scan() {
+		var e historyEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// commandLine formats arguments for display, quoting where a shell would
+// need it
+func commandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$\\|&;<>()*?[]{}`!#~") {
+			arg = shellQuote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// runQuery refers to subcommands, so it is added here rather than in the
+// map's initializer
+func init() {
+	subcommands["query"] = runQuery
+}
+
+func queryUsage() {
+	fmt.Println("Usage: loganalyzer query save [-d <description>] [-last] <name> [options...]")
+	fmt.Println("       loganalyzer query run <name> [-f <logfile>...] [options...]")
+	fmt.Println("       loganalyzer query list")
+	fmt.Println("       loganalyzer query delete <name>")
+	fmt.Println("       loganalyzer query history [-n 20]")
+	fmt.Println()
+	fmt.Println("Analyzer runs are recorded for history and save -last when $LOGANALYZER_HISTORY is set,")
+	fmt.Println("without the values of -http-user, -http-token, -redact-key and -webhook-url.")
+	os.Exit(1)
+}
+
+// runQuery manages saved queries: command lines stored by name and run
+// again with extra arguments, typically the files to read
+func runQuery(args []string) {
+	if len(args) == 0 {
+		queryUsage()
+	}
+
+	switch args[0] {
+	case "save":
+		querySave(args[1:])
+	case "run":
+		queryRun(args[1:])
+	case "list":
+		queryList()
+	case "delete":
+		if len(args) != 2 {
+			queryUsage()
+		}
+		queries, err := loadQueries()
+		if err != nil {
+			log.Fatalf("Error reading queries: %v", err)
+		}
+		if _, ok := queries[args[1]]; !ok {
+			log.Fatalf("No saved query %q", args[1])
+		}
+		delete(queries, args[1])
+		if err := saveQueries(queries); err != nil {
+			log.Fatalf("Error saving queries: %v", err)
+		}
+	case "history":
+		fs := flag.NewFlagSet("query history", flag.ExitOnError)
+		n := fs.Int("n", 20, "Number of recent runs to show")
+		fs.Parse(args[1:])
+		history, err := loadHistory()
+		if err != nil {
+			log.Fatalf("Error reading history: %v", err)
+		}
+		if len(history) > *n {
+			history = history[len(history)-*n:]
+		}
+		for _, e := range history {
+			fmt.Printf("%s  %s\n", e.Time.Format("2006-01-02 15:04:05"), commandLine(e.Args))
+		}
+	default:
+		queryUsage()
+	}
+}
+
+func querySave(args []string) {
+	fs := flag.NewFlagSet("query save", flag.ExitOnError)
+	description := fs.String("d", "", "Description shown by query list")
+	last := fs.Bool("last", false, "Save the most recent analyzer run from the history instead of the given options")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		queryUsage()
+	}
+	name, queryArgs := fs.Arg(0), fs.Args()[1:]
+
+	if *last {
+		history, err := loadHistory()
+		if err != nil {
+			log.Fatalf("Error reading history: %v", err)
+		}
+		if len(history) == 0 {
+			log.Fatal("No runs in the history (analyzer runs are recorded when $LOGANALYZER_HISTORY is set)")
+		}
+		queryArgs = history[len(history)-1].Args
+	}
+	if len(queryArgs) == 0 {
+		log.Fatal("Nothing to save: give the options of the query or -last")
+	}
+	if _, sub := subcommands[name]; sub || strings.HasPrefix(name, "-") {
+		log.Fatalf("Invalid query name %q", name)
+	}
+
+	queries, err := loadQueries()
+	if err != nil {
+		log.Fatalf("Error reading queries: %v", err)
+	}
+	queries[name] = SavedQuery{Args: queryArgs, Description: *description, Saved: time.Now()}
+	if err := saveQueries(queries); err != nil {
+		log.Fatalf("Error saving queries: %v", err)
+	}
+	fmt.Printf("Saved %s: %s\n", name, commandLine(queryArgs))
+}
+
+// queryRun runs the analyzer again with the saved arguments followed by
+// the extra ones, which take precedence for single-valued flags
+func queryRun(args []string) {
+	if len(args) == 0 {
+		queryUsage()
+	}
+	queries, err := loadQueries()
+	if err != nil {
+		log.Fatalf("Error reading queries: %v", err)
+	}
+	q, ok := queries[args[0]]
+	if !ok {
+		log.Fatalf("No saved query %q (see query list)", args[0])
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Error running query: %v", err)
+	}
+	cmd := exec.Command(self, append(append([]string(nil), q.Args...), args[1:]...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		log.Fatalf("Error running query: %v", err)
+	}
+}
+
+func queryList() {
+	queries, err := loadQueries()
+	if err != nil {
+		log.Fatalf("Error reading queries: %v", err)
+	}
+	names := make([]string, 0, len(queries))
+	for name := range queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		q := queries[name]
+		fmt.Printf("%s\n    %s\n", name, commandLine(q.Args))
+		if q.Description != "" {
+			fmt.Printf("    %s\n", q.Description)
+		}
+	}
+}
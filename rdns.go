@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSResolver does cached reverse DNS lookups with a bounded number of
+// lookups in flight, caching the most recent lookupCacheSize addresses
+type DNSResolver struct {
+	timeout time.Duration
+	workers int
+
+	mu    sync.Mutex
+	cache *lruCache[string, string]
+}
+
+func NewDNSResolver(workers int, timeout time.Duration) *DNSResolver {
+	if workers < 1 {
+		workers = 1
+	}
+	return &DNSResolver{
+		timeout: timeout,
+		workers: workers,
+		cache:   newLRUCache[string, string](lookupCacheSize),
+	}
+}
+
+// Resolve returns the first PTR name for an IP, or "" when there is none.
+// Failures are cached too so an unresolvable address is only tried once.
+func (r *DNSResolver) Resolve(ip string) string {
+	r.mu.Lock()
+	host, ok := r.cache.Get(ip)
+	r.mu.Unlock()
+	if ok {
+		return host
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	if names, err := net.DefaultResolver.LookupAddr(ctx, ip); err == nil && len(names) > 0 {
+		host = strings.TrimSuffix(names[0], ".")
+	}
+
+	r.mu.Lock()
+	r.cache.Put(ip, host)
+	r.mu.Unlock()
+	return host
+}
+
+// ResolveAll looks up every address concurrently, at most workers at a time
+func (r *DNSResolver) ResolveAll(ips []string) {
+	sem := make(chan struct{}, r.workers)
+	var wg sync.WaitGroup
+
+	for _, ip := range ips {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ip string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.Resolve(ip)
+		}(ip)
+	}
+
+	wg.Wait()
+}
+
+// resolveHostnames annotates access log entries with the reverse DNS name of
+// their client IP, resolving the distinct addresses in parallel first
+func (la *LogAnalyzer) resolveHostnames(entries []LogEntry) {
+	if la.resolver == nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var ips []string
+	for _, entry := range entries {
+		if entry.Access != nil && !seen[entry.Access.ClientIP] {
+			seen[entry.Access.ClientIP] = true
+			ips = append(ips, entry.Access.ClientIP)
+		}
+	}
+
+	la.resolver.ResolveAll(ips)
+
+	for _, entry := range entries {
+		if entry.Access != nil {
+			entry.Access.Hostname = la.resolver.Resolve(entry.Access.ClientIP)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// redactionRule finds one kind of sensitive value
+type redactionRule struct {
+	name    string
+	pattern *regexp.Regexp
+	// valid, when set, rejects matches that only look sensitive
+	valid func(string) bool
+}
+
+// redactionRules maps each -redact kind to its matcher
+var redactionRules = map[string]redactionRule{
+	"email": {
+		name:    "email",
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	"creditcard": {
+		name:    "creditcard",
+		pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid:   luhnValid,
+	},
+	"phone": {
+		name:    "phone",
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]\d{3}[\s.-]\d{4}\b|\+\d{8,15}\b`),
+	},
+	"ip": {
+		name:    "ip",
+		pattern: regexp.MustCompile(`(?i)\b\d{1,3}(?:\.\d{1,3}){3}\b|\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b|\b(?:[0-9a-f]{1,4}:)+:(?:[0-9a-f]{1,4}(?::[0-9a-f]{1,4})*)?`),
+	},
+}
+
+// redactionOrder is the order rules are applied in; credit cards run before
+// phone numbers so long digit runs aren't half-claimed as phones
+var redactionOrder = []string{"email", "creditcard", "phone", "ip"}
+
+// Redactor masks or pseudonymizes sensitive values. In hash mode the same
+// input always maps to the same token, so entries can still be correlated.
+type Redactor struct {
+	rules []redactionRule
+	hash  bool
+	key   []byte
+}
+
+// NewRedactor builds a redactor for a comma-separated list of kinds
+// (email, ip, phone, creditcard, or all)
+func NewRedactor(kinds, mode, key string) (*Redactor, error) {
+	wanted := make(map[string]bool)
+	for _, kind := range strings.Split(kinds, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "all" {
+			for _, name := range redactionOrder {
+				wanted[name] = true
+			}
+			continue
+		}
+		if _, ok := redactionRules[kind]; !ok {
+			return nil, fmt.Errorf("unknown redaction type %q (email, ip, phone, creditcard, all)", kind)
+		}
+		wanted[kind] = true
+	}
+
+	r := &Redactor{key: []byte(key)}
+	switch mode {
+	case "mask":
+	case "hash":
+		r.hash = true
+	default:
+		return nil, fmt.Errorf("unknown redaction mode %q (mask, hash)", mode)
+	}
+
+	for _, name := range redactionOrder {
+		if wanted[name] {
+			r.rules = append(r.rules, redactionRules[name])
+		}
+	}
+	return r, nil
+}
+
+// Redact replaces every sensitive value in s with a token
+func (r *Redactor) Redact(s string) string {
+	for _, rule := range r.rules {
+		rule := rule
+		s = rule.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.valid != nil && !rule.valid(match) {
+				return match
+			}
+			return r.token(rule.name, match)
+		})
+	}
+	return s
+}
+
+// token renders the replacement, e.g. [EMAIL] or [EMAIL:3f2a9c01d4]
+func (r *Redactor) token(kind, value string) string {
+	label := strings.ToUpper(kind)
+	if !r.hash {
+		return "[" + label + "]"
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return "[" + label + ":" + hex.EncodeToString(mac.Sum(nil))[:10] + "]"
+}
+
+// RedactEntry returns a copy of the entry with sensitive values replaced
+// in the message, raw line, source, fields and access log fields
+func (r *Redactor) RedactEntry(entry LogEntry) LogEntry {
+	entry.Message = r.Redact(entry.Message)
+	entry.Raw = r.Redact(entry.Raw)
+	entry.Source = r.Redact(entry.Source)
+
+	if len(entry.Fields) > 0 {
+		// A copy, as entries share their maps with the caller
+		fields := make(map[string]interface{}, len(entry.Fields))
+		for name, value := range entry.Fields {
+			// Numbers and arrays keep their type unless something in them
+			// is redacted
+			fields[name] = value
+			if s := parser.FieldString(value); r.Redact(s) != s {
+				fields[name] = r.Redact(s)
+			}
+		}
+		entry.Fields = fields
+	}
+
+	if entry.Access != nil {
+		access := *entry.Access
+		access.ClientIP = r.Redact(access.ClientIP)
+		access.Path = r.Redact(access.Path)
+		access.Hostname = r.Redact(access.Hostname)
+		// Referrer query strings carry tokens and emails
+		access.Referrer = r.Redact(access.Referrer)
+		access.UserAgent = r.Redact(access.UserAgent)
+		entry.Access = &access
+	}
+	return entry
+}
+
+// luhnValid checks the credit card checksum over the digits of s
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		kinds string
+		input string
+		want  string
+	}{
+		{"email", "login by jane.doe+x@example.co.uk failed", "login by [EMAIL] failed"},
+		{"creditcard", "card 4111 1111 1111 1111 declined", "card [CREDITCARD] declined"},
+		{"creditcard", "card 4111-1111-1111-1111 declined", "card [CREDITCARD] declined"},
+		// Fails the Luhn check, so it's an order number and not a card
+		{"creditcard", "order 4111111111111112 shipped", "order 4111111111111112 shipped"},
+		{"phone", "call +1 555-123-4567 or (555) 987-6543", "call [PHONE] or [PHONE]"},
+		{"ip", "from 10.0.0.12 and 2001:db8::1", "from [IP] and [IP]"},
+		{"ip", "user jane@example.com", "user jane@example.com"},
+		{"all", "jane@example.com paid with 4111111111111111 from 10.0.0.1", "[EMAIL] paid with [CREDITCARD] from [IP]"},
+		{"email, IP", "jane@example.com at 10.0.0.1", "[EMAIL] at [IP]"},
+	}
+	for _, tt := range tests {
+		r, err := NewRedactor(tt.kinds, "mask", "")
+		if err != nil {
+			t.Fatalf("NewRedactor(%q): %v", tt.kinds, err)
+		}
+		if got := r.Redact(tt.input); got != tt.want {
+			t.Errorf("%s: Redact(%q) = %q, want %q", tt.kinds, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRedactHash(t *testing.T) {
+	r, err := NewRedactor("email", "hash", "key one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewRedactor("email", "hash", "key two")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := r.Redact("jane@example.com")
+	if !regexp.MustCompile(`^\[EMAIL:[0-9a-f]{10}\]$`).MatchString(a) {
+		t.Fatalf("token = %q, want [EMAIL:<10 hex digits>]", a)
+	}
+	if b := r.Redact("jane@example.com"); b != a {
+		t.Errorf("the same value hashed to %q and %q", a, b)
+	}
+	if b := r.Redact("john@example.com"); b == a {
+		t.Errorf("different values share the token %q", a)
+	}
+	if b := other.Redact("jane@example.com"); b == a {
+		t.Errorf("different keys share the token %q", a)
+	}
+}
+
+func TestNewRedactorErrors(t *testing.T) {
+	if _, err := NewRedactor("email,ssn", "mask", ""); err == nil {
+		t.Error("unknown kind accepted")
+	}
+	if _, err := NewRedactor("email", "blur", ""); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestRedactEntry(t *testing.T) {
+	r, err := NewRedactor("all", "mask", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]interface{}{"user": "jane@example.com", "card": 4111111111111111.0, "status": 500.0}
+	entry := r.RedactEntry(LogEntry{
+		Message: "login jane@example.com",
+		Source:  "10.0.0.1",
+		Fields:  fields,
+		Access:  &AccessInfo{ClientIP: "10.0.0.1", Referrer: "https://x.test/?u=jane@example.com"},
+	})
+	if entry.Message != "login [EMAIL]" || entry.Source != "[IP]" {
+		t.Errorf("Message, Source = %q, %q", entry.Message, entry.Source)
+	}
+	if entry.Fields["user"] != "[EMAIL]" || entry.Fields["card"] != "[CREDITCARD]" || entry.Fields["status"] != 500.0 {
+		t.Errorf("Fields = %v", entry.Fields)
+	}
+	if entry.Access.ClientIP != "[IP]" || entry.Access.Referrer != "https://x.test/?u=[EMAIL]" {
+		t.Errorf("Access = %+v", entry.Access)
+	}
+	if fields["user"] != "jane@example.com" {
+		t.Error("RedactEntry changed the caller's fields")
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"5500-0000-0000-0004", true},
+		{"378282246310005", true},
+		{"4111111111111112", false},
+		{"0000", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := luhnValid(tt.number); got != tt.want {
+			t.Errorf("luhnValid(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
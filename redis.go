@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// redisConn is a minimal RESP client, enough to subscribe to channels and
+// read streams without pulling in a driver
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialRedis(addr, password string, db int) (*redisConn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	if password != "" {
+		if _, err := c.do("AUTH", password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if db != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) send(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(c.conn, b.String())
+	return err
+}
+
+// do sends a command and reads its reply
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// read parses one RESP value: strings, integers, nil, or nested arrays
+// ([]interface{}). Error replies are returned as errors.
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// subscribeRedis sends every message published on the channels (patterns
+// allowed) to out until the connection fails
+func subscribeRedis(c *redisConn, channels []string, out chan<- string) error {
+	if err := c.send(append([]string{"PSUBSCRIBE"}, channels...)...); err != nil {
+		return err
+	}
+	for {
+		reply, err := c.read()
+		if err != nil {
+			return err
+		}
+		// ["pmessage", pattern, channel, payload]
+		if msg, ok := reply.([]interface{}); ok && len(msg) == 4 && msg[0] == "pmessage" {
+			if payload, ok := msg[3].(string); ok {
+				out <- payload
+			}
+		}
+	}
+}
+
+// readRedisStream follows a stream from new entries onwards. The log line is
+// taken from field; entries without it are passed on as a JSON object of
+// all their fields so the JSON parser can handle them.
+func readRedisStream(c *redisConn, stream, field string, out chan<- string) error {
+	lastID := "$"
+	for {
+		reply, err := c.do("XREAD", "BLOCK", "0", "COUNT", "100", "STREAMS", stream, lastID)
+		if err != nil {
+			return err
+		}
+		// [[stream, [[id, [field, value, ...]], ...]]]
+		streams, _ := reply.([]interface{})
+		for _, s := range streams {
+			parts, _ := s.([]interface{})
+			if len(parts) != 2 {
+				continue
+			}
+			records, _ := parts[1].([]interface{})
+			for _, r := range records {
+				record, _ := r.([]interface{})
+				if len(record) != 2 {
+					continue
+				}
+				lastID, _ = record[0].(string)
+				kv, _ := record[1].([]interface{})
+				fields := make(map[string]string)
+				for i := 0; i+1 < len(kv); i += 2 {
+					k, _ := kv[i].(string)
+					v, _ := kv[i+1].(string)
+					fields[k] = v
+				}
+				if line, ok := fields[field]; ok {
+					out <- line
+				} else if data, err := json.Marshal(fields); err == nil {
+					out <- string(data)
+				}
+			}
+		}
+	}
+}
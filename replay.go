@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// syslogSeverity maps analyzer levels onto syslog severities
+var syslogSeverity = map[string]int{
+	"FATAL": 2,
+	"ERROR": 3,
+	"WARN":  4,
+	"INFO":  6,
+	"DEBUG": 7,
+	"TRACE": 7,
+}
+
+// replaySink receives replayed lines
+type replaySink interface {
+	Send(entry LogEntry) error
+	Close() error
+}
+
+type lineSink struct {
+	w      *bufio.Writer
+	closer io.Closer
+}
+
+func (s *lineSink) Send(entry LogEntry) error {
+	if _, err := s.w.WriteString(entry.Raw + "\n"); err != nil {
+		return err
+	}
+	// Flush every line so the receiver sees the original timing
+	return s.w.Flush()
+}
+
+func (s *lineSink) Close() error {
+	s.w.Flush()
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// syslogSink sends RFC 3164 messages, one per datagram or line
+type syslogSink struct {
+	conn     net.Conn
+	hostname string
+	stream   bool
+}
+
+func (s *syslogSink) Send(entry LogEntry) error {
+	severity, ok := syslogSeverity[entry.Level]
+	if !ok {
+		severity = 6
+	}
+	tag := entry.Source
+	if tag == "" {
+		tag = "loganalyzer"
+	}
+	msg := fmt.Sprintf("<%d>%s %s %s: %s", 8+severity, time.Now().Format(time.Stamp), s.hostname, tag, entry.Message)
+	if s.stream {
+		msg += "\n"
+	}
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+func (s *syslogSink) Close() error {
+	return s.conn.Close()
+}
+
+// openReplaySink parses a target: "-" for stdout, tcp://host:port,
+// udp://host:port, syslog://host:port (UDP) or syslog+tcp://host:port
+func openReplaySink(target string) (replaySink, error) {
+	if target == "" || target == "-" {
+		return &lineSink{w: bufio.NewWriter(os.Stdout)}, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "tcp", "udp":
+		conn, err := net.Dial(u.Scheme, u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &lineSink{w: bufio.NewWriter(conn), closer: conn}, nil
+	case "syslog", "syslog+udp", "syslog+tcp":
+		network := "udp"
+		if u.Scheme == "syslog+tcp" {
+			network = "tcp"
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "514")
+		}
+		conn, err := net.Dial(network, host)
+		if err != nil {
+			return nil, err
+		}
+		hostname, _ := os.Hostname()
+		return &syslogSink{conn: conn, hostname: hostname, stream: network == "tcp"}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported replay target %q", target)
+}
+
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	input := addInputFlags(fs)
+	target := fs.String("to", "-", "Where to send entries: - (stdout), tcp://host:port, udp://host:port, syslog://host:port, syslog+tcp://host:port")
+	speed := fs.Float64("speed", 1.0, "Replay speed multiplier (2 = twice as fast, 0 = no delays)")
+	maxDelay := fs.Duration("max-delay", 0, "Cap on any single pause between entries (0 = no cap)")
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 {
+		fmt.Println("Usage: loganalyzer replay -f <logfile> [-to target] [-speed N] [options]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	_, entries := input.load()
+
+	sink, err := openReplaySink(*target)
+	if err != nil {
+		log.Fatalf("Error opening replay target: %v", err)
+	}
+	defer sink.Close()
+
+	var prev time.Time
+	for _, entry := range entries {
+		if *speed > 0 && !prev.IsZero() && entry.Timestamp.After(prev) {
+			delay := time.Duration(float64(entry.Timestamp.Sub(prev)) / *speed)
+			if *maxDelay > 0 && delay > *maxDelay {
+				delay = *maxDelay
+			}
+			time.Sleep(delay)
+		}
+		if !entry.Timestamp.IsZero() {
+			prev = entry.Timestamp
+		}
+
+		if err := sink.Send(entry); err != nil {
+			log.Fatalf("Error sending entry: %v", err)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// s3Client signs requests with AWS Signature Version 4 using credentials
+// from the standard environment variables. Without credentials requests
+// are sent unsigned, which works for public buckets.
+type s3Client struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+	// endpoint, when set (AWS_ENDPOINT_URL, e.g. MinIO), is used with
+	// path-style addressing
+	endpoint string
+	client   *http.Client
+}
+
+func newS3Client() *s3Client {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &s3Client{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       region,
+		endpoint:     strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/"),
+		// Timeouts for connecting and the response headers only, as objects
+		// stream while they are parsed
+		client: httpInputClient,
+	}
+}
+
+// parseS3URL splits s3://bucket/key into its parts
+func parseS3URL(s string) (bucket, key string, err error) {
+	rest := strings.TrimPrefix(s, "s3://")
+	bucket, key, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 URL %q", s)
+	}
+	return bucket, key, nil
+}
+
+// s3Escape percent-encodes everything but unreserved characters, keeping
+// slashes when escaping a path, as SigV4 requires
+func s3Escape(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (path && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// get performs a signed GET for an object key (empty for bucket operations)
+func (c *s3Client) get(bucket, key string, query url.Values) (*http.Response, error) {
+	host := fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, c.region)
+	path := "/" + s3Escape(key, true)
+	base := "https://" + host
+	if c.endpoint != "" {
+		u, err := url.Parse(c.endpoint)
+		if err != nil {
+			return nil, err
+		}
+		host = u.Host
+		path = "/" + bucket + path
+		base = u.Scheme + "://" + host
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, len(keys))
+	for i, k := range keys {
+		params[i] = s3Escape(k, false) + "=" + s3Escape(query.Get(k), false)
+	}
+	canonicalQuery := strings.Join(params, "&")
+
+	reqURL := base + path
+	if canonicalQuery != "" {
+		reqURL += "?" + canonicalQuery
+	}
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.accessKey != "" {
+		c.sign(req, host, path, canonicalQuery)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3://%s/%s: %s %s", bucket, key, resp.Status, bytes.TrimSpace(body))
+	}
+	return resp, nil
+}
+
+// sign adds SigV4 headers for an unsigned-payload GET
+func (c *s3Client) sign(req *http.Request, host, path, canonicalQuery string) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	const payloadHash = "UNSIGNED-PAYLOAD"
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	headers := []string{"host:" + host, "x-amz-content-sha256:" + payloadHash, "x-amz-date:" + amzDate}
+	signed := "host;x-amz-content-sha256;x-amz-date"
+	if c.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", c.sessionToken)
+		headers = append(headers, "x-amz-security-token:"+c.sessionToken)
+		signed += ";x-amz-security-token"
+	}
+
+	canonical := strings.Join([]string{
+		"GET", path, canonicalQuery,
+		strings.Join(headers, "\n") + "\n",
+		signed, payloadHash,
+	}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac([]byte("AWS4"+c.secretKey), day)
+	key = mac(key, c.region)
+	key = mac(key, "s3")
+	key = mac(key, "aws4_request")
+	signature := hex.EncodeToString(mac(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signed, signature))
+}
+
+// list returns the keys under a prefix in lexical order, which for the
+// date-partitioned layouts of ALB/CloudFront/CloudTrail is time order
+func (c *s3Client) list(bucket, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.get(bucket, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range result.Contents {
+			if !strings.HasSuffix(obj.Key, "/") {
+				keys = append(keys, obj.Key)
+			}
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// openS3 opens an object, or every object under a prefix ending in "/" as
+// one stream. Up to workers requests are started ahead while earlier
+// objects are being parsed; their bodies stream, decompressed, as their
+// turn comes, so memory doesn't grow with object size.
+func openS3(name string, workers int) (io.ReadCloser, error) {
+	bucket, key, err := parseS3URL(name)
+	if err != nil {
+		return nil, err
+	}
+	c := newS3Client()
+
+	keys := []string{key}
+	if key == "" || strings.HasSuffix(key, "/") {
+		if keys, err = c.list(bucket, key); err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no objects under %s", name)
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	pr, pw := io.Pipe()
+	s3r := &s3Reader{PipeReader: pr, done: make(chan struct{}), results: make([]chan s3Result, len(keys))}
+	for i := range s3r.results {
+		s3r.results[i] = make(chan s3Result, 1)
+	}
+	slots := make(chan struct{}, workers)
+	go func() {
+		for i, k := range keys {
+			select {
+			case slots <- struct{}{}:
+			case <-s3r.done:
+				return
+			}
+			go func(i int, k string) {
+				resp, err := c.get(bucket, k, nil)
+				s3r.deliver(i, s3Result{resp: resp, err: err})
+			}(i, k)
+		}
+	}()
+
+	go func() {
+		w := &lastByteWriter{w: pw}
+		for i, k := range keys {
+			var r s3Result
+			select {
+			case r = <-s3r.results[i]:
+			case <-s3r.done:
+				return
+			}
+			if r.err == nil {
+				r.err = streamObject(w, r.resp.Body)
+				r.resp.Body.Close()
+			}
+			<-slots
+			if r.err != nil {
+				pw.CloseWithError(fmt.Errorf("s3://%s/%s: %v", bucket, k, r.err))
+				return
+			}
+			// Objects may not end with a newline; keep lines separate
+			if w.last != '\n' && w.n > 0 {
+				w.Write([]byte("\n"))
+			}
+			w.n = 0
+		}
+		pw.Close()
+	}()
+
+	return s3r, nil
+}
+
+// streamObject copies an object's body to w, decompressed
+func streamObject(w io.Writer, body io.Reader) error {
+	r, err := decompress(body)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// lastByteWriter remembers the last byte written and how many were
+type lastByteWriter struct {
+	w    io.Writer
+	n    int64
+	last byte
+}
+
+func (l *lastByteWriter) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	if n > 0 {
+		l.n += int64(n)
+		l.last = p[n-1]
+	}
+	return n, err
+}
+
+// s3Result is a started object download
+type s3Result struct {
+	resp *http.Response
+	err  error
+}
+
+// s3Reader stops outstanding downloads when closed early
+type s3Reader struct {
+	*io.PipeReader
+	done    chan struct{}
+	results []chan s3Result
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// deliver hands a started download to the stream, or closes it when the
+// reader was closed
+func (r *s3Reader) deliver(i int, res s3Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		if res.resp != nil {
+			res.resp.Body.Close()
+		}
+		return
+	}
+	r.results[i] <- res
+}
+
+func (r *s3Reader) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	close(r.done)
+	// Responses started ahead and never streamed
+	for _, ch := range r.results {
+		select {
+		case res := <-ch:
+			if res.resp != nil {
+				res.resp.Body.Close()
+			}
+		default:
+		}
+	}
+	r.mu.Unlock()
+	return r.PipeReader.Close()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretRule finds one kind of credential. When group is set only that
+// submatch is the secret; minEntropy rejects values that are too regular to
+// be generated keys (placeholders, words, repeated characters).
+type secretRule struct {
+	name       string
+	pattern    *regexp.Regexp
+	group      int
+	minEntropy float64
+}
+
+var secretRules = []secretRule{
+	{name: "private-key", pattern: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY-----`)},
+	{name: "aws-access-key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "aws-secret-key", pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})\b`), group: 1, minEntropy: 3.5},
+	{name: "github-token", pattern: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{name: "slack-token", pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{name: "stripe-key", pattern: regexp.MustCompile(`\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}\b`)},
+	{name: "google-api-key", pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{name: "bearer-token", pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{16,}=*)`), group: 1, minEntropy: 3.0},
+	{name: "password", pattern: regexp.MustCompile(`(?i)\b(?:password|passwd|pwd)["']?\s*[=:]\s*["']?([^\s"',;&]{4,})`), group: 1, minEntropy: 1.5},
+	{name: "generic-secret", pattern: regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret|token|access[_-]?key|client[_-]?secret)["']?\s*[=:]\s*["']?([A-Za-z0-9/+=_.-]{16,})`), group: 1, minEntropy: 3.5},
+}
+
+// SecretFinding is one likely credential found in a log line
+type SecretFinding struct {
+	File  string
+	Line  int
+	Kind  string
+	Match string
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	n := float64(len([]rune(s)))
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// findSecrets returns the kind and value of every likely secret in a line.
+// A value is only reported once, by the first (most specific) rule.
+func findSecrets(line string) [][2]string {
+	var found [][2]string
+	seen := make(map[string]bool)
+	for _, rule := range secretRules {
+		for _, m := range rule.pattern.FindAllStringSubmatch(line, -1) {
+			value := m[rule.group]
+			if seen[value] || strings.Contains(value, "*") {
+				continue
+			}
+			if rule.minEntropy > 0 && shannonEntropy(value) < rule.minEntropy {
+				continue
+			}
+			if rule.group > 0 && covered(found, value) {
+				continue
+			}
+			seen[value] = true
+			found = append(found, [2]string{rule.name, value})
+		}
+	}
+	return found
+}
+
+// covered reports whether value is part of a secret already found
+func covered(found [][2]string, value string) bool {
+	for _, f := range found {
+		if strings.Contains(f[1], value) || strings.Contains(value, f[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSecret keeps just enough of a secret to recognise it
+func maskSecret(s string) string {
+	keep := 4
+	if len(s) <= 8 {
+		keep = 1
+	}
+	return s[:keep] + strings.Repeat("*", min(len(s)-keep, 12))
+}
+
+// scanSecrets reads raw lines so findings can be reported by line number
+// regardless of whether the line parses as a log entry
+func scanSecrets(filename string, long *longLines) ([]SecretFinding, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var findings []SecretFinding
+	scanner := long.newScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		for _, f := range findSecrets(scanner.Text()) {
+			findings = append(findings, SecretFinding{File: filename, Line: lineNum, Kind: f[0], Match: f[1]})
+		}
+	}
+	return findings, scanner.Err()
+}
+
+// runScan audits log files for data that shouldn't have been logged. The
+// process exits 1 when anything is found so it can gate a pipeline.
+func runScan(args []string) {
+	if len(args) == 0 || args[0] != "secrets" {
+		fmt.Println("Usage: loganalyzer scan secrets -f <logfile> [-f <logfile>...]")
+		fmt.Println("Exits 0 when nothing is found, 1 on findings and 2 when a file cannot be scanned")
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("scan secrets", flag.ExitOnError)
+	var files fileList
+	fs.Var(&files, "f", "Log file to scan, - for stdin (repeat for several)")
+	maxLine := byteSize(defaultMaxLineSize)
+	fs.Var(&maxLine, "max-line-size", "Longest line scanned whole (e.g. 10MB); longer lines are cut to this size")
+	parseFlags(fs, args[1:])
+	long := &longLines{max: int(maxLine)}
+
+	if len(files) == 0 {
+		files = fileList{"-"}
+	}
+
+	var findings []SecretFinding
+	for _, filename := range files {
+		found, err := scanSecrets(filename, long)
+		if err != nil {
+			// Exit 1 means findings, so a failed scan must not look like one
+			fmt.Fprintf(os.Stderr, "Error scanning file: %v\n", err)
+			os.Exit(2)
+		}
+		findings = append(findings, found...)
+	}
+	if n := long.count.Load(); n > 0 {
+		fmt.Fprintf(os.Stderr, "%d lines were cut at -max-line-size; their rest was not scanned\n", n)
+	}
+
+	if len(findings) == 0 {
+		fmt.Printf("No likely secrets found in %d file(s)\n", len(files))
+		return
+	}
+
+	fmt.Printf("=== Secret Scan: %d findings ===\n", len(findings))
+	byKind := make(map[string]int)
+	for _, f := range findings {
+		byKind[f.Kind]++
+		fmt.Printf("%s:%d  %-15s  %s\n", f.File, f.Line, f.Kind, maskSecret(f.Match))
+	}
+
+	kinds := make([]string, 0, len(byKind))
+	for kind := range byKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	fmt.Println("\n=== By Kind ===")
+	for _, kind := range kinds {
+		fmt.Printf("%-15s %d\n", kind, byKind[kind])
+	}
+	os.Exit(1)
+}
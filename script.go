@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaScript runs a -script file's transform(entry) function on every parsed
+// entry. The entry is a table with timestamp (RFC 3339), level, message,
+// source and raw, a fields table of the entry's other fields, plus an
+// access table for access log requests. The function returns the table,
+// changed in place or a new one, or nil/false to drop the entry.
+type luaScript struct {
+	path string
+	// An LState is single-threaded
+	mu sync.Mutex
+	L  *lua.LState
+	fn lua.LValue
+}
+
+func loadScript(path string) (*luaScript, error) {
+	L := lua.NewState()
+	if err := L.DoFile(path); err != nil {
+		L.Close()
+		return nil, err
+	}
+	fn := L.GetGlobal("transform")
+	if fn.Type() != lua.LTFunction {
+		L.Close()
+		return nil, fmt.Errorf("%s: no transform(entry) function", path)
+	}
+	return &luaScript{path: path, L: L, fn: fn}, nil
+}
+
+func (s *luaScript) String() string {
+	return "Script " + s.path
+}
+
+func (s *luaScript) Transform(entry *LogEntry) (*LogEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	L := s.L
+	if err := L.CallByParam(lua.P{Fn: s.fn, NRet: 1, Protect: true}, entryTable(L, entry)); err != nil {
+		return nil, err
+	}
+	ret := L.Get(-1)
+	L.Pop(1)
+
+	tbl, ok := ret.(*lua.LTable)
+	if !ok {
+		if lua.LVAsBool(ret) {
+			return nil, fmt.Errorf("transform returned a %s, not a table", ret.Type())
+		}
+		return nil, nil
+	}
+	return tableEntry(tbl, entry)
+}
+
+func entryTable(L *lua.LState, entry *LogEntry) *lua.LTable {
+	tbl := L.NewTable()
+	if !entry.Timestamp.IsZero() {
+		tbl.RawSetString("timestamp", lua.LString(entry.Timestamp.Format(time.RFC3339Nano)))
+	}
+	tbl.RawSetString("level", lua.LString(entry.Level))
+	tbl.RawSetString("message", lua.LString(entry.Message))
+	tbl.RawSetString("source", lua.LString(entry.Source))
+	tbl.RawSetString("raw", lua.LString(entry.Raw))
+
+	fields := L.NewTable()
+	for name, value := range entry.Fields {
+		switch v := value.(type) {
+		case float64:
+			fields.RawSetString(name, lua.LNumber(v))
+		case bool:
+			fields.RawSetString(name, lua.LBool(v))
+		default:
+			fields.RawSetString(name, lua.LString(parser.FieldString(v)))
+		}
+	}
+	tbl.RawSetString("fields", fields)
+
+	if a := entry.Access; a != nil {
+		access := L.NewTable()
+		access.RawSetString("client_ip", lua.LString(a.ClientIP))
+		access.RawSetString("method", lua.LString(a.Method))
+		access.RawSetString("path", lua.LString(a.Path))
+		access.RawSetString("protocol", lua.LString(a.Protocol))
+		access.RawSetString("status", lua.LNumber(a.Status))
+		access.RawSetString("bytes", lua.LNumber(a.Bytes))
+		access.RawSetString("referrer", lua.LString(a.Referrer))
+		access.RawSetString("user_agent", lua.LString(a.UserAgent))
+		access.RawSetString("hostname", lua.LString(a.Hostname))
+		tbl.RawSetString("access", access)
+	}
+	return tbl
+}
+
+// tableEntry reads a returned table back into a copy of the original entry;
+// fields the script removed or left unset keep their value
+func tableEntry(tbl *lua.LTable, orig *LogEntry) (*LogEntry, error) {
+	entry := *orig
+	str := func(t *lua.LTable, key string, dst *string) {
+		if v, ok := t.RawGetString(key).(lua.LString); ok {
+			*dst = string(v)
+		}
+	}
+
+	if v, ok := tbl.RawGetString("timestamp").(lua.LString); ok {
+		t, err := time.Parse(time.RFC3339Nano, string(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", v)
+		}
+		entry.Timestamp = t
+	}
+	str(tbl, "level", &entry.Level)
+	entry.Level = strings.ToUpper(entry.Level)
+	str(tbl, "message", &entry.Message)
+	str(tbl, "source", &entry.Source)
+	str(tbl, "raw", &entry.Raw)
+
+	// The fields table holds all of the entry's fields, so keys the script
+	// removed are dropped
+	if t, ok := tbl.RawGetString("fields").(*lua.LTable); ok {
+		fields := make(map[string]interface{})
+		var err error
+		t.ForEach(func(k, v lua.LValue) {
+			name, ok := k.(lua.LString)
+			switch {
+			case err != nil:
+			case !ok:
+				err = fmt.Errorf("field name %s is not a string", k)
+			case v.Type() == lua.LTString:
+				fields[string(name)] = v.String()
+			case v.Type() == lua.LTNumber:
+				fields[string(name)] = float64(v.(lua.LNumber))
+			case v.Type() == lua.LTBool:
+				fields[string(name)] = bool(v.(lua.LBool))
+			default:
+				err = fmt.Errorf("field %s is a %s, not a string, number or boolean", name, v.Type())
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		entry.Fields = fields
+		if len(fields) == 0 {
+			entry.Fields = nil
+		}
+	}
+
+	if t, ok := tbl.RawGetString("access").(*lua.LTable); ok && orig.Access != nil {
+		a := *orig.Access
+		str(t, "client_ip", &a.ClientIP)
+		str(t, "method", &a.Method)
+		str(t, "path", &a.Path)
+		str(t, "protocol", &a.Protocol)
+		str(t, "referrer", &a.Referrer)
+		str(t, "user_agent", &a.UserAgent)
+		str(t, "hostname", &a.Hostname)
+		if v, ok := t.RawGetString("status").(lua.LNumber); ok {
+			a.Status = int(v)
+		}
+		if v, ok := t.RawGetString("bytes").(lua.LNumber); ok {
+			a.Bytes = int64(v)
+		}
+		entry.Access = &a
+	}
+	return &entry, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testScript loads a -script file holding src
+func testScript(t *testing.T, src string) *luaScript {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "transform.lua")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadScript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.L.Close)
+	return s
+}
+
+func TestScriptFields(t *testing.T) {
+	s := testScript(t, `
+function transform(entry)
+  local f = entry.fields
+  f.tenant = string.match(f.path, "^/t/(%w+)/")
+  f.slow = tonumber(f.latency_ms) > 500
+  f.latency_s = tonumber(f.latency_ms) / 1000
+  f.path = nil
+  if f.tenant == "internal" then
+    return nil
+  end
+  entry.message = entry.message .. " for " .. f.tenant
+  return entry
+end`)
+
+	entry := &LogEntry{Message: "request", Fields: map[string]interface{}{"path": "/t/acme/orders", "latency_ms": "750"}}
+	got, err := s.Transform(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"tenant": "acme", "slow": true, "latency_ms": "750", "latency_s": 0.75}
+	if !reflect.DeepEqual(got.Fields, want) {
+		t.Errorf("Fields = %v, want %v", got.Fields, want)
+	}
+	if got.Message != "request for acme" {
+		t.Errorf("Message = %q", got.Message)
+	}
+	if entry.Fields["path"] != "/t/acme/orders" {
+		t.Errorf("the original entry's fields changed: %v", entry.Fields)
+	}
+
+	dropped, err := s.Transform(&LogEntry{Fields: map[string]interface{}{"path": "/t/internal/x", "latency_ms": "1"}})
+	if err != nil || dropped != nil {
+		t.Errorf("Transform = %v, %v, want the entry dropped", dropped, err)
+	}
+}
+
+func TestScriptFieldTypes(t *testing.T) {
+	s := testScript(t, `
+function transform(entry)
+  local f = entry.fields
+  f.next = f.retries + 1
+  f.was_cached = f.cached
+  return entry
+end`)
+	got, err := s.Transform(&LogEntry{Fields: map[string]interface{}{"retries": 2.0, "cached": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"retries": 2.0, "cached": true, "next": 3.0, "was_cached": true}
+	if !reflect.DeepEqual(got.Fields, want) {
+		t.Errorf("Fields = %v, want %v", got.Fields, want)
+	}
+}
+
+func TestScriptFieldErrors(t *testing.T) {
+	s := testScript(t, `
+function transform(entry)
+  entry.fields.nested = {}
+  return entry
+end`)
+	if _, err := s.Transform(&LogEntry{}); err == nil {
+		t.Error("a table field was accepted")
+	}
+}
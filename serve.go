@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed web/index.html
+var webUI []byte
+
+// histogramWidths are the bucket widths offered for charts, smallest first
+var histogramWidths = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// maxHistogramBuckets bounds how many bars an automatic histogram has
+const maxHistogramBuckets = 120
+
+// maxRequestBuckets bounds the buckets a request can ask for, checked
+// before any are allocated
+const maxRequestBuckets = 100000
+
+// maxSearchLimit bounds the entries one /api/search response can hold
+const maxSearchLimit = 10000
+
+// histogramWidth picks the smallest standard width that covers the entries'
+// time span in at most maxHistogramBuckets buckets
+func histogramWidth(entries []LogEntry) time.Duration {
+	first, last := timeSpan(entries)
+	span := last.Sub(first)
+	for _, w := range histogramWidths {
+		if span/w < maxHistogramBuckets {
+			return w
+		}
+	}
+	return histogramWidths[len(histogramWidths)-1]
+}
+
+// parseQueryTime accepts RFC 3339, the -start/-end format, the format
+// produced by an HTML datetime-local input, or a duration meaning that long
+// ago (since=15m)
+func parseQueryTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		t := time.Now().Add(-d)
+		return &t, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid time %q", value)
+}
+
+// queryFilters builds Filters from URL parameters (q, level, source,
+// start or since, end or until, country)
+func queryFilters(r *http.Request) (Filters, error) {
+	q := r.URL.Query()
+	f := Filters{
+		Level:    strings.ToUpper(q.Get("level")),
+		Source:   q.Get("source"),
+		Keywords: q["q"],
+		Country:  q.Get("country"),
+	}
+	start, end := q.Get("start"), q.Get("end")
+	if start == "" {
+		start = q.Get("since")
+	}
+	if end == "" {
+		end = q.Get("until")
+	}
+	var err error
+	if f.StartTime, err = parseQueryTime(start); err != nil {
+		return f, err
+	}
+	if f.EndTime, err = parseQueryTime(end); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// logServer answers queries over the entries of a set of watched files
+type logServer struct {
+	analyzer *LogAnalyzer
+	format   string
+	files    []string
+	offsets  map[string]int64
+
+	mu      sync.RWMutex
+	entries []LogEntry
+}
+
+// poll reads whatever was appended to each file since the last poll. A file
+// that shrank was rotated or truncated and is read again from the start.
+func (s *logServer) poll() error {
+	for _, filename := range s.files {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err == nil && info.Size() < s.offsets[filename] {
+			s.offsets[filename] = 0
+		}
+		if _, err := file.Seek(s.offsets[filename], io.SeekStart); err != nil {
+			file.Close()
+			return err
+		}
+
+		var added []LogEntry
+		reader := bufio.NewReader(file)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				// Leave a partial last line for the next poll
+				break
+			}
+			atStart := s.offsets[filename] == 0
+			s.offsets[filename] += int64(len(line))
+			entry := s.analyzer.parseLine(trimLine(line, atStart), s.format)
+			if entry == nil {
+				continue
+			}
+			entry.File = filename
+			s.analyzer.enrich(entry)
+			if s.analyzer.matchesFilters(*entry) {
+				added = append(added, *entry)
+			}
+		}
+		file.Close()
+
+		if len(added) > 0 {
+			s.analyzer.resolveHostnames(added)
+			s.mu.Lock()
+			s.entries = append(s.entries, added...)
+			s.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// watch polls the files for new lines until the process exits
+func (s *logServer) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.poll(); err != nil {
+			log.Printf("Error reading log files: %v", err)
+		}
+	}
+}
+
+// search returns the entries matching the request's filters in timestamp
+// order
+func (s *logServer) search(r *http.Request) ([]LogEntry, error) {
+	filters, err := queryFilters(r)
+	if err != nil {
+		return nil, err
+	}
+	var matched []LogEntry
+	s.mu.RLock()
+	for _, entry := range s.entries {
+		if filters.Match(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortEntries(matched)
+	return matched, nil
+}
+
+// queryInt reads a non-negative integer parameter
+func queryInt(r *http.Request, name string, def int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+// handleEntries serves GET /entries: matching entries, oldest first, paged
+// with limit and offset
+func (s *logServer) handleEntries(w http.ResponseWriter, r *http.Request) {
+	matched, err := s.search(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	total := len(matched)
+	offset := queryInt(r, "offset", 0)
+	limit := queryInt(r, "limit", 1000)
+	if offset > total {
+		offset = total
+	}
+	matched = matched[offset:]
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"total":   total,
+		"offset":  offset,
+		"entries": matched,
+	})
+}
+
+// handleStats serves GET /stats: a summary of the matching entries
+func (s *logServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	matched, err := s.search(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summary := summarize(matched)
+	sources := make(map[string]int)
+	for _, entry := range matched {
+		sources[entry.Source]++
+	}
+	writeJSON(w, map[string]interface{}{
+		"entries":   summary.Entries,
+		"first":     summary.First,
+		"last":      summary.Last,
+		"levels":    summary.Levels,
+		"errorRate": summary.ErrorRate(),
+		"errors":    summary.Errors,
+		"sources":   sources,
+	})
+}
+
+// handleHistogram serves GET /histogram?bucket=1m; without bucket a width is
+// chosen from the time span
+func (s *logServer) handleHistogram(w http.ResponseWriter, r *http.Request) {
+	matched, err := s.search(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	width := histogramWidth(matched)
+	if v := r.URL.Query().Get("bucket"); v != "" {
+		width, err = time.ParseDuration(v)
+		if err != nil || width <= 0 {
+			http.Error(w, fmt.Sprintf("invalid bucket %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if bucketCount(matched, width) > maxRequestBuckets {
+		http.Error(w, "bucket too small for the time range", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"bucket":  width.String(),
+		"buckets": bucketEntries(matched, width),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// handleSearch backs the web UI: the newest matching entries, level counts
+// and a histogram in one response
+func (s *logServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	matched, err := s.search(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 500
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxSearchLimit {
+			http.Error(w, fmt.Sprintf("invalid limit %q (0 to %d)", v, maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		if n > 0 {
+			limit = n
+		}
+	}
+
+	levels := make(map[string]int)
+	for _, entry := range matched {
+		levels[entry.Level]++
+	}
+
+	newest := make([]LogEntry, 0, min(limit, len(matched)))
+	for i := len(matched) - 1; i >= 0 && len(newest) < limit; i-- {
+		newest = append(newest, matched[i])
+	}
+
+	width := histogramWidth(matched)
+	if bucketCount(matched, width) > maxRequestBuckets {
+		http.Error(w, "time range too long for a histogram", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"total":     len(matched),
+		"levels":    levels,
+		"entries":   newest,
+		"bucket":    width.String(),
+		"histogram": bucketEntries(matched, width),
+	})
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	input := addInputFlags(fs)
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to serve the web UI and API on; the API has no authentication, so listen beyond localhost only on trusted networks")
+	interval := fs.Duration("watch-interval", 2*time.Second, "How often to check the files for new lines")
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 {
+		fmt.Println("Usage: loganalyzer serve -f <logfile|dir> [-f ...] [-listen 127.0.0.1:8080] [options]")
+		fmt.Println("API:   GET /entries?level=ERROR&since=15m&q=...&limit=N&offset=N")
+		fmt.Println("       GET /stats?...  GET /histogram?bucket=1m&...")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	for _, filename := range input.files {
+		if filename == "-" {
+			log.Fatal("serve watches files and can't read stdin")
+		}
+	}
+
+	s := &logServer{
+		analyzer: input.newAnalyzer(),
+		format:   *input.format,
+		files:    input.files,
+		offsets:  make(map[string]int64),
+	}
+	if err := s.poll(); err != nil {
+		log.Fatalf("Error parsing file: %v", err)
+	}
+	go s.watch(*interval)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(webUI)
+	})
+	mux.HandleFunc("/api/search", s.handleSearch)
+	mux.HandleFunc("/entries", s.handleEntries)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/histogram", s.handleHistogram)
+
+	fmt.Printf("Serving %d entries from %d file(s) on http://%s/\n", len(s.entries), len(input.files), *listen)
+	log.Fatal(http.ListenAndServe(*listen, mux))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Session is a run of requests from one client (IP + user agent) with no
+// idle period longer than the session timeout
+type Session struct {
+	ClientIP  string
+	UserAgent string
+	Start     time.Time
+	End       time.Time
+	Pages     []string
+}
+
+// sessionize groups access log entries into sessions
+func sessionize(entries []LogEntry, timeout time.Duration) []Session {
+	type visitor struct{ ip, agent string }
+	requests := make(map[visitor][]LogEntry)
+
+	for _, entry := range entries {
+		if entry.Access == nil || entry.Timestamp.IsZero() {
+			continue
+		}
+		v := visitor{entry.Access.ClientIP, entry.Access.UserAgent}
+		requests[v] = append(requests[v], entry)
+	}
+
+	var sessions []Session
+	for v, reqs := range requests {
+		sort.SliceStable(reqs, func(i, j int) bool {
+			return reqs[i].Timestamp.Before(reqs[j].Timestamp)
+		})
+
+		var current *Session
+		for _, r := range reqs {
+			if current != nil && r.Timestamp.Sub(current.End) > timeout {
+				sessions = append(sessions, *current)
+				current = nil
+			}
+			if current == nil {
+				current = &Session{ClientIP: v.ip, UserAgent: v.agent, Start: r.Timestamp}
+			}
+			current.End = r.Timestamp
+			current.Pages = append(current.Pages, r.Access.Path)
+		}
+		if current != nil {
+			sessions = append(sessions, *current)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Start.Before(sessions[j].Start)
+	})
+
+	return sessions
+}
+
+func (la *LogAnalyzer) showSessions(entries []LogEntry, timeout time.Duration) {
+	sessions := sessionize(entries, timeout)
+
+	fmt.Printf("=== Sessions (idle timeout %s) ===\n", timeout)
+	if len(sessions) == 0 {
+		fmt.Println("No access log entries to sessionize")
+		return
+	}
+
+	pages := 0
+	var duration time.Duration
+	entryPages := make(map[string]int)
+	exitPages := make(map[string]int)
+	visitors := make(map[string]bool)
+
+	for _, s := range sessions {
+		pages += len(s.Pages)
+		duration += s.End.Sub(s.Start)
+		entryPages[s.Pages[0]]++
+		exitPages[s.Pages[len(s.Pages)-1]]++
+		visitors[s.ClientIP+" "+s.UserAgent] = true
+	}
+
+	fmt.Printf("Sessions: %d\n", len(sessions))
+	fmt.Printf("Unique Visitors: %d\n", len(visitors))
+	fmt.Printf("Avg Pages/Session: %.1f\n", float64(pages)/float64(len(sessions)))
+	fmt.Printf("Avg Session Duration: %s\n", (duration / time.Duration(len(sessions))).Round(time.Second))
+	fmt.Println()
+
+	fmt.Println("Top Entry Pages:")
+	la.printTopMap(entryPages, 10)
+	fmt.Println()
+
+	fmt.Println("Top Exit Pages:")
+	la.printTopMap(exitPages, 10)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// sizeBuckets are the upper bounds (exclusive) of the response size
+// histogram; the last bucket has none
+var sizeBuckets = []struct {
+	label string
+	max   int64
+}{
+	{"0 B", 1},
+	{"< 1 KB", 1 << 10},
+	{"1-10 KB", 10 << 10},
+	{"10-100 KB", 100 << 10},
+	{"100 KB-1 MB", 1 << 20},
+	{"1-10 MB", 10 << 20},
+	{">= 10 MB", -1},
+}
+
+func sizeBucket(bytes int64) int {
+	for i, b := range sizeBuckets {
+		if b.max < 0 || bytes < b.max {
+			return i
+		}
+	}
+	return len(sizeBuckets) - 1
+}
+
+// showSizes prints a histogram of access log response sizes per status
+// class, then the largest responses. Error pages served with 200 show up
+// as 2xx responses in the size range of the error pages.
+func (la *LogAnalyzer) showSizes(entries []LogEntry, top int) {
+	counts := make(map[int][]int)
+	var requests []*AccessInfo
+	for _, entry := range entries {
+		a := entry.Access
+		if a == nil {
+			continue
+		}
+		class := a.Status / 100
+		if counts[class] == nil {
+			counts[class] = make([]int, len(sizeBuckets))
+		}
+		counts[class][sizeBucket(a.Bytes)]++
+		requests = append(requests, a)
+	}
+	if len(requests) == 0 {
+		fmt.Println("No access log requests to report on")
+		return
+	}
+
+	classes := make([]int, 0, len(counts))
+	for class := range counts {
+		classes = append(classes, class)
+	}
+	sort.Ints(classes)
+
+	fmt.Println("=== Response Sizes by Status Class ===")
+	fmt.Printf("%-12s", "Size")
+	for _, class := range classes {
+		fmt.Printf(" %15s", fmt.Sprintf("%dxx", class))
+	}
+	fmt.Println()
+	for i, b := range sizeBuckets {
+		fmt.Printf("%-12s", b.label)
+		for _, class := range classes {
+			total := 0
+			for _, n := range counts[class] {
+				total += n
+			}
+			n := counts[class][i]
+			fmt.Printf(" %15s", fmt.Sprintf("%d (%.1f%%)", n, 100*float64(n)/float64(total)))
+		}
+		fmt.Println()
+	}
+
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].Bytes > requests[j].Bytes
+	})
+	if len(requests) > top {
+		requests = requests[:top]
+	}
+	fmt.Println()
+	fmt.Println("Largest Responses:")
+	for _, a := range requests {
+		fmt.Printf("  %10s  %d %s %s\n", formatBytes(a.Bytes), a.Status, a.Method, a.Path)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationFields are tried in order when -duration-field isn't given
+var durationFields = []string{
+	"duration_ms", "latency_ms", "elapsed_ms", "response_time_ms", "took_ms",
+	"duration", "latency", "elapsed", "response_time", "took",
+	"request_time", "upstream_response_time", "time_taken",
+}
+
+// secondsFields hold bare numbers in seconds (nginx $request_time,
+// CloudFront time-taken) despite having no unit suffix
+var secondsFields = map[string]bool{
+	"request_time": true, "upstream_response_time": true, "time_taken": true,
+}
+
+// fieldDuration reads a duration field: a Go duration (1.5s, 250ms) or a
+// bare number in the unit the name ends with (_s, _ms, _us, _ns), else in
+// milliseconds like -derive's duration()
+func fieldDuration(entry *LogEntry, name string) (time.Duration, bool) {
+	v, ok := entry.Field(name)
+	if !ok || v == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		d, err := time.ParseDuration(v)
+		return d, err == nil
+	}
+	unit := time.Millisecond
+	switch {
+	case secondsFields[name] || strings.HasSuffix(name, "_s") || strings.HasSuffix(name, "_sec") || strings.HasSuffix(name, "_seconds"):
+		unit = time.Second
+	case strings.HasSuffix(name, "_us"):
+		unit = time.Microsecond
+	case strings.HasSuffix(name, "_ns"):
+		unit = time.Nanosecond
+	}
+	return time.Duration(n * float64(unit)), true
+}
+
+// entryDuration returns the entry's duration from the named field, or from
+// the first of durationFields it has when field is empty
+func entryDuration(entry *LogEntry, field string) (time.Duration, bool) {
+	if field != "" {
+		return fieldDuration(entry, field)
+	}
+	for _, name := range durationFields {
+		if d, ok := fieldDuration(entry, name); ok {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// showSlowest lists the n entries with the longest durations
+func (la *LogAnalyzer) showSlowest(entries []LogEntry, n int, field string) {
+	type timed struct {
+		entry    *LogEntry
+		duration time.Duration
+	}
+	var requests []timed
+	for i := range entries {
+		if d, ok := entryDuration(&entries[i], field); ok {
+			requests = append(requests, timed{&entries[i], d})
+		}
+	}
+	if len(requests) == 0 {
+		if field == "" {
+			field = strings.Join(durationFields, ", ")
+		}
+		log.Fatalf("No entries with a duration field (%s); name one with -duration-field or create it with -derive", field)
+	}
+
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].duration > requests[j].duration
+	})
+	if len(requests) > n {
+		requests = requests[:n]
+	}
+
+	fmt.Printf("=== %d Slowest Requests ===\n", len(requests))
+	fmt.Printf("%12s  %-19s  %s\n", "Duration", "Timestamp", "Request")
+	for _, r := range requests {
+		timestamp := "-"
+		if !r.entry.Timestamp.IsZero() {
+			timestamp = r.entry.Timestamp.Format("2006-01-02 15:04:05")
+		}
+		request := r.entry.Message
+		if a := r.entry.Access; a != nil {
+			request = fmt.Sprintf("%d %s %s", a.Status, a.Method, a.Path)
+		}
+		fmt.Printf("%12s  %-19s  %s\n", r.duration.Round(time.Microsecond), timestamp, request)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// timestampShapes are the timestamp layouts `detect format` looks for; a
+// line counts for the first one it contains
+var timestampShapes = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"2006-01-02T15:04:05Z07:00 (RFC 3339)", regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}`)},
+	{"2006-01-02 15:04:05", regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`)},
+	{"2006/01/02 15:04:05", regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}`)},
+	{"02/Jan/2006:15:04:05 -0700 (common log)", regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}`)},
+	{"Mon Jan 02 15:04:05 2006 (ctime)", regexp.MustCompile(`[A-Z][a-z]{2} [A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4}`)},
+	{"Jan _2 15:04:05 (syslog)", regexp.MustCompile(`\b[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}\b`)},
+	{"02 Jan 2006 15:04:05", regexp.MustCompile(`\b\d{2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2}`)},
+	{"Unix time", regexp.MustCompile(`\b1\d{9}(?:\.\d+|\d{3})?\b`)},
+}
+
+// runDetectFormat reports how well each format reads a sample of every
+// input, so a wrong auto-detection can be traced to the lines behind it
+func runDetectFormat(args []string) {
+	fs := flag.NewFlagSet("detect format", flag.ExitOnError)
+	input := addInputFlags(fs)
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 {
+		fmt.Println("Usage: loganalyzer detect format -f <logfile> [-f <logfile>...] [-sniff-lines 50]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	analyzer := input.newAnalyzer()
+	size := *input.sniffLines
+	if size <= 0 {
+		size = defaultSniffLines
+	}
+
+	for i, filename := range input.files {
+		sample, err := analyzer.sampleLines(filename, size)
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", filename, err)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		analyzer.showFormatReport(filename, sample)
+	}
+}
+
+// sampleLines reads the first n lines of an input
+func (la *LogAnalyzer) sampleLines(filename string, n int) ([]string, error) {
+	r, err := la.openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var lines []string
+	scanner := la.newLineScanner(r)
+	for len(lines) < n && scanner.Scan() {
+		lines = append(lines, la.long.clip(scanner.Text()))
+	}
+	return lines, scanner.Err()
+}
+
+func (la *LogAnalyzer) showFormatReport(filename string, sample []string) {
+	fmt.Printf("=== Format Detection: %s (%d lines sampled) ===\n", filename, len(sample))
+	if len(sample) == 0 {
+		fmt.Println("No lines to sample")
+		return
+	}
+	percent := func(n int) float64 {
+		return float64(n) * 100 / float64(len(sample))
+	}
+
+	type score struct {
+		name             string
+		detected, parsed int
+	}
+	var scores []score
+	for _, name := range parser.Names() {
+		p, _ := parser.Lookup(name)
+		// A session per format, so multi-line formats see the lines in order
+		session := parser.NewSession(name)
+		s := score{name: name}
+		for _, line := range sample {
+			if p.Detect(line) {
+				s.detected++
+			}
+			if _, ok := session.TryParse(line); ok {
+				s.parsed++
+			}
+		}
+		if s.detected > 0 || s.parsed > 0 {
+			scores = append(scores, s)
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].detected > scores[j].detected })
+
+	if len(scores) == 0 {
+		fmt.Println("No format recognizes any of these lines")
+	} else {
+		fmt.Printf("%-14s %14s %14s\n", "Format", "Detected", "Parsed")
+		for _, s := range scores {
+			fmt.Printf("%-14s %6d %6.1f%% %6d %6.1f%%\n", s.name, s.detected, percent(s.detected), s.parsed, percent(s.parsed))
+		}
+	}
+
+	shapes := make(map[string]int)
+	for _, line := range sample {
+		shape := "none"
+		for _, ts := range timestampShapes {
+			if ts.re.MatchString(line) {
+				shape = ts.name
+				break
+			}
+		}
+		shapes[shape]++
+	}
+	names := make([]string, 0, len(shapes))
+	for name := range shapes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if shapes[names[i]] != shapes[names[j]] {
+			return shapes[names[i]] > shapes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	fmt.Println()
+	fmt.Println("Timestamp Layouts:")
+	for _, name := range names {
+		fmt.Printf("  %-42s %6d %6.1f%%\n", name, shapes[name], percent(shapes[name]))
+	}
+
+	fmt.Println()
+	best := la.sniffFormat(sample)
+	switch {
+	case best != "auto":
+		fmt.Printf("Recommendation: -format %s (auto mode picks it for this input)\n", best)
+	case len(scores) > 0:
+		fmt.Println("Recommendation: no format reads most lines, so auto mode detects line by line;")
+		fmt.Println("if the input is one layout, describe it with -log4j or a config pattern and check it with -rejects")
+	default:
+		fmt.Println("Recommendation: describe the layout with -log4j or a config pattern and check it with -rejects")
+	}
+}
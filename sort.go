@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hrabid/log-analyzer/pkg/output"
+)
+
+// sortEntries orders entries by timestamp, keeping file order for ties
+func sortEntries(entries []LogEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+}
+
+// JSON arrays in Fields are spilled as interface values, which gob only
+// encodes once their types are registered
+func init() {
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// sortSegment is a sorted run of entries spilled to a temporary file
+type sortSegment struct {
+	file    *os.File
+	decoder *gob.Decoder
+	head    LogEntry
+	seq     int
+}
+
+// segmentHeap merges segments by their current head entry; seq breaks ties
+// so equal timestamps keep their original order
+type segmentHeap []*sortSegment
+
+func (h segmentHeap) Len() int { return len(h) }
+func (h segmentHeap) Less(i, j int) bool {
+	if h[i].head.Timestamp.Equal(h[j].head.Timestamp) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].head.Timestamp.Before(h[j].head.Timestamp)
+}
+func (h segmentHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *segmentHeap) Push(x interface{}) { *h = append(*h, x.(*sortSegment)) }
+func (h *segmentHeap) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
+
+// outputSpilled streams the filtered entries of the given files, in
+// timestamp order when sorted is set. Entries are buffered in memory up to
+// bufferSize entries (when sorting) or maxBytes of estimated memory (when
+// positive); beyond that the buffer is spilled to a temporary segment and
+// the segments are concatenated, or merged when sorted, on output.
+func (la *LogAnalyzer) outputSpilled(files []string, format string, sorted bool, bufferSize int, maxBytes int64, head, tail int, output string, verbose bool) error {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
+	var segments []*sortSegment
+	defer func() {
+		for _, s := range segments {
+			s.file.Close()
+			os.Remove(s.file.Name())
+		}
+	}()
+
+	var chunk []LogEntry
+	var chunkBytes int64
+	spill := func() error {
+		la.resolveHostnames(chunk)
+		if sorted {
+			sortEntries(chunk)
+		}
+		s, err := writeSegment(chunk, len(segments))
+		if err != nil {
+			return err
+		}
+		segments = append(segments, s)
+		chunk = chunk[:0]
+		chunkBytes = 0
+		return nil
+	}
+
+	var spillErr error
+	for _, filename := range files {
+		err := la.scanFile(filename, format, func(entry *LogEntry) {
+			if spillErr != nil || !la.matchesFilters(*entry) {
+				return
+			}
+			chunk = append(chunk, *entry)
+			chunkBytes += entrySize(entry)
+			if (sorted && len(chunk) >= bufferSize) || (maxBytes > 0 && chunkBytes >= maxBytes) {
+				spillErr = spill()
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if spillErr != nil {
+			return spillErr
+		}
+	}
+
+	var next func() (LogEntry, bool, error)
+	if len(segments) == 0 {
+		// Everything fit in memory
+		la.resolveHostnames(chunk)
+		if sorted {
+			sortEntries(chunk)
+		}
+		i := 0
+		next = func() (LogEntry, bool, error) {
+			if i >= len(chunk) {
+				return LogEntry{}, false, nil
+			}
+			i++
+			return chunk[i-1], true, nil
+		}
+	} else {
+		if len(chunk) > 0 {
+			if err := spill(); err != nil {
+				return err
+			}
+		}
+		if sorted {
+			merge, err := mergeSegments(segments)
+			if err != nil {
+				return err
+			}
+			next = merge
+		} else {
+			next = concatSegments(segments)
+		}
+	}
+
+	return la.streamEntries(next, head, tail, output, verbose)
+}
+
+// entrySize estimates the memory an entry holds: its strings plus struct,
+// pointer and map overhead
+func entrySize(e *LogEntry) int64 {
+	n := 160 + len(e.Raw) + len(e.Message) + len(e.Level) + len(e.Source)
+	for k, v := range e.Fields {
+		n += 64 + len(k)
+		if s, ok := v.(string); ok {
+			n += len(s)
+		}
+	}
+	if a := e.Access; a != nil {
+		n += 160 + len(a.ClientIP) + len(a.Method) + len(a.Path) + len(a.Protocol) + len(a.UserAgent) + len(a.Hostname)
+		if a.Agent != nil {
+			n += 128
+		}
+		if a.Geo != nil {
+			n += 64
+		}
+	}
+	return int64(n)
+}
+
+// byteSize is a flag holding a size such as 512MB or 1GB; units are
+// powers of 1024 and a bare number is bytes
+type byteSize int64
+
+func (b *byteSize) String() string {
+	if *b == 0 {
+		return ""
+	}
+	return formatBytes(int64(*b))
+}
+
+func (b *byteSize) Set(value string) error {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	mult := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			mult = 1 << (10 * (i + 1))
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q (e.g. 512MB, 1GB)", value)
+	}
+	*b = byteSize(n * float64(mult))
+	return nil
+}
+
+func writeSegment(entries []LogEntry, seq int) (*sortSegment, error) {
+	file, err := os.CreateTemp("", "loganalyzer-spill-*")
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(file)
+	enc := gob.NewEncoder(w)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &sortSegment{file: file, decoder: gob.NewDecoder(bufio.NewReader(file)), seq: seq}, nil
+}
+
+// concatSegments returns an iterator over the segments' entries in the
+// order they were written
+func concatSegments(segments []*sortSegment) func() (LogEntry, bool, error) {
+	i := 0
+	return func() (LogEntry, bool, error) {
+		for i < len(segments) {
+			var entry LogEntry
+			err := segments[i].decoder.Decode(&entry)
+			if err == nil {
+				return entry, true, nil
+			}
+			if err != io.EOF {
+				return LogEntry{}, false, err
+			}
+			i++
+		}
+		return LogEntry{}, false, nil
+	}
+}
+
+// mergeSegments returns an iterator over the k-way merge of sorted segments
+func mergeSegments(segments []*sortSegment) (func() (LogEntry, bool, error), error) {
+	h := &segmentHeap{}
+	for _, s := range segments {
+		if err := s.decoder.Decode(&s.head); err == nil {
+			heap.Push(h, s)
+		} else if err != io.EOF {
+			return nil, err
+		}
+	}
+
+	return func() (LogEntry, bool, error) {
+		if h.Len() == 0 {
+			return LogEntry{}, false, nil
+		}
+		s := (*h)[0]
+		entry := s.head
+
+		s.head = LogEntry{}
+		if err := s.decoder.Decode(&s.head); err == nil {
+			heap.Fix(h, 0)
+		} else if err == io.EOF {
+			heap.Pop(h)
+		} else {
+			return LogEntry{}, false, err
+		}
+		return entry, true, nil
+	}, nil
+}
+
+// streamEntries writes entries from an iterator in the requested output
+// format without holding them all in memory, applying -head/-tail
+func (la *LogAnalyzer) streamEntries(next func() (LogEntry, bool, error), head, tail int, outFormat string, verbose bool) error {
+	if head <= 0 && tail > 0 {
+		// Keep a ring of the last tail entries
+		ring := make([]LogEntry, 0, tail)
+		pos := 0
+		for {
+			entry, ok, err := next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			if len(ring) < tail {
+				ring = append(ring, entry)
+			} else {
+				ring[pos] = entry
+				pos = (pos + 1) % tail
+			}
+		}
+		ordered := append(ring[pos:], ring[:pos]...)
+		la.outputEntries(ordered, outFormat, verbose)
+		return nil
+	}
+
+	w := output.NewWriter(os.Stdout, outFormat, verbose)
+	for count := 0; head <= 0 || count < head; count++ {
+		entry, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := w.Write(entry); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// spill writes each run as a segment, removed when the test ends
+func spill(t *testing.T, runs ...[]LogEntry) []*sortSegment {
+	t.Helper()
+	var segments []*sortSegment
+	for i, run := range runs {
+		s, err := writeSegment(run, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			s.file.Close()
+			os.Remove(s.file.Name())
+		})
+		segments = append(segments, s)
+	}
+	return segments
+}
+
+// drain collects the messages an iterator returns
+func drain(t *testing.T, next func() (LogEntry, bool, error)) []string {
+	t.Helper()
+	var messages []string
+	for {
+		entry, ok, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			return messages
+		}
+		messages = append(messages, entry.Message)
+	}
+}
+
+func TestSpillMerge(t *testing.T) {
+	at := func(min int) time.Time { return time.Date(2024, 3, 1, 12, min, 0, 0, time.UTC) }
+	entry := func(min int, message string) LogEntry {
+		return LogEntry{Timestamp: at(min), Message: message}
+	}
+	tests := []struct {
+		name string
+		runs [][]LogEntry
+		want []string
+	}{
+		{
+			name: "interleaved",
+			runs: [][]LogEntry{
+				{entry(1, "a1"), entry(4, "a4"), entry(7, "a7")},
+				{entry(2, "b2"), entry(3, "b3"), entry(9, "b9")},
+				{entry(5, "c5")},
+			},
+			want: []string{"a1", "b2", "b3", "a4", "c5", "a7", "b9"},
+		},
+		{
+			// Equal timestamps keep the order of the segments they came from
+			name: "ties",
+			runs: [][]LogEntry{
+				{entry(1, "first"), entry(2, "third")},
+				{entry(1, "second"), entry(2, "fourth")},
+			},
+			want: []string{"first", "second", "third", "fourth"},
+		},
+		{
+			name: "empty segment",
+			runs: [][]LogEntry{{}, {entry(1, "only")}},
+			want: []string{"only"},
+		},
+	}
+	for _, tt := range tests {
+		next, err := mergeSegments(spill(t, tt.runs...))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := drain(t, next); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: merged %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSpillConcat(t *testing.T) {
+	segments := spill(t,
+		[]LogEntry{{Message: "one"}, {Message: "two"}},
+		[]LogEntry{{Message: "three"}},
+	)
+	if got, want := drain(t, concatSegments(segments)), []string{"one", "two", "three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("concatenated %q, want %q", got, want)
+	}
+}
+
+func TestSpillFields(t *testing.T) {
+	fields := map[string]interface{}{
+		"user":   "u1",
+		"status": 500.0,
+		"ok":     false,
+		"tags":   []interface{}{"a", map[string]interface{}{"b": 1.0}},
+		"note":   nil,
+	}
+	next, err := mergeSegments(spill(t, []LogEntry{{Message: "x", Fields: fields}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok, err := next()
+	if err != nil || !ok {
+		t.Fatalf("next = %v, %v", ok, err)
+	}
+	if !reflect.DeepEqual(entry.Fields, fields) {
+		t.Errorf("Fields after a spill = %#v, want %#v", entry.Fields, fields)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// splitKey returns the output file name (without extension) for an entry
+func splitKey(entry LogEntry, by string) string {
+	switch by {
+	case "day":
+		if !entry.Timestamp.IsZero() {
+			return entry.Timestamp.Format("2006-01-02")
+		}
+	case "hour":
+		if !entry.Timestamp.IsZero() {
+			return entry.Timestamp.Format("2006-01-02T15")
+		}
+	case "source":
+		if name := unsafeFileChars.ReplaceAllString(entry.Source, "_"); name != "" && name != "." && name != ".." {
+			return name
+		}
+	case "level":
+		if entry.Level != "" {
+			return entry.Level
+		}
+	}
+	return "unknown"
+}
+
+func runSplit(args []string) {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	input := addInputFlags(fs)
+	by := fs.String("by", "day", "Split by day, hour, source or level")
+	outDir := fs.String("out", "split", "Directory to write the split files to")
+	parseFlags(fs, args)
+
+	switch *by {
+	case "day", "hour", "source", "level":
+	default:
+		log.Fatalf("Invalid -by value %q (day, hour, source, level)", *by)
+	}
+
+	_, entries := input.load()
+
+	groups := make(map[string][]string)
+	for _, entry := range entries {
+		key := splitKey(entry, *by)
+		groups[key] = append(groups[key], entry.Raw)
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("Error creating output directory: %v", err)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := filepath.Join(*outDir, key+".log")
+		if err := writeLines(path, groups[key]); err != nil {
+			log.Fatalf("Error writing %s: %v", path, err)
+		}
+		fmt.Printf("%s: %d lines\n", path, len(groups[key]))
+	}
+}
+
+func writeLines(path string, lines []string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	for _, line := range lines {
+		w.WriteString(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
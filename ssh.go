@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTarget is a remote file given as ssh://user@host:/path (scp style) or
+// ssh://user@host:port/path
+type sshTarget struct {
+	user string
+	host string
+	port string
+	path string
+}
+
+func parseSSHURL(name string) (sshTarget, error) {
+	var t sshTarget
+	rest := strings.TrimPrefix(name, "ssh://")
+	if dest, path, ok := strings.Cut(rest, ":/"); ok && !strings.Contains(dest, "/") {
+		t.host, t.path = dest, "/"+path
+		if user, host, ok := strings.Cut(dest, "@"); ok {
+			t.user, t.host = user, host
+		}
+	} else {
+		u, err := url.Parse(name)
+		if err != nil || u.Host == "" || u.Path == "" {
+			return sshTarget{}, fmt.Errorf("invalid SSH URL %q (want ssh://user@host:/path)", name)
+		}
+		t.host, t.port, t.path = u.Hostname(), u.Port(), u.Path
+		if u.User != nil {
+			t.user = u.User.Username()
+		}
+	}
+	// Option-like hosts are how ssh:// URLs have smuggled ssh options
+	if t.host == "" || strings.HasPrefix(t.host, "-") || strings.ContainsAny(t.host, " \t'\"") {
+		return sshTarget{}, fmt.Errorf("invalid SSH host in %q", name)
+	}
+	if t.user == "" {
+		t.user = os.Getenv("USER")
+	}
+	if t.port == "" {
+		t.port = "22"
+	}
+	return t, nil
+}
+
+// shellQuote quotes s for a POSIX shell on the remote side
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// sshConfig authenticates with the user's agent ($SSH_AUTH_SOCK) and
+// unencrypted default keys in ~/.ssh, and checks the host against
+// ~/.ssh/known_hosts, so the keys the user already has work and nothing is
+// installed remotely
+func sshConfig(user string) (*ssh.ClientConfig, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("reading known_hosts: %v", err)
+	}
+
+	var methods []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		key, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(key); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no SSH agent or unencrypted key in ~/.ssh")
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            methods,
+		HostKeyCallback: hostKeys,
+		Timeout:         15 * time.Second,
+	}, nil
+}
+
+// sshReader is the output of a command running over an SSH connection
+type sshReader struct {
+	io.Reader
+	client  *ssh.Client
+	session *ssh.Session
+}
+
+func (r *sshReader) Close() error {
+	// Closing the session stops the remote command if the reader gave up
+	// early
+	r.session.Close()
+	return r.client.Close()
+}
+
+// openSSH streams a remote file's contents
+func openSSH(name string) (io.ReadCloser, error) {
+	return startSSH(name, "cat")
+}
+
+func startSSH(name, remoteCmd string) (io.ReadCloser, error) {
+	t, err := parseSSHURL(name)
+	if err != nil {
+		return nil, err
+	}
+	config, err := sshConfig(t.user)
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(t.host, t.port), config)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %v", t.host, err)
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	session.Stderr = os.Stderr
+	out, err := session.StdoutPipe()
+	if err == nil {
+		err = session.Start(remoteCmd + " " + shellQuote(t.path))
+	}
+	if err != nil {
+		session.Close()
+		client.Close()
+		return nil, fmt.Errorf("starting %s on %s: %v", remoteCmd, t.host, err)
+	}
+	return &sshReader{Reader: out, client: client, session: session}, nil
+}
+
+// followSSH tails a remote file, surviving rotation on the remote side
+func (la *LogAnalyzer) followSSH(name, format string, verbose bool) {
+	r, err := startSSH(name, "tail -n 0 -F")
+	if err != nil {
+		log.Fatalf("Error following file: %v", err)
+	}
+	defer r.Close()
+
+	fmt.Println("Following remote log file... (Press Ctrl+C to exit)")
+	scanner := la.newLineScanner(r)
+	for scanner.Scan() {
+		if entry := la.parseLine(scanner.Text(), format); entry != nil {
+			la.processLive(entry, verbose)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Error following file: %v", err)
+	}
+	log.Fatal("SSH connection closed")
+}
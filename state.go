@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateSaveInterval bounds how often the state file is rewritten while
+// lines keep arriving; it is also saved whenever following catches up
+const stateSaveInterval = time.Second
+
+// followState records how far each followed file was read, so a restarted
+// follow resumes there instead of at the end of the file
+type followState struct {
+	path   string
+	files  map[string]fileState
+	saved  time.Time
+	dirty  bool
+	failed bool
+}
+
+// fileState identifies a file by inode, so a rotated file isn't resumed at
+// its predecessor's offset
+type fileState struct {
+	Inode   uint64    `json:"inode"`
+	Offset  int64     `json:"offset"`
+	Updated time.Time `json:"updated"`
+}
+
+// loadFollowState reads a state file; a missing file is an empty state
+func loadFollowState(path string) (*followState, error) {
+	s := &followState{path: path, files: make(map[string]fileState)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.files); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// resumeOffset returns where to start reading a newly opened file: the
+// saved offset if it is the same file and wasn't truncated, the start if
+// the file was rotated or truncated since, and the end if it is new
+func (s *followState) resumeOffset(key string, inode uint64, size int64) int64 {
+	if s == nil {
+		return size
+	}
+	saved, ok := s.files[key]
+	switch {
+	case !ok:
+		return size
+	case saved.Inode != inode || saved.Offset > size:
+		return 0
+	}
+	return saved.Offset
+}
+
+// update records a file's position, writing the state file at most once
+// per stateSaveInterval unless flush is set
+func (s *followState) update(key string, inode uint64, offset int64, flush bool) {
+	if s == nil {
+		return
+	}
+	if cur, ok := s.files[key]; !ok || cur.Inode != inode || cur.Offset != offset {
+		s.files[key] = fileState{Inode: inode, Offset: offset, Updated: time.Now()}
+		s.dirty = true
+	}
+	if !s.dirty || (!flush && time.Since(s.saved) < stateSaveInterval) {
+		return
+	}
+	if err := s.save(); err != nil && !s.failed {
+		log.Printf("Error saving follow state: %v", err)
+		s.failed = true
+	}
+}
+
+// save replaces the state file atomically, so a crash mid-write leaves the
+// previous state
+func (s *followState) save() error {
+	s.saved, s.dirty = time.Now(), false
+	data, err := json.MarshalIndent(s.files, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
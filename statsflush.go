@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hrabid/log-analyzer/pkg/stats"
+)
+
+// statsFlushTop is how many sources and error templates a flush lists
+const statsFlushTop = 5
+
+// statsFlusher aggregates the entries followed since its last flush and
+// writes a summary of them every interval (-stats-interval), between the
+// streamed entries, so a long-running follow doubles as a monitoring feed
+type statsFlusher struct {
+	interval time.Duration
+	asJSON   bool
+	out      io.Writer
+
+	mu    sync.Mutex
+	agg   *stats.Aggregator
+	since time.Time
+}
+
+func newStatsFlusher(interval time.Duration, asJSON bool) *statsFlusher {
+	return &statsFlusher{interval: interval, asJSON: asJSON, out: os.Stdout, agg: stats.NewAggregator(), since: time.Now()}
+}
+
+// Add counts an entry that passed the filters
+func (f *statsFlusher) Add(entry LogEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.agg.Add(entry)
+}
+
+// Run flushes every interval
+func (f *statsFlusher) Run() {
+	for now := range time.Tick(f.interval) {
+		f.flush(now)
+	}
+}
+
+// statsSummary is a flush written with -stats-json
+type statsSummary struct {
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Entries    int            `json:"entries"`
+	Rate       float64        `json:"rate"`
+	ErrorRate  float64        `json:"error_rate"`
+	Levels     map[string]int `json:"levels"`
+	TopSources []countEntry   `json:"top_sources"`
+	TopErrors  []countEntry   `json:"top_errors"`
+}
+
+type countEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// flush writes the summary of the entries since the last flush and starts
+// a new interval
+func (f *statsFlusher) flush(now time.Time) {
+	f.mu.Lock()
+	s := f.agg.Stats()
+	since := f.since
+	f.agg, f.since = stats.NewAggregator(), now
+	f.mu.Unlock()
+
+	summary := statsSummary{
+		Start:   since,
+		End:     now,
+		Entries: s.TotalLines,
+		Levels:  make(map[string]int),
+	}
+	if secs := now.Sub(since).Seconds(); secs > 0 {
+		summary.Rate = float64(s.TotalLines) / secs
+	}
+	if s.TotalLines > 0 {
+		summary.ErrorRate = float64(s.ErrorCount+s.FatalCount) * 100 / float64(s.TotalLines)
+	}
+	for level, n := range map[string]int{
+		"TRACE": s.TraceCount, "DEBUG": s.DebugCount, "INFO": s.InfoCount,
+		"WARN": s.WarnCount, "ERROR": s.ErrorCount, "FATAL": s.FatalCount,
+	} {
+		if n > 0 {
+			summary.Levels[level] = n
+		}
+	}
+	summary.TopSources = topCounts(s.TopSources, statsFlushTop)
+	summary.TopErrors = topCounts(s.TopErrors, statsFlushTop)
+
+	if f.asJSON {
+		data, _ := json.Marshal(summary)
+		fmt.Fprintln(f.out, string(data))
+		return
+	}
+
+	levels := make([]string, 0, len(summary.Levels))
+	for level, n := range summary.Levels {
+		levels = append(levels, fmt.Sprintf("%s=%d", level, n))
+	}
+	sort.Strings(levels)
+	line := fmt.Sprintf("--- Stats %s to %s: %d entries (%.1f/s), errors %.1f%%",
+		since.Format("15:04:05"), now.Format("15:04:05"), summary.Entries, summary.Rate, summary.ErrorRate)
+	if len(levels) > 0 {
+		line += " | " + strings.Join(levels, " ")
+	}
+	if len(summary.TopSources) > 0 {
+		line += " | top sources: " + joinCounts(summary.TopSources)
+	}
+	if len(summary.TopErrors) > 0 {
+		line += " | top errors: " + joinCounts(summary.TopErrors)
+	}
+	fmt.Fprintln(f.out, line+" ---")
+}
+
+// topCounts returns the n largest counts, ties by name
+func topCounts(m map[string]int, n int) []countEntry {
+	counts := make([]countEntry, 0, len(m))
+	for name, count := range m {
+		counts = append(counts, countEntry{name, count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
+
+func joinCounts(counts []countEntry) string {
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = fmt.Sprintf("%s=%d", c.Name, c.Count)
+	}
+	return strings.Join(parts, " ")
+}
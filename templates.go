@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hrabid/log-analyzer/pkg/stats"
+)
+
+const (
+	// templateWildcard replaces tokens that differ between messages of a cluster
+	templateWildcard = "{*}"
+	// templateSimilarity is the fraction of matching tokens required to join
+	// an existing cluster
+	templateSimilarity = 0.5
+)
+
+// LogTemplate is a cluster of messages sharing the same constant tokens
+type LogTemplate struct {
+	Tokens []string
+	Count  int
+}
+
+func (t *LogTemplate) String() string {
+	return strings.Join(t.Tokens, " ")
+}
+
+// TemplateMiner groups messages into templates using a simplified Drain
+// algorithm: messages are bucketed by token count and leading token, then
+// matched against the most similar cluster in that bucket
+type TemplateMiner struct {
+	groups    map[string][]*LogTemplate
+	templates []*LogTemplate
+}
+
+func NewTemplateMiner() *TemplateMiner {
+	return &TemplateMiner{groups: make(map[string][]*LogTemplate)}
+}
+
+func (tm *TemplateMiner) Add(message string) *LogTemplate {
+	tokens := strings.Fields(stats.NormalizeMessage(message))
+	key := templateGroupKey(tokens)
+
+	var best *LogTemplate
+	bestSim := -1.0
+	for _, t := range tm.groups[key] {
+		if sim := templateSimilarityScore(t.Tokens, tokens); sim > bestSim {
+			best, bestSim = t, sim
+		}
+	}
+
+	if best != nil && bestSim >= templateSimilarity {
+		for i, tok := range tokens {
+			if best.Tokens[i] != tok {
+				best.Tokens[i] = templateWildcard
+			}
+		}
+		best.Count++
+		return best
+	}
+
+	t := &LogTemplate{Tokens: tokens, Count: 1}
+	tm.groups[key] = append(tm.groups[key], t)
+	tm.templates = append(tm.templates, t)
+	return t
+}
+
+// Top returns the n most frequent templates
+func (tm *TemplateMiner) Top(n int) []*LogTemplate {
+	sorted := append([]*LogTemplate(nil), tm.templates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Count > sorted[j].Count
+	})
+	if n > 0 && n < len(sorted) {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// templateGroupKey buckets by token count and the first token, unless the
+// first token is itself variable
+func templateGroupKey(tokens []string) string {
+	first := ""
+	if len(tokens) > 0 && !strings.Contains(tokens[0], "{") {
+		first = tokens[0]
+	}
+	return strconv.Itoa(len(tokens)) + " " + first
+}
+
+func templateSimilarityScore(template, tokens []string) float64 {
+	if len(tokens) == 0 {
+		return 1
+	}
+	same := 0
+	for i, tok := range tokens {
+		if template[i] == tok {
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens))
+}
+
+func (la *LogAnalyzer) showTemplates(entries []LogEntry, limit int) {
+	miner := NewTemplateMiner()
+	for _, entry := range entries {
+		miner.Add(entry.Message)
+	}
+
+	fmt.Printf("=== Message Templates (%d total) ===\n", len(miner.templates))
+	for _, t := range miner.Top(limit) {
+		fmt.Printf("%8d  %s\n", t.Count, t)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fieldPatterns caches the key=value extraction regex per field name
+var fieldPatterns = make(map[string]*regexp.Regexp)
+
+// extractField finds a named field in an entry: a top-level key for JSON
+// lines, or a key=value / key: value pair anywhere in the line otherwise
+func extractField(entry LogEntry, field string) string {
+	if strings.HasPrefix(strings.TrimSpace(entry.Raw), "{") {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(entry.Raw), &data); err == nil {
+			if v, ok := data[field]; ok && v != nil {
+				return fmt.Sprint(v)
+			}
+			return ""
+		}
+	}
+
+	re, ok := fieldPatterns[field]
+	if !ok {
+		re = regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(field) + `\s*[=:]\s*"?([^\s",;\]\}]+)`)
+		fieldPatterns[field] = re
+	}
+	if m := re.FindStringSubmatch(entry.Raw); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+type traceEntry struct {
+	File  string
+	Entry LogEntry
+}
+
+func runTrace(args []string) {
+	var id string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		id, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("trace", flag.ExitOnError)
+	input := addInputFlags(fs)
+	traceField := fs.String("trace-field", "request_id", "Field holding the request/trace ID")
+	verbose := fs.Bool("v", false, "Show the raw line for each step")
+	parseFlags(fs, args)
+
+	if id == "" && fs.NArg() > 0 {
+		id = fs.Arg(0)
+	}
+	if id == "" || len(input.files) == 0 {
+		fmt.Println("Usage: loganalyzer trace <id> -f <logfile> [-f <logfile>...] [-trace-field request_id]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var steps []traceEntry
+	for _, filename := range input.files {
+		analyzer := input.newAnalyzer()
+		if err := analyzer.parseFile(filename, *input.format); err != nil {
+			log.Fatalf("Error parsing file: %v", err)
+		}
+		for _, entry := range analyzer.filterEntries() {
+			if extractField(entry, *traceField) == id {
+				steps = append(steps, traceEntry{File: filepath.Base(filename), Entry: entry})
+			}
+		}
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Entry.Timestamp.Before(steps[j].Entry.Timestamp)
+	})
+
+	if len(steps) == 0 {
+		fmt.Printf("No entries with %s=%s\n", *traceField, id)
+		return
+	}
+
+	first := steps[0].Entry.Timestamp
+	last := steps[len(steps)-1].Entry.Timestamp
+	fmt.Printf("=== Trace %s=%s: %d entries in %d files, %s total ===\n",
+		*traceField, id, len(steps), len(input.files), last.Sub(first))
+	fmt.Printf("%10s  %10s  %-23s  %s\n", "Elapsed", "Step", "Timestamp", "Entry")
+
+	var prev time.Time
+	for i, step := range steps {
+		e := step.Entry
+		elapsed, delta := "-", "-"
+		if !e.Timestamp.IsZero() && !first.IsZero() {
+			elapsed = "+" + e.Timestamp.Sub(first).String()
+			if i > 0 && !prev.IsZero() {
+				delta = "+" + e.Timestamp.Sub(prev).String()
+			}
+		}
+		prev = e.Timestamp
+
+		fmt.Printf("%10s  %10s  %-23s  [%s] [%s] [%s] %s\n", elapsed, delta,
+			e.Timestamp.Format("2006-01-02 15:04:05.000"), step.File, e.Level, e.Source, e.Message)
+		if *verbose {
+			fmt.Printf("%48s%s\n", "", e.Raw)
+		}
+	}
+}
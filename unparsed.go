@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	// unparsedSamples is how many offending lines are kept per format
+	unparsedSamples = 5
+	// unparsedSampleLen truncates long samples in the report
+	unparsedSampleLen = 200
+)
+
+// parseFailures counts, per requested format, the lines read and the lines
+// no format matched, which became plain-text entries. Those lines are also
+// written to rejects (-rejects) when set.
+type parseFailures struct {
+	mu      sync.Mutex
+	lines   map[string]int
+	failed  map[string]int
+	samples map[string][]unparsedSample
+	rejects io.Writer
+}
+
+type unparsedSample struct {
+	lineNum int
+	line    string
+}
+
+func (p *parseFailures) add(format, line string, lineNum int, matched bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lines == nil {
+		p.lines = make(map[string]int)
+		p.failed = make(map[string]int)
+		p.samples = make(map[string][]unparsedSample)
+	}
+	p.lines[format]++
+	if matched {
+		return
+	}
+	p.failed[format]++
+	if p.rejects != nil {
+		io.WriteString(p.rejects, line+"\n")
+	}
+	if len(p.samples[format]) < unparsedSamples {
+		if len(line) > unparsedSampleLen {
+			line = line[:unparsedSampleLen] + "..."
+		}
+		p.samples[format] = append(p.samples[format], unparsedSample{lineNum, line})
+	}
+}
+
+// show prints the unparsed line counts and samples; nothing when every
+// line was parsed
+func (p *parseFailures) show() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	total, failed := 0, 0
+	formats := make([]string, 0, len(p.failed))
+	for format, n := range p.failed {
+		formats = append(formats, format)
+		failed += n
+	}
+	for _, n := range p.lines {
+		total += n
+	}
+	if failed == 0 {
+		return
+	}
+	sort.Strings(formats)
+
+	fmt.Printf("Unparsed Lines: %d of %d (%.1f%%), kept as plain text\n", failed, total, 100*float64(failed)/float64(total))
+	for _, format := range formats {
+		fmt.Printf("  %s: %d of %d\n", format, p.failed[format], p.lines[format])
+		for _, sample := range p.samples[format] {
+			if sample.lineNum > 0 {
+				fmt.Printf("    line %d: %q\n", sample.lineNum, sample.line)
+			} else {
+				fmt.Printf("    %q\n", sample.line)
+			}
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/hrabid/log-analyzer/pkg/parser"
+)
+
+// validation counts how well a format reads its inputs: the lines it
+// matched and, among the entries it produced, how often each field was
+// empty
+type validation struct {
+	lines, matched, entries int
+	// present counts the entries with a value for each field seen; the
+	// others had it empty or missing
+	present map[string]int
+}
+
+// runValidate parses the inputs without printing entries and reports the
+// lines the format didn't match, as a quick check of a custom pattern. It
+// exits 1 when any line was unmatched.
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	input := addInputFlags(fs)
+	parseFlags(fs, args)
+
+	if len(input.files) == 0 {
+		fmt.Println("Usage: loganalyzer validate -f <logfile> [-f <logfile>...] -format <format> [options]")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	analyzer := input.newAnalyzer()
+	v := &validation{present: make(map[string]int)}
+	for _, filename := range input.files {
+		if err := analyzer.validateFile(filename, *input.format, v); err != nil {
+			log.Fatalf("Error reading %s: %v", filename, err)
+		}
+	}
+
+	v.show(*input.format)
+	analyzer.unparsed.show()
+	if v.matched < v.lines {
+		os.Exit(1)
+	}
+}
+
+// validateFile parses every line of an input in the given format, counting
+// the entries of matched lines into v
+func (la *LogAnalyzer) validateFile(filename, format string, v *validation) error {
+	r, err := la.openInput(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	session := parser.NewSession(format)
+	scanner := la.newLineScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		v.lines++
+		entry, matched := la.tryParse(scanner.Text(), session, lineNum)
+		if !matched {
+			continue
+		}
+		v.matched++
+		if entry != nil {
+			v.add(entry)
+		}
+	}
+	return scanner.Err()
+}
+
+func (v *validation) add(entry *LogEntry) {
+	v.entries++
+	values := map[string]string{
+		"level":   entry.Level,
+		"message": entry.Message,
+		"source":  entry.Source,
+	}
+	if entry.Timestamp.IsZero() {
+		values["timestamp"] = ""
+	} else {
+		values["timestamp"] = "set"
+	}
+	for name, value := range entry.Fields {
+		values[name] = parser.FieldString(value)
+	}
+
+	for name, value := range values {
+		if _, seen := v.present[name]; !seen {
+			v.present[name] = 0
+		}
+		if value != "" {
+			v.present[name]++
+		}
+	}
+}
+
+func (v *validation) show(format string) {
+	fmt.Printf("=== Validation: %d lines, format %s ===\n", v.lines, format)
+	if v.lines == 0 {
+		return
+	}
+	fmt.Printf("Matched:   %d (%.1f%%)\n", v.matched, 100*float64(v.matched)/float64(v.lines))
+	fmt.Printf("Unmatched: %d (%.1f%%)\n", v.lines-v.matched, 100*float64(v.lines-v.matched)/float64(v.lines))
+	fmt.Printf("Entries:   %d\n", v.entries)
+	if v.entries == 0 {
+		fmt.Println()
+		return
+	}
+
+	names := make([]string, 0, len(v.present))
+	for name := range v.present {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println()
+	fmt.Println("Empty Fields:")
+	shown := false
+	for _, name := range names {
+		if n := v.entries - v.present[name]; n > 0 {
+			fmt.Printf("  %-20s %8d of %d (%.1f%%)\n", name, n, v.entries, 100*float64(n)/float64(v.entries))
+			shown = true
+		}
+	}
+	if !shown {
+		fmt.Println("  none, every entry has every field")
+	}
+	fmt.Println()
+}